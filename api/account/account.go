@@ -0,0 +1,59 @@
+package account
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+const (
+	Cash       = "cash"
+	Bank       = "bank"
+	CreditCard = "credit_card"
+)
+
+// Account is where a spender's money sits, transactions reference it and
+// transfers move money between two of them.
+type Account struct {
+	ID             int64     `json:"id"`
+	SpenderID      int64     `json:"spender_id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Currency       string    `json:"currency"`
+	OpeningBalance float64   `json:"opening_balance"`
+	Balance        float64   `json:"balance"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Entry is one transaction seen from an account, Change is signed and
+// RunningBalance is the account balance right after it.
+type Entry struct {
+	ID              int64     `json:"id"`
+	Date            time.Time `json:"date"`
+	Amount          float64   `json:"amount"`
+	Category        string    `json:"category"`
+	TransactionType string    `json:"transaction_type"`
+	Note            string    `json:"note"`
+	Change          float64   `json:"change"`
+	RunningBalance  float64   `json:"running_balance"`
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func (a *Account) validate() error {
+	if a.Name == "" {
+		return errors.New("name is required")
+	}
+	switch a.Type {
+	case Cash, Bank, CreditCard:
+	default:
+		return errors.New("type must be one of cash, bank or credit_card")
+	}
+	if a.Currency == "" {
+		a.Currency = "THB"
+	}
+	if !currencyCode.MatchString(a.Currency) {
+		return errors.New("currency must be an ISO 4217 code such as THB")
+	}
+	return nil
+}
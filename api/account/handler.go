@@ -0,0 +1,260 @@
+package account
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	// change is how a transaction moves the balance of account a
+	change = `CASE WHEN t.to_account_id = a.id THEN t.amount WHEN t.transaction_type = 'income' THEN t.amount ELSE -t.amount END`
+
+	balance = `a.opening_balance + COALESCE((SELECT SUM(` + change + `) FROM transaction t WHERE (t.account_id = a.id OR t.to_account_id = a.id) AND t.deleted_at IS NULL), 0)`
+
+	columns = `a.id, a.spender_id, a.name, a.type, a.currency, a.opening_balance, ` + balance + ` AS balance, a.created_at`
+
+	cStmt        = `INSERT INTO account (spender_id, name, type, currency, opening_balance) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at;`
+	uStmt        = `UPDATE account SET name = $1, type = $2, currency = $3, opening_balance = $4 WHERE id = $5;`
+	bySpenderID  = `SELECT ` + columns + ` FROM account a WHERE a.spender_id = $1 ORDER BY a.id`
+	getStmt      = `SELECT ` + columns + ` FROM account a WHERE a.id = $1`
+	countEntries = `SELECT COUNT(*) FROM transaction WHERE (account_id = $1 OR to_account_id = $1) AND deleted_at IS NULL`
+
+	// the window runs over every entry of the account before paging
+	entriesStmt = `SELECT id, date, amount, category, transaction_type, note, change, $2 + SUM(change) OVER (ORDER BY date, id) AS running_balance FROM (
+		SELECT t.id, t.date, t.amount, t.category, t.transaction_type, t.note, ` + change + ` AS change
+		FROM transaction t JOIN account a ON a.id = $1 WHERE (t.account_id = $1 OR t.to_account_id = $1) AND t.deleted_at IS NULL
+	) e ORDER BY date DESC, id DESC LIMIT $3 OFFSET $4`
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAccount(row scanner) (Account, error) {
+	var a Account
+	err := row.Scan(&a.ID, &a.SpenderID, &a.Name, &a.Type, &a.Currency, &a.OpeningBalance, &a.Balance, &a.CreatedAt)
+	return a, err
+}
+
+// POST /api/v1/spenders/:id/accounts
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var a Account
+	if err := c.Bind(&a); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := a.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+	a.SpenderID = spenderID
+	a.Balance = a.OpeningBalance
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, cStmt, a.SpenderID, a.Name, a.Type, a.Currency, a.OpeningBalance).Scan(&a.ID, &a.CreatedAt)
+	})
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("create successfully", zap.Int64("id", a.ID))
+	return c.JSON(http.StatusCreated, a)
+}
+
+// GET /api/v1/spenders/:id/accounts
+func (h handler) GetBySpenderID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	rows, err := h.db.QueryContext(ctx, bySpenderID, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, accounts)
+}
+
+// GET /api/v1/accounts/:id
+func (h handler) GetByID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid account id")
+	}
+
+	a, err := scanAccount(h.db.QueryRowContext(ctx, getStmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "account not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, a)
+}
+
+// PUT /api/v1/accounts/:id
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid account id")
+	}
+
+	var a Account
+	if err := c.Bind(&a); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := a.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, uStmt, a.Name, a.Type, a.Currency, a.OpeningBalance, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "account not found")
+	}
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	// reload for the balance computed from the new opening balance
+	a, err = scanAccount(h.db.QueryRowContext(ctx, getStmt, id))
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("update successfully", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, a)
+}
+
+// GET /api/v1/accounts/:id/transactions?page=1&limit=10
+// Newest first, each entry carries the account balance right after it.
+func (h handler) GetEntries(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid account id")
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	a, err := scanAccount(h.db.QueryRowContext(ctx, getStmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "account not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	rows, err := h.db.QueryContext(ctx, entriesStmt, id, a.OpeningBalance, limit, (page-1)*limit)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Date, &e.Amount, &e.Category, &e.TransactionType, &e.Note, &e.Change, &e.RunningBalance); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	var total int
+	if err := h.db.QueryRowContext(ctx, countEntries, id).Scan(&total); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"account":      a,
+		"transactions": entries,
+		"pagination": map[string]int{
+			"current_page": page,
+			"total_pages":  int(math.Ceil(float64(total) / float64(limit))),
+			"per_page":     limit,
+		},
+	})
+}
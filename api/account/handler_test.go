@@ -0,0 +1,217 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var accountColumns = []string{"id", "spender_id", "name", "type", "currency", "opening_balance", "balance", "created_at"}
+
+func TestCreateAccount(t *testing.T) {
+	t.Run("create account with default currency", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Wallet", "type": "cash", "opening_balance": 500}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs(int64(1), "Wallet", Cash, "THB", 500.0).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, created))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 3, "spender_id": 1, "name": "Wallet", "type": "cash", "currency": "THB", "opening_balance": 500, "balance": 500, "created_at": "2024-05-01T00:00:00Z"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject unknown account type", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Stocks", "type": "brokerage"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("reject lowercase currency", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Card", "type": "credit_card", "currency": "usd"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetAccountsBySpenderID(t *testing.T) {
+	t.Run("list accounts with current balances", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(accountColumns).
+			AddRow(1, 1, "Wallet", "cash", "THB", 500.0, 350.0, created).
+			AddRow(2, 1, "Savings", "bank", "THB", 0.0, 1000.0, created)
+		mock.ExpectQuery(bySpenderID).WithArgs(int64(1)).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+			{"id": 1, "spender_id": 1, "name": "Wallet", "type": "cash", "currency": "THB", "opening_balance": 500, "balance": 350, "created_at": "2024-05-01T00:00:00Z"},
+			{"id": 2, "spender_id": 1, "name": "Savings", "type": "bank", "currency": "THB", "opening_balance": 0, "balance": 1000, "created_at": "2024-05-01T00:00:00Z"}
+		]`, rec.Body.String())
+	})
+}
+
+func TestGetAccountByID(t *testing.T) {
+	t.Run("account not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(getStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows(accountColumns))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestUpdateAccount(t *testing.T) {
+	t.Run("update account and return the new balance", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"name": "Pocket", "type": "cash", "currency": "THB", "opening_balance": 600}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(uStmt).WithArgs("Pocket", Cash, "THB", 600.0, int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(getStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(accountColumns).AddRow(1, 1, "Pocket", "cash", "THB", 600.0, 450.0, created))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "spender_id": 1, "name": "Pocket", "type": "cash", "currency": "THB", "opening_balance": 600, "balance": 450, "created_at": "2024-05-01T00:00:00Z"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAccountEntries(t *testing.T) {
+	t.Run("entries carry the running balance", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?page=1&limit=2", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(getStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(accountColumns).AddRow(1, 1, "Wallet", "cash", "THB", 500.0, 350.0, created))
+
+		day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+		entries := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "note", "change", "running_balance"}).
+			AddRow(5, day, 200.0, "saving", "transfer", "", -200.0, 350.0).
+			AddRow(4, day, 50.0, "salary", "income", "", 50.0, 550.0)
+		mock.ExpectQuery(entriesStmt).WithArgs(int64(1), 500.0, 2, 0).WillReturnRows(entries)
+		mock.ExpectQuery(countEntries).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetEntries(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"account": {"id": 1, "spender_id": 1, "name": "Wallet", "type": "cash", "currency": "THB", "opening_balance": 500, "balance": 350, "created_at": "2024-05-01T00:00:00Z"},
+			"transactions": [
+				{"id": 5, "date": "2024-05-02T00:00:00Z", "amount": 200, "category": "saving", "transaction_type": "transfer", "note": "", "change": -200, "running_balance": 350},
+				{"id": 4, "date": "2024-05-02T00:00:00Z", "amount": 50, "category": "salary", "transaction_type": "income", "note": "", "change": 50, "running_balance": 550}
+			],
+			"pagination": {"current_page": 1, "total_pages": 2, "per_page": 2}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Bulk actions.
+const (
+	Recategorize = "recategorize"
+	Delete       = "delete"
+	Reassign     = "reassign"
+)
+
+const (
+	// the matching rows are locked so what is counted is what gets changed
+	matchStmt        = `SELECT COUNT(*) FROM (SELECT t.id FROM "transaction" t WHERE %s FOR UPDATE) m`
+	recategorizeStmt = `UPDATE "transaction" t SET category = $1 WHERE %s`
+	deleteStmt       = `UPDATE "transaction" t SET deleted_at = now() WHERE %s`
+	// a transaction through accounts, which belong to its spender, or in a
+	// wallet the new owner may not record into is not moved
+	reassignStmt = `UPDATE "transaction" t SET spender_id = $1 WHERE t.account_id IS NULL AND t.to_account_id IS NULL AND (t.wallet_id IS NULL OR EXISTS (SELECT 1 FROM wallet_member m WHERE m.wallet_id = t.wallet_id AND m.spender_id = $1 AND m.role IN ('owner', 'editor'))) AND %s`
+	existsStmt   = `SELECT EXISTS (SELECT 1 FROM spender WHERE id = $1 AND deleted_at IS NULL)`
+)
+
+var (
+	errCountChanged = errors.New("count changed")
+	errNotMovable   = errors.New("transactions not movable")
+)
+
+// BulkRequest applies Action to the transactions picked by Filter, by ids,
+// by criteria or both. Category is the new category of recategorize,
+// SpenderID the new owner of reassign. A reassign is refused when one of
+// the transactions uses accounts, or a wallet the new owner cannot record
+// into. The tags of the moved transactions become the new owner's tags of
+// the same names.
+//
+// DryRun only counts. ExpectedCount, usually the count of a dry run, makes
+// the action refuse to run when the transactions matching have changed
+// since.
+type BulkRequest struct {
+	Action        string `json:"action"`
+	Filter        Filter `json:"filter"`
+	Category      string `json:"category"`
+	SpenderID     int64  `json:"spender_id"`
+	DryRun        bool   `json:"dry_run"`
+	ExpectedCount *int   `json:"expected_count"`
+}
+
+type BulkResult struct {
+	Action   string `json:"action"`
+	DryRun   bool   `json:"dry_run"`
+	Matched  int    `json:"matched"`
+	Affected int    `json:"affected"`
+}
+
+func (r *BulkRequest) validate() error {
+	r.Category = strings.TrimSpace(r.Category)
+	switch r.Action {
+	case Recategorize:
+		if r.Category == "" {
+			return errors.New("category is required to recategorize")
+		}
+	case Reassign:
+		if r.SpenderID <= 0 {
+			return errors.New("spender_id is required to reassign")
+		}
+	case Delete:
+	default:
+		return fmt.Errorf("action must be %s, %s or %s", Recategorize, Delete, Reassign)
+	}
+	if err := r.Filter.validate(); err != nil {
+		return err
+	}
+	if r.Filter.empty() {
+		return errors.New("filter must pick transactions by ids or criteria")
+	}
+	return nil
+}
+
+// POST /api/v1/admin/transactions/bulk
+// Runs in one DB transaction, the transactions matching are counted and
+// locked first, a dry run stops there.
+func (h handler) Bulk(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var req BulkRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := req.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	result, err := h.bulk(ctx, req)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	if errors.Is(err, errCountChanged) {
+		return apierror.Respond(c, http.StatusConflict, fmt.Sprintf("%d transactions match, expected %d", result.Matched, *req.ExpectedCount))
+	}
+	if errors.Is(err, errNotMovable) {
+		return apierror.Respond(c, http.StatusConflict, fmt.Sprintf("%d of %d transactions use accounts or a wallet spender %d cannot record into", result.Matched-result.Affected, result.Matched, req.SpenderID))
+	}
+	if err != nil {
+		logger.Error("bulk error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("bulk action successfully", zap.String("action", req.Action), zap.Bool("dry_run", req.DryRun), zap.Int("affected", result.Affected))
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h handler) bulk(ctx context.Context, req BulkRequest) (BulkResult, error) {
+	result := BulkResult{Action: req.Action, DryRun: req.DryRun}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if req.Action == Reassign {
+		var ok bool
+		if err := tx.QueryRowContext(ctx, existsStmt, req.SpenderID).Scan(&ok); err != nil {
+			return result, err
+		}
+		if !ok {
+			return result, sql.ErrNoRows
+		}
+	}
+
+	where, args := req.Filter.clauses(nil)
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf(matchStmt, where), args...).Scan(&result.Matched); err != nil {
+		return result, err
+	}
+	if req.ExpectedCount != nil && *req.ExpectedCount != result.Matched {
+		return result, errCountChanged
+	}
+	if req.DryRun || result.Matched == 0 {
+		return result, nil
+	}
+
+	var stmt string
+	args = nil
+	switch req.Action {
+	case Recategorize:
+		stmt, args = recategorizeStmt, []any{req.Category}
+	case Reassign:
+		stmt, args = reassignStmt, []any{req.SpenderID}
+	case Delete:
+		stmt = deleteStmt
+	}
+
+	where, args = req.Filter.clauses(args)
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(stmt, where), args...)
+	if err != nil {
+		return result, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return result, err
+	}
+	result.Affected = int(n)
+	if req.Action == Reassign {
+		if result.Affected < result.Matched {
+			return result, errNotMovable
+		}
+		if err := tag.Move(ctx, tx, req.SpenderID); err != nil {
+			return result, err
+		}
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// the statements tag.Move runs to relink the tags of moved transactions
+var moveTagStmts = []string{
+	`INSERT INTO tag (spender_id, name) SELECT DISTINCT $1::int, g.name FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT (spender_id, name) DO NOTHING;`,
+	`INSERT INTO transaction_tag (transaction_id, tag_id) SELECT tt.transaction_id, n.id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id JOIN tag n ON n.spender_id = $1 AND n.name = g.name WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT DO NOTHING;`,
+	`DELETE FROM transaction_tag tt USING tag g, "transaction" t WHERE g.id = tt.tag_id AND t.id = tt.transaction_id AND t.spender_id = $1 AND g.spender_id <> $1;`,
+}
+
+func TestBulk(t *testing.T) {
+	t.Run("dry run only counts", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", `{"action": "delete", "filter": {"spender_id": 1, "category": "Test"}, "dry_run": true}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(fmt.Sprintf(matchStmt, "t.deleted_at IS NULL AND t.spender_id = $1 AND t.category = $2")).WithArgs(int64(1), "Test").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Bulk(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"action": "delete", "dry_run": true, "matched": 12, "affected": 0}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("recategorize by id list", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", `{"action": "recategorize", "filter": {"ids": [4, 5]}, "category": " Groceries ", "expected_count": 2}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(fmt.Sprintf(matchStmt, "t.deleted_at IS NULL AND t.id = ANY($1::int[])")).WithArgs(pq.Array([]int64{4, 5})).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectExec(fmt.Sprintf(recategorizeStmt, "t.deleted_at IS NULL AND t.id = ANY($2::int[])")).WithArgs("Groceries", pq.Array([]int64{4, 5})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Bulk(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"action": "recategorize", "dry_run": false, "matched": 2, "affected": 2}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reassign to another spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", `{"action": "reassign", "filter": {"spender_id": 1}, "spender_id": 2}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(existsStmt).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(fmt.Sprintf(matchStmt, "t.deleted_at IS NULL AND t.spender_id = $1")).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectExec(fmt.Sprintf(reassignStmt, "t.deleted_at IS NULL AND t.spender_id = $2")).WithArgs(int64(2), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		for _, stmt := range moveTagStmts {
+			mock.ExpectExec(stmt).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Bulk(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"action": "reassign", "dry_run": false, "matched": 3, "affected": 3}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuse to reassign transactions through accounts", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", `{"action": "reassign", "filter": {"spender_id": 1}, "spender_id": 2}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(existsStmt).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(fmt.Sprintf(matchStmt, "t.deleted_at IS NULL AND t.spender_id = $1")).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectExec(fmt.Sprintf(reassignStmt, "t.deleted_at IS NULL AND t.spender_id = $2")).WithArgs(int64(2), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Bulk(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "1 of 3 transactions use accounts or a wallet spender 2 cannot record into")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuse when the match changed since the dry run", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", `{"action": "delete", "filter": {"category": "Test"}, "expected_count": 12}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(fmt.Sprintf(matchStmt, "t.deleted_at IS NULL AND t.category = $1")).WithArgs("Test").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(13))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Bulk(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Conflict", "status": 409, "detail": "13 transactions match, expected 12"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject an incomplete or unbounded request", func(t *testing.T) {
+		for _, body := range []string{
+			`{"action": "delete", "filter": {}}`,
+			`{"action": "archive", "filter": {"ids": [1]}}`,
+			`{"action": "recategorize", "filter": {"ids": [1]}}`,
+			`{"action": "reassign", "filter": {"ids": [1]}}`,
+		} {
+			c, rec := newContext(http.MethodPost, "/", body)
+
+			h := New(config.FeatureFlag{}, nil)
+			err := h.Bulk(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// maxIDs bounds the id list of a bulk action.
+	maxIDs = 1000
+)
+
+// Filter picks transactions across spenders. Every value ends up as a bind
+// parameter.
+type Filter struct {
+	IDs             []int64 `json:"ids,omitempty"`
+	SpenderID       int64   `json:"spender_id,omitempty"`
+	Category        string  `json:"category,omitempty"`
+	TransactionType string  `json:"transaction_type,omitempty"`
+	From            string  `json:"from,omitempty"`
+	To              string  `json:"to,omitempty"`
+}
+
+// parseFilter reads ?spender_id=&category=&transaction_type=&from=&to=.
+func parseFilter(c echo.Context) (Filter, error) {
+	f := Filter{
+		Category:        c.QueryParam("category"),
+		TransactionType: c.QueryParam("transaction_type"),
+		From:            c.QueryParam("from"),
+		To:              c.QueryParam("to"),
+	}
+	if s := c.QueryParam("spender_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Filter{}, errors.New("invalid spender id")
+		}
+		f.SpenderID = id
+	}
+	return f, f.validate()
+}
+
+func (f Filter) validate() error {
+	for _, p := range [][2]string{{"from", f.From}, {"to", f.To}} {
+		if p[1] == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, p[1]); err != nil {
+			return fmt.Errorf("%s must be a date like 2024-01-31", p[0])
+		}
+	}
+	if len(f.IDs) > maxIDs {
+		return fmt.Errorf("at most %d ids at a time", maxIDs)
+	}
+	return nil
+}
+
+// empty reports whether the filter would pick every transaction.
+func (f Filter) empty() bool {
+	return len(f.IDs) == 0 && f.SpenderID == 0 && f.Category == "" && f.TransactionType == "" && f.From == "" && f.To == ""
+}
+
+// clauses is the condition on transactions aliased t, numbering the
+// placeholders after the args already bound. Deleted transactions are
+// never picked.
+func (f Filter) clauses(args []any) (string, []any) {
+	where := "t.deleted_at IS NULL"
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where += fmt.Sprintf(" AND "+cond, len(args))
+	}
+
+	if len(f.IDs) > 0 {
+		add("t.id = ANY($%d::int[])", pq.Array(f.IDs))
+	}
+	if f.SpenderID != 0 {
+		add("t.spender_id = $%d", f.SpenderID)
+	}
+	if f.Category != "" {
+		add("t.category = $%d", f.Category)
+	}
+	if f.TransactionType != "" {
+		add("t.transaction_type = $%d", f.TransactionType)
+	}
+	if f.From != "" {
+		add("DATE(t.date) >= $%d", f.From)
+	}
+	if f.To != "" {
+		add("DATE(t.date) <= $%d", f.To)
+	}
+	return where, args
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterClauses(t *testing.T) {
+	t.Run("only live transactions without criteria", func(t *testing.T) {
+		where, args := Filter{}.clauses(nil)
+
+		assert.Equal(t, "t.deleted_at IS NULL", where)
+		assert.Empty(t, args)
+		assert.True(t, Filter{}.empty())
+	})
+
+	t.Run("number the criteria after the args already bound", func(t *testing.T) {
+		f := Filter{IDs: []int64{4, 5}, SpenderID: 1, Category: "Food", From: "2024-05-01"}
+
+		where, args := f.clauses([]any{"Groceries"})
+
+		assert.Equal(t, "t.deleted_at IS NULL AND t.id = ANY($2::int[]) AND t.spender_id = $3 AND t.category = $4 AND DATE(t.date) >= $5", where)
+		assert.Equal(t, []any{"Groceries", pq.Array([]int64{4, 5}), int64(1), "Food", "2024-05-01"}, args)
+	})
+
+	t.Run("reject a bad date or too many ids", func(t *testing.T) {
+		assert.Error(t, Filter{To: "31/05/2024"}.validate())
+		assert.Error(t, Filter{IDs: make([]int64, maxIDs+1)}.validate())
+	})
+}
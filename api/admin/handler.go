@@ -0,0 +1,152 @@
+// Package admin lets operators manage transactions across spenders. Its
+// routes sit behind a shared token, see Auth.
+package admin
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/pagination"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+const (
+	listStmt = `SELECT t.id, t.date, t.amount, t.category, t.transaction_type, COALESCE(t.note, ''), t.merchant, t.spender_id, s.name, s.email, s.deleted_at IS NOT NULL
+		FROM "transaction" t LEFT JOIN spender s ON s.id = t.spender_id WHERE %s ORDER BY t.date DESC, t.id DESC LIMIT $%d OFFSET $%d`
+	countStmt = `SELECT COUNT(*) FROM "transaction" t WHERE %s`
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+// Auth lets through requests bearing the admin token. Without a token
+// configured the admin routes don't exist. Changes are logged as made by
+// the admin named in X-Admin-User.
+func Auth(cfg config.Admin) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Token == "" {
+				return apierror.Respond(c, http.StatusNotFound, "Not Found")
+			}
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+				return apierror.Respond(c, http.StatusUnauthorized, "invalid admin token")
+			}
+			audit.SetActor(c, audit.Actor{Type: audit.Admin, ID: c.Request().Header.Get("X-Admin-User")})
+			return next(c)
+		}
+	}
+}
+
+// Spender is who a transaction belongs to, Deleted when they were removed
+// but their transactions kept.
+type Spender struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Deleted bool   `json:"deleted"`
+}
+
+type Transaction struct {
+	ID              int64     `json:"id"`
+	Date            time.Time `json:"date"`
+	Amount          float64   `json:"amount"`
+	Category        string    `json:"category"`
+	TransactionType string    `json:"transaction_type"`
+	Note            string    `json:"note"`
+	Merchant        string    `json:"merchant"`
+	Spender         *Spender  `json:"spender"`
+}
+
+type List struct {
+	Transactions []Transaction   `json:"transactions"`
+	Pagination   pagination.Info `json:"pagination"`
+}
+
+// GET /api/v1/admin/transactions?spender_id=&category=&transaction_type=&from=&to=&page=&limit=
+// Lists transactions of every spender, latest first.
+func (h handler) ListTransactions(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	f, err := parseFilter(c)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+	page, limit := 1, defaultLimit
+	if s := c.QueryParam("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 {
+			return apierror.Respond(c, http.StatusBadRequest, "page must be a positive number")
+		}
+	}
+	if s := c.QueryParam("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			return apierror.Respond(c, http.StatusBadRequest, "limit must be a positive number")
+		}
+		limit = min(limit, maxLimit)
+	}
+
+	where, args := f.clauses(nil)
+	var total int
+	if err := h.db.QueryRowContext(ctx, fmt.Sprintf(countStmt, where), args...).Scan(&total); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	query := fmt.Sprintf(listStmt, where, len(args)+1, len(args)+2)
+	rows, err := h.db.QueryContext(ctx, query, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	txs := []Transaction{}
+	for rows.Next() {
+		var (
+			t         Transaction
+			spenderID sql.NullInt64
+			name      sql.NullString
+			email     sql.NullString
+			deleted   bool
+		)
+		if err := rows.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.Note, &t.Merchant, &spenderID, &name, &email, &deleted); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if spenderID.Valid {
+			t.Spender = &Spender{ID: spenderID.Int64, Name: name.String, Email: email.String, Deleted: deleted}
+		}
+		txs = append(txs, t)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, List{
+		Transactions: txs,
+		Pagination:   pagination.Offset(page, limit, total),
+	})
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContext(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestAuth(t *testing.T) {
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+
+	for name, tc := range map[string]struct {
+		token  string
+		header string
+		code   int
+	}{
+		"let the admin token through":    {"s3cret", "Bearer s3cret", http.StatusNoContent},
+		"reject a wrong token":           {"s3cret", "Bearer guess", http.StatusUnauthorized},
+		"reject a missing token":         {"s3cret", "", http.StatusUnauthorized},
+		"hide the routes without config": {"", "Bearer ", http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/", "")
+			c.Request().Header.Set(echo.HeaderAuthorization, tc.header)
+
+			err := Auth(config.Admin{Token: tc.token})(ok)(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	t.Run("list transactions of every spender with who they belong to", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?category=Food&page=2&limit=1", "")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		where := "t.deleted_at IS NULL AND t.category = $1"
+		date := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(fmt.Sprintf(countStmt, where)).WithArgs("Food").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(fmt.Sprintf(listStmt, where, 2, 3)).WithArgs("Food", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "note", "merchant", "spender_id", "name", "email", "deleted"}).
+				AddRow(7, date, 250, "Food", "expense", "lunch", "", 2, "Mai", "mai@example.com", true))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.ListTransactions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [{"id": 7, "date": "2024-05-03T12:00:00Z", "amount": 250, "category": "Food", "transaction_type": "expense", "note": "lunch", "merchant": "",
+				"spender": {"id": 2, "name": "Mai", "email": "mai@example.com", "deleted": true}}],
+			"pagination": {"current_page": 2, "total_pages": 3, "per_page": 1, "total_count": 3}
+		}`, rec.Body.String())
+	})
+
+	t.Run("list transactions failed on bad filter", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?spender_id=abc", "")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.ListTransactions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
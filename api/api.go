@@ -3,12 +3,25 @@ package api
 import (
 	"database/sql"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/account"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/admin"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/balance"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/changefeed"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dashboard"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/eslip"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/health"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/importer"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/merchant"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/recurring"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/suggest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/wallet"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -24,9 +37,11 @@ type Postgres struct {
 
 func New(db *sql.DB, cfg config.Config, logger *zap.Logger) *Server {
 	e := echo.New()
+	e.HTTPErrorHandler = apierror.Handler
 
 	e.Use(middleware.Logger())
 	e.Use(mlog.Middleware(logger))
+	e.Use(audit.Middleware(cfg.Gateway.Secret))
 
 	v1 := e.Group("/api/v1")
 
@@ -41,18 +56,128 @@ func New(db *sql.DB, cfg config.Config, logger *zap.Logger) *Server {
 		v1.GET("/spenders", h.GetAll)
 		v1.POST("/spenders", h.Create)
 		v1.GET("/spenders/:id", h.GetByID)
+		v1.PUT("/spenders/:id", h.Update)
+		v1.PATCH("/spenders/:id", h.Patch)
+		v1.DELETE("/spenders/:id", h.Delete)
+		v1.GET("/spenders/:id/preferences", h.GetPreferences)
+		v1.PUT("/spenders/:id/preferences", h.UpdatePreferences)
 	}
 
 	{
 		h := transaction.New(cfg.FeatureFlag, &transaction.Postgres{Db: db})
 		v1.GET("/spenders/:id/transactions", h.GetTransactionDetailBySpenderIdHandler)
 		v1.GET("/spenders/:id/transactions/summary", h.GetTransactionSummaryBySpenderIdHandler)
+		v1.GET("/spenders/:id/transactions/summary/categories", h.GetCategorySummaryBySpenderIdHandler)
 	}
 
 	{
 		h := transaction.NewHandler(cfg.FeatureFlag, db)
 		v1.POST("/transactions", h.Create)
+		v1.POST("/transactions/batch", h.CreateBatch)
 		v1.PUT("/transactions/:id", h.Update)
+		v1.DELETE("/transactions/:id", h.Delete)
+		v1.GET("/transactions/:id/versions", h.Versions)
+		v1.POST("/transactions/:id/revert", h.Revert)
+		v1.GET("/spenders/:id/transactions/search", h.Search)
+		v1.GET("/spenders/:id/transactions/export", h.Export)
+		v1.GET("/spenders/:id/statement", h.Statement)
+		v1.POST("/spenders/:id/transactions/import", h.Import)
+	}
+
+	{
+		h := recurring.New(cfg.FeatureFlag, db)
+		v1.GET("/spenders/:id/recurring", h.GetBySpenderID)
+		v1.POST("/spenders/:id/recurring", h.Create)
+		v1.PUT("/recurring/:id", h.Update)
+		v1.DELETE("/recurring/:id", h.Delete)
+		v1.GET("/recurring/:id/preview", h.Preview)
+	}
+
+	{
+		h := wallet.New(cfg.FeatureFlag, db, &transaction.Postgres{Db: db})
+		v1.POST("/wallets", h.Create)
+		v1.GET("/wallets/:id", h.GetByID)
+		v1.GET("/spenders/:id/wallets", h.GetBySpenderID)
+		v1.PUT("/wallets/:id/members/:spender_id", h.UpdateMember)
+		v1.DELETE("/wallets/:id/members/:spender_id", h.RemoveMember)
+		v1.POST("/wallets/:id/invitations", h.Invite)
+		v1.POST("/wallets/invitations/:token/accept", h.Accept)
+		v1.GET("/wallets/:id/summary", h.GetSummary)
+		v1.GET("/wallets/:id/summary/categories", h.GetCategorySummary)
+	}
+
+	{
+		h := balance.New(cfg.FeatureFlag, db)
+		v1.GET("/spenders/:id/balances", h.GetBySpenderID)
+		v1.POST("/spenders/:id/settle-up", h.SettleUp)
+		v1.POST("/balances/simplify", h.Simplify)
+	}
+
+	{
+		h := account.New(cfg.FeatureFlag, db)
+		v1.POST("/spenders/:id/accounts", h.Create)
+		v1.GET("/spenders/:id/accounts", h.GetBySpenderID)
+		v1.GET("/accounts/:id", h.GetByID)
+		v1.PUT("/accounts/:id", h.Update)
+		v1.GET("/accounts/:id/transactions", h.GetEntries)
+	}
+
+	{
+		h := importer.New(cfg.FeatureFlag, db)
+		v1.POST("/spenders/:id/transactions/import/statement", h.Import)
+	}
+
+	{
+		h := changefeed.New(cfg.FeatureFlag, db)
+		v1.GET("/spenders/:id/changes", h.Changes)
+		v1.POST("/spenders/:id/changes", h.Push)
+	}
+
+	{
+		h := tag.New(cfg.FeatureFlag, db)
+		v1.POST("/spenders/:id/tags", h.Create)
+		v1.GET("/spenders/:id/tags", h.GetBySpenderID)
+		v1.GET("/spenders/:id/tags/summary", h.GetSummary)
+		v1.PUT("/tags/:id", h.Update)
+		v1.DELETE("/tags/:id", h.Delete)
+		v1.GET("/transactions/:id/tags", h.GetByTransaction)
+		v1.PUT("/transactions/:id/tags", h.SetOnTransaction)
+	}
+
+	{
+		h := merchant.New(cfg.FeatureFlag, db)
+		v1.GET("/merchants", h.GetAll)
+		v1.POST("/merchants", h.Create)
+		v1.POST("/merchants/:id/aliases", h.AddAlias)
+		v1.POST("/spenders/:id/merchant-rules", h.CreateRule)
+		v1.GET("/spenders/:id/merchant-rules", h.GetRules)
+		v1.POST("/spenders/:id/merchant-rules/test", h.TestRule)
+		v1.PUT("/merchant-rules/:id", h.UpdateRule)
+		v1.DELETE("/merchant-rules/:id", h.DeleteRule)
+	}
+
+	{
+		h := suggest.New(cfg.FeatureFlag, db)
+		v1.POST("/spenders/:id/transactions/suggest-category", h.SuggestCategory)
+	}
+
+	admins := v1.Group("/admin", admin.Auth(cfg.Admin))
+
+	{
+		h := admin.New(cfg.FeatureFlag, db)
+		admins.GET("/transactions", h.ListTransactions)
+		admins.POST("/transactions/bulk", h.Bulk)
+	}
+
+	{
+		h := audit.New(cfg.FeatureFlag, db)
+		admins.GET("/audit", h.Search)
+		v1.GET("/transactions/:id/history", h.TransactionHistory)
+	}
+
+	{
+		h := dashboard.New(cfg.FeatureFlag, db)
+		v1.GET("/spenders/:id/dashboard", h.Get)
 	}
 
 	return &Server{e}
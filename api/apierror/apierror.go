@@ -0,0 +1,174 @@
+// Package apierror writes every error response as an RFC 7807 problem
+// document. Handlers write theirs with Respond, Invalid and Internal, errors
+// returned to echo go through Handler. A server error never carries its
+// cause, the logs have it under the request id.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+// internalDetail is all a client learns of a server error.
+const internalDetail = "Please check server logs"
+
+// Problem is the body of an error response.
+type Problem struct {
+	// Type is about:blank, the status says what kind of problem it is
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// Extensions are the members of a problem type of its own, like the
+	// outcome of every item of a rejected batch.
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON puts the extension members next to the standard ones, which
+// win a clash.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	b, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	members := map[string]json.RawMessage{}
+	for k, v := range p.Extensions {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		members[k] = raw
+	}
+	if err := json.Unmarshal(b, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// FieldError is what is wrong with one field of the request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Field is a validation error on field, message is the whole sentence the
+// client gets as detail.
+func Field(field, message string) error {
+	return &FieldError{Field: field, Message: message}
+}
+
+// Respond writes a problem with status and detail.
+func Respond(c echo.Context, status int, detail string) error {
+	return write(c, Problem{Status: status, Detail: detail})
+}
+
+// RespondWith writes a problem with status and detail that carries ext as
+// extension members.
+func RespondWith(c echo.Context, status int, detail string, ext map[string]any) error {
+	return write(c, Problem{Status: status, Detail: detail, Extensions: ext})
+}
+
+// Invalid writes a 400 for err, a bind, parse or validation error. The field
+// errors in it, also those of a JSON value of the wrong type, are listed.
+func Invalid(c echo.Context, err error) error {
+	detail := err.Error()
+	var herr *echo.HTTPError
+	if errors.As(err, &herr) {
+		detail = fmt.Sprint(herr.Message)
+	}
+	return write(c, Problem{Status: http.StatusBadRequest, Detail: detail, Errors: fieldErrors(err)})
+}
+
+// Internal writes a 500, the handler logs the cause.
+func Internal(c echo.Context) error {
+	return write(c, Problem{Status: http.StatusInternalServerError, Detail: internalDetail})
+}
+
+// Write writes the problem of herr, an error from a helper that decided the
+// status. The internal error of a server error stays out of the response.
+func Write(c echo.Context, herr *echo.HTTPError) error {
+	if herr.Code >= http.StatusInternalServerError {
+		return Internal(c)
+	}
+	if err, ok := herr.Message.(error); ok {
+		return write(c, Problem{Status: herr.Code, Detail: err.Error(), Errors: fieldErrors(err)})
+	}
+	return write(c, Problem{Status: herr.Code, Detail: fmt.Sprint(herr.Message), Errors: fieldErrors(herr.Internal)})
+}
+
+// Handler is the echo HTTPErrorHandler for the errors handlers and
+// middleware return, like echo's own not found and bind errors.
+func Handler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var herr *echo.HTTPError
+	if !errors.As(err, &herr) {
+		herr = echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+	}
+	if herr.Code >= http.StatusInternalServerError {
+		mlog.L(c).Error("unhandled error", zap.Error(err))
+	}
+	if err := Write(c, herr); err != nil {
+		mlog.L(c).Error("write error response", zap.Error(err))
+	}
+}
+
+// write fills in the members p gets from its status and the request.
+func write(c echo.Context, p Problem) error {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.RequestID = mlog.ParentID(c)
+	// echo's own errors say no more than the title
+	if p.Detail == p.Title {
+		p.Detail = ""
+	}
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(p.Status)
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEProblemJSON)
+	return c.JSON(p.Status, p)
+}
+
+// fieldErrors are the field errors in err, joined errors each add theirs.
+func fieldErrors(err error) []FieldError {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var fields []FieldError
+		for _, err := range joined.Unwrap() {
+			fields = append(fields, fieldErrors(err)...)
+		}
+		return fields
+	}
+
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return []FieldError{*fe}
+	}
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) && te.Field != "" {
+		return []FieldError{{Field: te.Field, Message: te.Field + " must be " + te.Type.String()}}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContext(method, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestRespond(t *testing.T) {
+	t.Run("problem with the detail", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "")
+		c.Set("parent-id", "req-1")
+
+		err := Respond(c, http.StatusNotFound, "spender not found")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "spender not found", "request_id": "req-1"}`, rec.Body.String())
+	})
+
+	t.Run("no body for HEAD", func(t *testing.T) {
+		c, rec := newContext(http.MethodHead, "")
+
+		err := Respond(c, http.StatusNotFound, "spender not found")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
+
+func TestRespondWith(t *testing.T) {
+	c, rec := newContext(http.MethodPost, "")
+
+	err := RespondWith(c, http.StatusUnprocessableEntity, "1 of 2 items are invalid", map[string]any{"failed": 1, "status": 200})
+
+	assert.NoError(t, err)
+	assert.Equal(t, MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.JSONEq(t, `{"type": "about:blank", "title": "Unprocessable Entity", "status": 422, "detail": "1 of 2 items are invalid", "failed": 1}`, rec.Body.String())
+}
+
+func TestInvalid(t *testing.T) {
+	t.Run("field of the wrong type in the body", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"amount": "ten"}`)
+		var body struct {
+			Amount float64 `json:"amount"`
+		}
+
+		err := Invalid(c, c.Bind(&body))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"errors":[{"field":"amount","message":"amount must be float64"}]`)
+	})
+
+	t.Run("field errors joined", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "")
+
+		err := Invalid(c, errors.Join(Field("name", "name is required"), Field("email", "email must be a valid address")))
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "name is required\nemail must be a valid address",
+			"errors": [{"field": "name", "message": "name is required"}, {"field": "email", "message": "email must be a valid address"}]}`, rec.Body.String())
+	})
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("client error keeps the message", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "")
+
+		err := Write(c, echo.NewHTTPError(http.StatusBadRequest, Field("splits", "sum of splits must equal the transaction amount")))
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "sum of splits must equal the transaction amount",
+			"errors": [{"field": "splits", "message": "sum of splits must equal the transaction amount"}]}`, rec.Body.String())
+	})
+
+	t.Run("server error hides the cause", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "")
+
+		err := Write(c, echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch transactions").SetInternal(errors.New(`pq: relation "transaction" does not exist`)))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Internal Server Error", "status": 500, "detail": "Please check server logs"}`, rec.Body.String())
+	})
+}
+
+func TestHandler(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = Handler
+	e.GET("/fail", func(c echo.Context) error {
+		return errors.New(`pq: duplicate key value violates unique constraint "spender_email_key"`)
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Not Found", "status": 404}`, rec.Body.String())
+	})
+
+	t.Run("error returned by a handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "pq:")
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Internal Server Error", "status": 500, "detail": "Please check server logs"}`, rec.Body.String())
+	})
+}
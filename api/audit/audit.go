@@ -0,0 +1,134 @@
+// Package audit says who made the changes the audit_log trigger records.
+// The trigger logs every write to an audited table in the DB transaction
+// that made it, a transaction begun with BeginTx also carries the actor and
+// the request id for it.
+//
+// The API does not sign anyone in, the gateway in front of it does. The
+// gateway checks the session or the API key and forwards who the caller
+// is in X-Spender-ID or X-API-Key along with the secret both share in
+// X-Gateway-Secret. Without that secret the headers are ignored, anybody
+// could set them.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+)
+
+// Actor types.
+const (
+	Spender   = "spender"
+	Admin     = "admin"
+	APIKey    = "api_key"
+	System    = "system"
+	Anonymous = "anonymous"
+)
+
+// AttachStmt is how BeginTx names the actor and the request of a DB
+// transaction. The settings are local to it, they can't leak to the next
+// user of a pooled connection.
+const AttachStmt = `SELECT set_config('audit.actor_type', $1, true), set_config('audit.actor_id', $2, true), set_config('audit.request_id', $3, true)`
+
+// Actor is who made a change. An API key is known by a digest, never by
+// the key itself.
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type ctxKey struct{}
+
+type attribution struct {
+	actor     Actor
+	requestID string
+}
+
+// WithActor returns a context whose DB transactions are logged as made by
+// a under requestID.
+func WithActor(ctx context.Context, a Actor, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, attribution{a, requestID})
+}
+
+// ActorOf is the actor of ctx, anonymous when none was set.
+func ActorOf(ctx context.Context) Actor {
+	if at, ok := ctx.Value(ctxKey{}).(attribution); ok {
+		return at.actor
+	}
+	return Actor{Type: Anonymous}
+}
+
+// Middleware reads the actor of the request from X-Spender-ID, set by the
+// gateway for signed in spenders, or X-API-Key, the key the gateway
+// verified, and keeps it with the request's parent-id. They count only
+// when X-Gateway-Secret is gatewaySecret, any other request is anonymous,
+// and so is every request while gatewaySecret is empty. It goes after the
+// mlog middleware. Routes with their own authentication, like admin,
+// overwrite the actor with SetActor.
+func Middleware(gatewaySecret string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			a := Actor{Type: Anonymous}
+			if fromGateway(c.Request().Header.Get("X-Gateway-Secret"), gatewaySecret) {
+				if id := strings.TrimSpace(c.Request().Header.Get("X-Spender-ID")); id != "" {
+					a = Actor{Type: Spender, ID: id}
+				} else if key := c.Request().Header.Get("X-API-Key"); key != "" {
+					sum := sha256.Sum256([]byte(key))
+					a = Actor{Type: APIKey, ID: hex.EncodeToString(sum[:8])}
+				}
+			}
+			SetActor(c, a)
+			return next(c)
+		}
+	}
+}
+
+func fromGateway(sent, secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(secret)) == 1
+}
+
+// SetActor makes a the actor of the rest of the request.
+func SetActor(c echo.Context, a Actor) {
+	req := c.Request()
+	c.SetRequest(req.WithContext(WithActor(req.Context(), a, mlog.ParentID(c))))
+}
+
+// BeginTx starts a DB transaction whose changes are logged as made by the
+// actor of ctx.
+func BeginTx(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	at, ok := ctx.Value(ctxKey{}).(attribution)
+	if !ok {
+		at.actor = Actor{Type: Anonymous}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, AttachStmt, at.actor.Type, at.actor.ID, at.requestID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// InTx runs fn in a DB transaction begun with BeginTx and commits it when
+// fn succeeds.
+func InTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := BeginTx(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware(t *testing.T) {
+	for name, tc := range map[string]struct {
+		headers map[string]string
+		want    Actor
+	}{
+		"signed in spender":       {map[string]string{"X-Gateway-Secret": "g4teway", "X-Spender-ID": "7"}, Actor{Type: Spender, ID: "7"}},
+		"api key by a digest":     {map[string]string{"X-Gateway-Secret": "g4teway", "X-API-Key": "k3y"}, Actor{Type: APIKey, ID: "a49b1287870c10a7"}},
+		"nobody said who":         {map[string]string{"X-Gateway-Secret": "g4teway"}, Actor{Type: Anonymous}},
+		"not through the gateway": {map[string]string{"X-Spender-ID": "7"}, Actor{Type: Anonymous}},
+		"wrong gateway secret":    {map[string]string{"X-Gateway-Secret": "guess", "X-Spender-ID": "7"}, Actor{Type: Anonymous}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			var got Actor
+			err := Middleware("g4teway")(func(c echo.Context) error {
+				got = ActorOf(c.Request().Context())
+				return nil
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMiddlewareWithoutGateway(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Gateway-Secret", "")
+	req.Header.Set("X-Spender-ID", "7")
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	var got Actor
+	err := Middleware("")(func(c echo.Context) error {
+		got = ActorOf(c.Request().Context())
+		return nil
+	})(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Actor{Type: Anonymous}, got)
+}
+
+func TestBeginTx(t *testing.T) {
+	t.Run("name the actor and request of the DB transaction", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Parent-ID", "req-1")
+		c := e.NewContext(req, httptest.NewRecorder())
+		_ = mlog.Middleware(zap.NewNop())(func(c echo.Context) error { return nil })(c)
+		SetActor(c, Actor{Type: Admin, ID: "ops"})
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(AttachStmt).WithArgs("admin", "ops", "req-1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		tx, err := BeginTx(c.Request().Context(), db)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, tx)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("anonymous without an actor", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(AttachStmt).WithArgs("anonymous", "", "").WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		_, err := BeginTx(context.Background(), db)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,20 @@
+// Package audittest has the sqlmock expectations of writes made through
+// audit.BeginTx.
+package audittest
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+)
+
+// Anonymous is the actor of a request that didn't come through the gateway,
+// as in most handler tests.
+var Anonymous = audit.Actor{Type: audit.Anonymous}
+
+// ExpectBegin expects a DB transaction begun with audit.BeginTx for actor,
+// outside of any request. The mock must match queries with
+// sqlmock.QueryMatcherEqual.
+func ExpectBegin(mock sqlmock.Sqlmock, actor audit.Actor) {
+	mock.ExpectBegin()
+	mock.ExpectExec(audit.AttachStmt).WithArgs(actor.Type, actor.ID, "").WillReturnResult(sqlmock.NewResult(0, 0))
+}
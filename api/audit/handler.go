@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	dateLayout   = "2006-01-02"
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+const (
+	entryColumns = `id, actor_type, actor_id, action, entity_type, entity_id, before, after, request_id, created_at`
+	searchStmt   = `SELECT ` + entryColumns + ` FROM audit_log WHERE %s ORDER BY id DESC LIMIT $%d`
+	// splits, shares and tags are logged under their transaction
+	historyStmt = `SELECT ` + entryColumns + ` FROM audit_log
+		WHERE entity_type IN ('transaction', 'transaction_split', 'transaction_share', 'transaction_tag') AND entity_id = $1 ORDER BY id`
+	txExistsStmt = `SELECT EXISTS (SELECT 1 FROM "transaction" WHERE id = $1)`
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+// Entry is one logged change. Before and After hold the whole row on create
+// and delete, only the fields that changed on update.
+type Entry struct {
+	ID         int64           `json:"id"`
+	Actor      Actor           `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// Next is the before cursor of the following page, null on the last
+	Next *int64 `json:"next"`
+}
+
+// GET /api/v1/admin/audit?actor_type=&actor_id=&entity_type=&entity_id=&action=&request_id=&from=&to=&before=&limit=
+// Latest first, pages follow with before set to the previous page's next.
+func (h handler) Search(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var (
+		where = "TRUE"
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where += fmt.Sprintf(" AND "+cond, len(args))
+	}
+	for _, col := range []string{"actor_type", "actor_id", "entity_type", "entity_id", "action", "request_id"} {
+		if v := c.QueryParam(col); v != "" {
+			add(col+" = $%d", v)
+		}
+	}
+	for _, p := range []struct{ name, cond string }{{"from", "created_at >= $%d"}, {"to", "created_at < $%d"}} {
+		s := c.QueryParam(p.name)
+		if s == "" {
+			continue
+		}
+		d, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return apierror.Respond(c, http.StatusBadRequest, p.name+" must be a date like 2024-01-31")
+		}
+		if p.name == "to" {
+			d = d.AddDate(0, 0, 1)
+		}
+		add(p.cond, d)
+	}
+	if s := c.QueryParam("before"); s != "" {
+		before, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return apierror.Respond(c, http.StatusBadRequest, "invalid before cursor")
+		}
+		add("id < $%d", before)
+	}
+	limit := defaultLimit
+	if s := c.QueryParam("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 {
+			return apierror.Respond(c, http.StatusBadRequest, "limit must be a positive number")
+		}
+		limit = min(limit, maxLimit)
+	}
+
+	// one more than asked tells whether there is a next page
+	entries, err := h.entries(ctx, fmt.Sprintf(searchStmt, where, len(args)+1), append(args, limit+1)...)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	page := Page{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.Next = &page.Entries[limit-1].ID
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// GET /api/v1/transactions/:id/history
+// Every change to the transaction and its splits, shares and tags, oldest
+// first. A transaction not changed since the audit log was added has none.
+func (h handler) TransactionHistory(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid transaction id")
+	}
+
+	entries, err := h.entries(ctx, historyStmt, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if len(entries) == 0 {
+		var ok bool
+		if err := h.db.QueryRowContext(ctx, txExistsStmt, id).Scan(&ok); err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if !ok {
+			return apierror.Respond(c, http.StatusNotFound, "transaction not found")
+		}
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (h handler) entries(ctx context.Context, query string, args ...any) ([]Entry, error) {
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var (
+			e             Entry
+			before, after []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Actor.Type, &e.Actor.ID, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		// a NULL column stays null in JSON
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var entryRows = []string{"id", "actor_type", "actor_id", "action", "entity_type", "entity_id", "before", "after", "request_id", "created_at"}
+
+func newContext(target string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestSearch(t *testing.T) {
+	at := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+
+	t.Run("search the log latest first with a cursor to the next page", func(t *testing.T) {
+		c, rec := newContext("/?actor_type=admin&entity_type=transaction&to=2024-05-31&before=90&limit=2")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(fmt.Sprintf(searchStmt, "TRUE AND actor_type = $1 AND entity_type = $2 AND created_at < $3 AND id < $4", 5)).
+			WithArgs("admin", "transaction", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), int64(90), 3).
+			WillReturnRows(sqlmock.NewRows(entryRows).
+				AddRow(89, "admin", "ops", "update", "transaction", "4", []byte(`{"amount": 100}`), []byte(`{"amount": 120}`), "req-1", at).
+				AddRow(88, "admin", "ops", "delete", "transaction", "5", []byte(`{"deleted_at": null}`), []byte(`{"deleted_at": "2024-05-03T12:00:00Z"}`), "req-1", at).
+				AddRow(87, "admin", "ops", "create", "transaction", "6", nil, []byte(`{"id": 6}`), "req-0", at))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Search(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"entries": [
+				{"id": 89, "actor": {"type": "admin", "id": "ops"}, "action": "update", "entity_type": "transaction", "entity_id": "4",
+					"before": {"amount": 100}, "after": {"amount": 120}, "request_id": "req-1", "created_at": "2024-05-03T12:00:00Z"},
+				{"id": 88, "actor": {"type": "admin", "id": "ops"}, "action": "delete", "entity_type": "transaction", "entity_id": "5",
+					"before": {"deleted_at": null}, "after": {"deleted_at": "2024-05-03T12:00:00Z"}, "request_id": "req-1", "created_at": "2024-05-03T12:00:00Z"}
+			],
+			"next": 88
+		}`, rec.Body.String())
+	})
+
+	t.Run("search failed on bad date", func(t *testing.T) {
+		c, rec := newContext("/?from=May")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Search(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestTransactionHistory(t *testing.T) {
+	t.Run("get every change of the transaction oldest first", func(t *testing.T) {
+		c, rec := newContext("/")
+		c.SetParamNames("id")
+		c.SetParamValues("4")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		at := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(historyStmt).WithArgs("4").WillReturnRows(sqlmock.NewRows(entryRows).
+			AddRow(10, "spender", "1", "create", "transaction", "4", nil, []byte(`{"id": 4, "amount": 100}`), "req-0", at).
+			AddRow(11, "spender", "1", "create", "transaction_split", "4", nil, []byte(`{"id": 1, "transaction_id": 4, "amount": 40}`), "req-0", at).
+			AddRow(89, "admin", "ops", "update", "transaction", "4", []byte(`{"amount": 100}`), []byte(`{"amount": 120}`), "req-1", at))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.TransactionHistory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+			{"id": 10, "actor": {"type": "spender", "id": "1"}, "action": "create", "entity_type": "transaction", "entity_id": "4",
+				"before": null, "after": {"id": 4, "amount": 100}, "request_id": "req-0", "created_at": "2024-05-03T12:00:00Z"},
+			{"id": 11, "actor": {"type": "spender", "id": "1"}, "action": "create", "entity_type": "transaction_split", "entity_id": "4",
+				"before": null, "after": {"id": 1, "transaction_id": 4, "amount": 40}, "request_id": "req-0", "created_at": "2024-05-03T12:00:00Z"},
+			{"id": 89, "actor": {"type": "admin", "id": "ops"}, "action": "update", "entity_type": "transaction", "entity_id": "4",
+				"before": {"amount": 100}, "after": {"amount": 120}, "request_id": "req-1", "created_at": "2024-05-03T12:00:00Z"}
+		]`, rec.Body.String())
+	})
+
+	t.Run("get history failed on unknown transaction", func(t *testing.T) {
+		c, rec := newContext("/")
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(historyStmt).WithArgs("9").WillReturnRows(sqlmock.NewRows(entryRows))
+		mock.ExpectQuery(txExistsStmt).WithArgs("9").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.TransactionHistory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no history for a transaction older than the log", func(t *testing.T) {
+		c, rec := newContext("/")
+		c.SetParamNames("id")
+		c.SetParamValues("2")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(historyStmt).WithArgs("2").WillReturnRows(sqlmock.NewRows(entryRows))
+		mock.ExpectQuery(txExistsStmt).WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.TransactionHistory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,107 @@
+package balance
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Balance is what another spender owes, positive, or is owed, negative.
+type Balance struct {
+	SpenderID int64   `json:"spender_id"`
+	Amount    float64 `json:"amount"`
+}
+
+type Summary struct {
+	SpenderID int64     `json:"spender_id"`
+	Balances  []Balance `json:"balances"`
+	OwedToYou float64   `json:"owed_to_you"`
+	YouOwe    float64   `json:"you_owe"`
+	Net       float64   `json:"net"`
+}
+
+// Transfer is one payment that settles debts.
+type Transfer struct {
+	FromSpenderID int64   `json:"from_spender_id"`
+	ToSpenderID   int64   `json:"to_spender_id"`
+	Amount        float64 `json:"amount"`
+}
+
+type Settlement struct {
+	ID            int64     `json:"id"`
+	FromSpenderID int64     `json:"from_spender_id"`
+	ToSpenderID   int64     `json:"to_spender_id"`
+	Amount        float64   `json:"amount"`
+	Note          string    `json:"note"`
+	Date          time.Time `json:"date"`
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func summarize(spenderID int64, balances []Balance) Summary {
+	s := Summary{SpenderID: spenderID, Balances: balances}
+	var owed, owe int64
+	for _, b := range balances {
+		if b.Amount > 0 {
+			owed += cents(b.Amount)
+		} else {
+			owe -= cents(b.Amount)
+		}
+	}
+	s.OwedToYou = float64(owed) / 100
+	s.YouOwe = float64(owe) / 100
+	s.Net = float64(owed-owe) / 100
+	return s
+}
+
+// Simplify returns transfers that settle the given net positions, positive
+// for spenders who are owed money, with at most n-1 payments. It greedily
+// matches the largest debtor with the largest creditor.
+func Simplify(net map[int64]float64) []Transfer {
+	type position struct {
+		id     int64
+		amount int64
+	}
+
+	var creditors, debtors []position
+	for id, amount := range net {
+		switch c := cents(amount); {
+		case c > 0:
+			creditors = append(creditors, position{id, c})
+		case c < 0:
+			debtors = append(debtors, position{id, -c})
+		}
+	}
+
+	byAmount := func(p []position) {
+		sort.Slice(p, func(i, j int) bool {
+			if p[i].amount != p[j].amount {
+				return p[i].amount > p[j].amount
+			}
+			return p[i].id < p[j].id
+		})
+	}
+	byAmount(creditors)
+	byAmount(debtors)
+
+	transfers := []Transfer{}
+	for len(creditors) > 0 && len(debtors) > 0 {
+		c, d := &creditors[0], &debtors[0]
+		amount := min(c.amount, d.amount)
+		transfers = append(transfers, Transfer{FromSpenderID: d.id, ToSpenderID: c.id, Amount: float64(amount) / 100})
+
+		c.amount -= amount
+		d.amount -= amount
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+		byAmount(creditors)
+		byAmount(debtors)
+	}
+	return transfers
+}
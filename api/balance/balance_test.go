@@ -0,0 +1,57 @@
+package balance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimplify(t *testing.T) {
+	t.Run("chain of debts becomes a single transfer", func(t *testing.T) {
+		// 1 owes 2 100, 2 owes 3 100
+		net := map[int64]float64{1: -100, 2: 0, 3: 100}
+
+		transfers := Simplify(net)
+
+		assert.Equal(t, []Transfer{{FromSpenderID: 1, ToSpenderID: 3, Amount: 100}}, transfers)
+	})
+
+	t.Run("dinner for three paid by one", func(t *testing.T) {
+		net := map[int64]float64{1: 200, 2: -100, 3: -100}
+
+		transfers := Simplify(net)
+
+		assert.Equal(t, []Transfer{
+			{FromSpenderID: 2, ToSpenderID: 1, Amount: 100},
+			{FromSpenderID: 3, ToSpenderID: 1, Amount: 100},
+		}, transfers)
+	})
+
+	t.Run("uses at most n-1 transfers", func(t *testing.T) {
+		net := map[int64]float64{1: 50.5, 2: 30, 3: -20.25, 4: -40.25, 5: -20}
+
+		transfers := Simplify(net)
+
+		assert.LessOrEqual(t, len(transfers), 4)
+		settled := map[int64]int64{}
+		for _, tr := range transfers {
+			settled[tr.FromSpenderID] += cents(tr.Amount)
+			settled[tr.ToSpenderID] -= cents(tr.Amount)
+		}
+		for id, amount := range net {
+			assert.Equal(t, -cents(amount), settled[id], "spender %d", id)
+		}
+	})
+
+	t.Run("nothing to settle", func(t *testing.T) {
+		assert.Equal(t, []Transfer{}, Simplify(map[int64]float64{1: 0, 2: 0}))
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	s := summarize(1, []Balance{{SpenderID: 2, Amount: 150}, {SpenderID: 3, Amount: -40.5}})
+
+	assert.Equal(t, 150.0, s.OwedToYou)
+	assert.Equal(t, 40.5, s.YouOwe)
+	assert.Equal(t, 109.5, s.Net)
+}
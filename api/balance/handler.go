@@ -0,0 +1,222 @@
+package balance
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	// positive amounts are owed to the spender by the other spender
+	balancesStmt = `SELECT other_id, SUM(amount) AS amount FROM (
+		SELECT debtor_id AS other_id, amount FROM debt_ledger WHERE creditor_id = $1
+		UNION ALL
+		SELECT creditor_id, -amount FROM debt_ledger WHERE debtor_id = $1
+	) l GROUP BY other_id HAVING SUM(amount) <> 0 ORDER BY other_id`
+
+	pairStmt = `SELECT COALESCE(SUM(CASE WHEN creditor_id = $1 THEN -amount ELSE amount END), 0) FROM debt_ledger WHERE (debtor_id = $1 AND creditor_id = $2) OR (debtor_id = $2 AND creditor_id = $1)`
+
+	groupNetStmt = `SELECT spender_id, SUM(amount) FROM (
+		SELECT creditor_id AS spender_id, amount FROM debt_ledger WHERE debtor_id = ANY($1::int[]) AND creditor_id = ANY($1::int[])
+		UNION ALL
+		SELECT debtor_id, -amount FROM debt_ledger WHERE debtor_id = ANY($1::int[]) AND creditor_id = ANY($1::int[])
+	) n GROUP BY spender_id`
+
+	// the share lock keeps a spender from being deleted before the
+	// settlement commits
+	activeStmt  = `SELECT id FROM spender WHERE id = ANY($1::int[]) AND deleted_at IS NULL FOR SHARE`
+	cSettleStmt = `INSERT INTO settlement (from_spender_id, to_spender_id, amount, note) VALUES ($1, $2, $3, $4) RETURNING id, date;`
+)
+
+// GET /api/v1/spenders/:id/balances
+func (h handler) GetBySpenderID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	rows, err := h.db.QueryContext(ctx, balancesStmt, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	balances := []Balance{}
+	for rows.Next() {
+		var b Balance
+		if err := rows.Scan(&b.SpenderID, &b.Amount); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, summarize(id, balances))
+}
+
+type simplifyReqBody struct {
+	SpenderIDs []int64 `json:"spender_ids"`
+}
+
+// POST /api/v1/balances/simplify
+// Only debts between spenders of the group are taken into account.
+func (h handler) Simplify(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var body simplifyReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if len(body.SpenderIDs) < 2 {
+		return apierror.Respond(c, http.StatusBadRequest, "at least two spender_ids are required")
+	}
+
+	rows, err := h.db.QueryContext(ctx, groupNetStmt, pq.Array(body.SpenderIDs))
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	net := map[int64]float64{}
+	for rows.Next() {
+		var id int64
+		var amount float64
+		if err := rows.Scan(&id, &amount); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		net[id] = amount
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, map[string][]Transfer{"transfers": Simplify(net)})
+}
+
+// pairBalance is what the spender owes to the other spender, negative when
+// the other spender owes them.
+func pairBalance(ctx context.Context, tx *sql.Tx, spenderID, otherID int64) (float64, error) {
+	var owed float64
+	err := tx.QueryRowContext(ctx, pairStmt, spenderID, otherID).Scan(&owed)
+	return owed, err
+}
+
+// activeSpenders is which of ids are spenders not deleted, they stay so
+// until tx ends.
+func activeSpenders(ctx context.Context, tx *sql.Tx, ids ...int64) (map[int64]bool, error) {
+	rows, err := tx.QueryContext(ctx, activeStmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	active := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		active[id] = true
+	}
+	return active, rows.Err()
+}
+
+// POST /api/v1/spenders/:id/settle-up
+// Records a payment from the spender to to_spender_id, without an amount
+// the whole debt to that spender is settled.
+func (h handler) SettleUp(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var s Settlement
+	if err := c.Bind(&s); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	s.FromSpenderID = id
+	if s.ToSpenderID <= 0 || s.ToSpenderID == id {
+		return apierror.Respond(c, http.StatusBadRequest, "to_spender_id must be another spender")
+	}
+	if s.Amount < 0 {
+		return apierror.Respond(c, http.StatusBadRequest, "amount must be greater than zero")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	active, err := activeSpenders(ctx, tx, id, s.ToSpenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !active[id] {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	if !active[s.ToSpenderID] {
+		return apierror.Respond(c, http.StatusBadRequest, "to_spender_id is not a spender")
+	}
+
+	if s.Amount == 0 {
+		owed, err := pairBalance(ctx, tx, id, s.ToSpenderID)
+		if err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if cents(owed) <= 0 {
+			return apierror.Respond(c, http.StatusConflict, "nothing to settle with this spender")
+		}
+		s.Amount = owed
+	}
+
+	if err := tx.QueryRowContext(ctx, cSettleStmt, s.FromSpenderID, s.ToSpenderID, s.Amount, s.Note).Scan(&s.ID, &s.Date); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("settle up successfully", zap.Int64("id", s.ID))
+	return c.JSON(http.StatusCreated, s)
+}
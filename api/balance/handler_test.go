@@ -0,0 +1,195 @@
+package balance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBalancesBySpenderID(t *testing.T) {
+	t.Run("get net balance with each other spender", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"other_id", "amount"}).AddRow(2, 150.0).AddRow(3, -40.0)
+		mock.ExpectQuery(balancesStmt).WithArgs(int64(1)).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"spender_id": 1, "balances": [{"spender_id": 2, "amount": 150}, {"spender_id": 3, "amount": -40}],
+			"owed_to_you": 150, "you_owe": 40, "net": 110}`, rec.Body.String())
+	})
+}
+
+func TestSettleUp(t *testing.T) {
+	t.Run("settle the whole debt when no amount is given", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_spender_id": 2, "note": "dinner"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		paid := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(activeStmt).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(2))
+		mock.ExpectQuery(pairStmt).WithArgs(int64(3), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"owed"}).AddRow(333.33))
+		mock.ExpectQuery(cSettleStmt).WithArgs(int64(3), int64(2), 333.33, "dinner").WillReturnRows(sqlmock.NewRows([]string{"id", "date"}).AddRow(5, paid))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SettleUp(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 5, "from_spender_id": 3, "to_spender_id": 2, "amount": 333.33, "note": "dinner", "date": "2024-05-02T00:00:00Z"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing owed to that spender", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_spender_id": 2}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(activeStmt).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(2))
+		mock.ExpectQuery(pairStmt).WithArgs(int64(3), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"owed"}).AddRow(-10.0))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SettleUp(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("cannot settle with yourself", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_spender_id": 3, "amount": 10}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.SettleUp(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("the paying spender is not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_spender_id": 2, "amount": 10}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(activeStmt).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SettleUp(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cannot settle with a deleted spender", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_spender_id": 2, "amount": 10}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(activeStmt).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SettleUp(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSimplifyHandler(t *testing.T) {
+	t.Run("suggest minimal transfers for a group", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"spender_ids": [1, 2, 3]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"spender_id", "sum"}).AddRow(1, -100.0).AddRow(2, 0.0).AddRow(3, 100.0)
+		mock.ExpectQuery(groupNetStmt).WithArgs(sqlmock.AnyArg()).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Simplify(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"transfers": [{"from_spender_id": 1, "to_spender_id": 3, "amount": 100}]}`, rec.Body.String())
+	})
+}
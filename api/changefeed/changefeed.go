@@ -0,0 +1,145 @@
+// Package changefeed lets offline clients fetch what changed since their
+// last sync and push the edits they made while offline.
+package changefeed
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/google/uuid"
+)
+
+const (
+	EntitySpender     = "spender"
+	EntityTransaction = "transaction"
+
+	OpUpsert = "upsert"
+	OpDelete = "delete"
+)
+
+// Change is one row as it is now. Deletes are tombstones without Data.
+type Change struct {
+	Entity    string          `json:"entity"`
+	ID        int64           `json:"id"`
+	Op        string          `json:"op"`
+	Version   int             `json:"version"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type Feed struct {
+	Changes []Change `json:"changes"`
+	Cursor  string   `json:"cursor"`
+	HasMore bool     `json:"has_more"`
+}
+
+// Edit is a transaction the client created, changed or deleted offline.
+// ID is empty for new transactions, which the client identifies with a
+// ClientID of its own so a retried push doesn't create them twice.
+// BaseVersion is the version the client started from and UpdatedAt when the
+// client made the edit.
+type Edit struct {
+	ID          *int64                         `json:"id"`
+	ClientID    string                         `json:"client_id"`
+	BaseVersion int                            `json:"base_version"`
+	UpdatedAt   time.Time                      `json:"updated_at"`
+	Deleted     bool                           `json:"deleted"`
+	Transaction transaction.TransactionReqBody `json:"transaction"`
+}
+
+const (
+	StatusCreated  = "created"
+	StatusApplied  = "applied"
+	StatusRejected = "rejected"
+	StatusNotFound = "not_found"
+	StatusInvalid  = "invalid"
+)
+
+// PushResult tells the client what happened to its edit. Conflict is set
+// when the server row moved on since BaseVersion; the newer edit wins and
+// Version is the server version either way.
+type PushResult struct {
+	Index    int    `json:"index"`
+	ID       int64  `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Version  int    `json:"version,omitempty"`
+	Conflict bool   `json:"conflict"`
+	Error    string `json:"error,omitempty"`
+}
+
+// position is where a client is in the feed, the writing DB transaction's
+// id first since sequence numbers aren't drawn in commit order.
+type position struct {
+	xid, seq int64
+}
+
+const (
+	cursorPrefix = "v2:"
+	// v1 cursors held a sequence number alone, they restart the feed
+	legacyPrefix = "v1:"
+)
+
+// encodeCursor hides the position so clients treat it as opaque.
+func encodeCursor(p position) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(p.xid, 10) + "." + strconv.FormatInt(p.seq, 10)))
+}
+
+func decodeCursor(cursor string) (position, error) {
+	if cursor == "" {
+		return position{}, nil
+	}
+	invalid := errors.New("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position{}, invalid
+	}
+	if strings.HasPrefix(string(raw), legacyPrefix) {
+		return position{}, nil
+	}
+	rest, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return position{}, invalid
+	}
+	xid, seq, ok := strings.Cut(rest, ".")
+	if !ok {
+		return position{}, invalid
+	}
+	var p position
+	if p.xid, err = strconv.ParseInt(xid, 10, 64); err != nil || p.xid < 0 {
+		return position{}, invalid
+	}
+	if p.seq, err = strconv.ParseInt(seq, 10, 64); err != nil || p.seq < 0 {
+		return position{}, invalid
+	}
+	return p, nil
+}
+
+func (e Edit) validate() error {
+	if e.Deleted {
+		if e.ID == nil {
+			return errors.New("id is required to delete")
+		}
+		return nil
+	}
+	if e.ID == nil {
+		if _, err := uuid.Parse(e.ClientID); err != nil {
+			return errors.New("client_id must be a UUID to create")
+		}
+	}
+	t := e.Transaction
+	if t.Date == "" {
+		return errors.New("date is required")
+	}
+	if t.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if t.TransactionType != "income" && t.TransactionType != "expense" {
+		return errors.New("transaction_type must be income or expense")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		p, err := decodeCursor(encodeCursor(position{xid: 731, seq: 42}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, position{xid: 731, seq: 42}, p)
+	})
+
+	t.Run("empty cursor starts from the beginning", func(t *testing.T) {
+		p, err := decodeCursor("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, position{}, p)
+	})
+
+	t.Run("sequence only cursors start from the beginning", func(t *testing.T) {
+		p, err := decodeCursor("djE6NDI")
+
+		assert.NoError(t, err)
+		assert.Equal(t, position{}, p)
+	})
+
+	t.Run("reject cursors the server did not issue", func(t *testing.T) {
+		for _, cursor := range []string{"42", "djI6NDI", "djI6LTEuMw", "bm9wZQ"} {
+			_, err := decodeCursor(cursor)
+
+			assert.EqualError(t, err, "invalid cursor", cursor)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package changefeed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 500
+
+	// one more row than asked tells whether there is another page. Only rows
+	// written before the oldest DB transaction still running are returned,
+	// one running now may commit a row that sorts before them.
+	changesStmt = `SELECT entity, id, xid::text::bigint, seq, version, updated_at, deleted, data FROM (
+		SELECT 'spender' AS entity, s.id, s.sync_xid AS xid, s.sync_seq AS seq, s.version, s.updated_at, s.deleted_at IS NOT NULL AS deleted,
+			CASE WHEN s.deleted_at IS NULL THEN json_build_object('id', s.id, 'name', s.name, 'email', s.email) END AS data
+		FROM spender s WHERE s.id = $1 AND (s.sync_xid, s.sync_seq) > ($2::text::xid8, $3)
+		UNION ALL
+		SELECT 'transaction', t.id, t.sync_xid, t.sync_seq, t.version, t.updated_at, t.deleted_at IS NOT NULL,
+			CASE WHEN t.deleted_at IS NULL THEN json_build_object('id', t.id, 'client_id', t.client_id, 'date', t.date, 'amount', t.amount, 'category', t.category,
+				'transaction_type', t.transaction_type, 'spender_id', t.spender_id, 'note', t.note, 'image_url', t.image_url,
+				'wallet_id', t.wallet_id, 'account_id', t.account_id, 'to_account_id', t.to_account_id) END
+		FROM transaction t WHERE t.spender_id = $1 AND (t.sync_xid, t.sync_seq) > ($2::text::xid8, $3)
+		UNION ALL
+		SELECT m.entity, m.entity_id, m.sync_xid, m.sync_seq, m.version, m.deleted_at, true, NULL
+		FROM sync_tombstone m WHERE m.spender_id = $1 AND (m.sync_xid, m.sync_seq) > ($2::text::xid8, $3)
+	) c WHERE xid < pg_snapshot_xmin(pg_current_snapshot()) ORDER BY xid, seq LIMIT $4`
+
+	// a deleted row is gone for the client too, the feed has its tombstone.
+	// Offline edits only carry the plain fields, a row that also has split
+	// lines, shares, accounts or a wallet is edited online where all of it
+	// is checked together.
+	lockStmt = `SELECT t.version, t.updated_at, t.transaction_type = 'transfer' OR t.wallet_id IS NOT NULL OR t.account_id IS NOT NULL OR t.to_account_id IS NOT NULL
+			OR EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id) OR EXISTS (SELECT 1 FROM transaction_share h WHERE h.transaction_id = t.id)
+		FROM transaction t WHERE t.id = $1 AND t.spender_id = $2 AND t.deleted_at IS NULL FOR UPDATE OF t`
+	// a create pushed again returns the row it made the first time
+	cStmt = `WITH created AS (
+			INSERT INTO transaction (date, amount, category, transaction_type, spender_id, note, image_url, client_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (spender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING RETURNING id, version)
+		SELECT id, version FROM created
+		UNION ALL
+		SELECT id, version FROM transaction WHERE spender_id = $5 AND client_id = $8 AND NOT EXISTS (SELECT 1 FROM created)`
+	uStmt      = `UPDATE transaction SET date = $1, amount = $2, category = $3, transaction_type = $4, note = $5, image_url = $6 WHERE id = $7 RETURNING version;`
+	deleteStmt = `UPDATE transaction SET deleted_at = now() WHERE id = $1 RETURNING version;`
+)
+
+// GET /api/v1/spenders/:id/changes?since=<cursor>&limit=100
+// Returns the spender and their transactions that changed after the cursor,
+// oldest change first, and tombstones for transactions moved to another
+// spender. A change shows once the DB transactions begun before it have
+// finished. Clients keep the returned cursor for the next call and call
+// again right away while has_more is true.
+func (h handler) Changes(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	since, err := decodeCursor(c.QueryParam("since"))
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+
+	rows, err := h.db.QueryContext(ctx, changesStmt, spenderID, since.xid, since.seq, limit+1)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	feed := Feed{Changes: []Change{}}
+	last := since
+	for rows.Next() {
+		var (
+			ch      Change
+			p       position
+			deleted bool
+			data    []byte
+		)
+		if err := rows.Scan(&ch.Entity, &ch.ID, &p.xid, &p.seq, &ch.Version, &ch.UpdatedAt, &deleted, &data); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if len(feed.Changes) == limit {
+			feed.HasMore = true
+			break
+		}
+
+		ch.Op = OpUpsert
+		if deleted {
+			ch.Op = OpDelete
+		} else {
+			ch.Data = data
+		}
+		feed.Changes = append(feed.Changes, ch)
+		last = p
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	feed.Cursor = encodeCursor(last)
+	return c.JSON(http.StatusOK, feed)
+}
+
+type pushReqBody struct {
+	Edits []Edit `json:"edits"`
+}
+
+// POST /api/v1/spenders/:id/changes
+// Applies the client's offline edits in one DB transaction. Creates pushed
+// again, e.g. after a timeout, report the transaction made the first time
+// by its client_id. An edit based
+// on an older version than the server's is a conflict: the one made last,
+// by updated_at, wins. Changing a transaction with split lines, shares,
+// accounts or a wallet is invalid offline, and a deleted one is not found.
+func (h handler) Push(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var body pushReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if len(body.Edits) == 0 || len(body.Edits) > maxLimit {
+		return apierror.Respond(c, http.StatusBadRequest, "a push needs between 1 and 500 edits")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	results := make([]PushResult, len(body.Edits))
+	for i, e := range body.Edits {
+		if results[i], err = apply(ctx, tx, spenderID, e); err != nil {
+			logger.Error("push error", zap.Int("index", i), zap.Error(err))
+			return apierror.Internal(c)
+		}
+		results[i].Index = i
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, map[string][]PushResult{"results": results})
+}
+
+// apply writes one edit. The server row is locked first so the version it
+// is compared with can't change before the write.
+func apply(ctx context.Context, tx *sql.Tx, spenderID int64, e Edit) (PushResult, error) {
+	if err := e.validate(); err != nil {
+		return PushResult{Status: StatusInvalid, Error: err.Error()}, nil
+	}
+	t := e.Transaction
+
+	if e.ID == nil {
+		r := PushResult{Status: StatusCreated}
+		err := tx.QueryRowContext(ctx, cStmt, t.Date, t.Amount, t.Category, t.TransactionType, spenderID, t.Note, t.ImageURL, e.ClientID).Scan(&r.ID, &r.Version)
+		return r, err
+	}
+
+	r := PushResult{ID: *e.ID}
+	var (
+		version   int
+		updatedAt time.Time
+		online    bool
+	)
+	err := tx.QueryRowContext(ctx, lockStmt, *e.ID, spenderID).Scan(&version, &updatedAt, &online)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.Status = StatusNotFound
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+
+	r.Conflict = e.BaseVersion != version
+	if r.Conflict && !e.UpdatedAt.After(updatedAt) {
+		r.Status = StatusRejected
+		r.Version = version
+		return r, nil
+	}
+
+	if online && !e.Deleted {
+		r.Status = StatusInvalid
+		r.Version = version
+		r.Error = "transaction has split lines, shares, accounts or a wallet, edit it online"
+		return r, nil
+	}
+
+	r.Status = StatusApplied
+	if e.Deleted {
+		err = tx.QueryRowContext(ctx, deleteStmt, *e.ID).Scan(&r.Version)
+	} else {
+		err = tx.QueryRowContext(ctx, uStmt, t.Date, t.Amount, t.Category, t.TransactionType, t.Note, t.ImageURL, *e.ID).Scan(&r.Version)
+	}
+	return r, err
+}
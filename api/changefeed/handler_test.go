@@ -0,0 +1,161 @@
+package changefeed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	changeColumns = []string{"entity", "id", "xid", "seq", "version", "updated_at", "deleted", "data"}
+	lockColumns   = []string{"version", "updated_at", "online"}
+)
+
+func TestChanges(t *testing.T) {
+	t.Run("upserts and tombstones in order with the next cursor", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?since="+encodeCursor(position{700, 10})+"&limit=2", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		at := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(changeColumns).
+			AddRow("transaction", 5, 701, 12, 2, at, false, []byte(`{"id": 5, "amount": 100}`)).
+			AddRow("transaction", 6, 702, 11, 3, at, true, nil).
+			AddRow("spender", 1, 702, 13, 2, at, false, []byte(`{"id": 1}`))
+		mock.ExpectQuery(changesStmt).WithArgs(int64(1), int64(700), int64(10), 3).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Changes(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"changes": [
+			{"entity": "transaction", "id": 5, "op": "upsert", "version": 2, "updated_at": "2024-06-01T00:00:00Z", "data": {"id": 5, "amount": 100}},
+			{"entity": "transaction", "id": 6, "op": "delete", "version": 3, "updated_at": "2024-06-01T00:00:00Z"}
+		], "cursor": "`+encodeCursor(position{702, 11})+`", "has_more": true}`, rec.Body.String())
+	})
+
+	t.Run("nothing changed keeps the cursor", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		cursor := encodeCursor(position{702, 12})
+		req := httptest.NewRequest(http.MethodGet, "/?since="+cursor, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(changesStmt).WithArgs(int64(1), int64(702), int64(12), defaultLimit+1).WillReturnRows(sqlmock.NewRows(changeColumns))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Changes(c)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"changes": [], "cursor": "`+cursor+`", "has_more": false}`, rec.Body.String())
+	})
+}
+
+func TestPush(t *testing.T) {
+	t.Run("apply, create and resolve conflicts by last writer", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		body := `{"edits": [
+			{"id": 5, "base_version": 2, "updated_at": "2024-06-02T10:00:00Z", "transaction": {"date": "2024-06-01", "amount": 120, "category": "food", "transaction_type": "expense"}},
+			{"client_id": "0b6f5c1e-3f7a-4d2b-9c1e-5a8d2f4e6b7c", "base_version": 0, "updated_at": "2024-06-02T10:00:00Z", "transaction": {"date": "2024-06-02", "amount": 50, "category": "coffee", "transaction_type": "expense"}},
+			{"base_version": 0, "updated_at": "2024-06-02T10:00:00Z", "transaction": {"date": "2024-06-02", "amount": 50, "category": "coffee", "transaction_type": "expense"}},
+			{"id": 6, "base_version": 1, "updated_at": "2024-06-02T10:00:00Z", "deleted": true},
+			{"id": 7, "base_version": 1, "updated_at": "2024-06-02T10:00:00Z", "deleted": true}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		before := time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)
+		after := time.Date(2024, 6, 2, 11, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(lockStmt).WithArgs(int64(5), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(2, before, false))
+		mock.ExpectQuery(uStmt).WithArgs("2024-06-01", 120.0, "food", "expense", "", "", int64(5)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-02", 50.0, "coffee", "expense", int64(1), "", "", "0b6f5c1e-3f7a-4d2b-9c1e-5a8d2f4e6b7c").WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(9, 1))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(6), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(4, before, true))
+		mock.ExpectQuery(deleteStmt).WithArgs(int64(6)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(5))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(7), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(2, after, false))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Push(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"results": [
+			{"index": 0, "id": 5, "status": "applied", "version": 3, "conflict": false},
+			{"index": 1, "id": 9, "status": "created", "version": 1, "conflict": false},
+			{"index": 2, "status": "invalid", "conflict": false, "error": "client_id must be a UUID to create"},
+			{"index": 3, "id": 6, "status": "applied", "version": 5, "conflict": true},
+			{"index": 4, "id": 7, "status": "rejected", "version": 2, "conflict": true}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("edits of rows with more than the plain fields or deleted ones are not applied", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		body := `{"edits": [
+			{"id": 5, "base_version": 2, "updated_at": "2024-06-02T10:00:00Z", "transaction": {"date": "2024-06-01", "amount": 120, "category": "food", "transaction_type": "expense"}},
+			{"id": 6, "base_version": 1, "updated_at": "2024-06-02T10:00:00Z", "transaction": {"date": "2024-06-01", "amount": 80, "category": "food", "transaction_type": "income"}}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		before := time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(lockStmt).WithArgs(int64(5), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(2, before, true))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(6), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Push(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"results": [
+			{"index": 0, "id": 5, "status": "invalid", "version": 2, "conflict": false, "error": "transaction has split lines, shares, accounts or a wallet, edit it online"},
+			{"index": 1, "id": 6, "status": "not_found", "conflict": false}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -14,6 +14,8 @@ type Config struct {
 	Database    Database
 	Server      Server
 	FeatureFlag FeatureFlag
+	Admin       Admin
+	Gateway     Gateway
 }
 
 func (c Config) PostgresURI() string {
@@ -28,6 +30,18 @@ type Database struct {
 	PostgresURI string `env:"DATABASE_POSTGRES_URI,required"`
 }
 
+// Admin guards the /admin routes, they are off while Token is empty.
+type Admin struct {
+	Token string `env:"ADMIN_TOKEN"`
+}
+
+// Gateway is the secret the gateway in front of the API sends in
+// X-Gateway-Secret. Only requests carrying it are trusted to say who the
+// caller is, with X-Spender-ID or X-API-Key. None are while Secret is empty.
+type Gateway struct {
+	Secret string `env:"GATEWAY_SECRET"`
+}
+
 type FeatureFlag struct {
 	EnableCreateSpender bool `env:"ENABLE_CREATE_SPENDER"`
 }
@@ -71,6 +85,16 @@ func parse(envPrefix string) (Config, error) {
 		return Config{}, errors.New("failed to parse feature flag config:" + err.Error())
 	}
 
+	admin := &Admin{}
+	if err := env.ParseWithOptions(admin, opts); err != nil {
+		return Config{}, errors.New("failed to parse admin config:" + err.Error())
+	}
+
+	gateway := &Gateway{}
+	if err := env.ParseWithOptions(gateway, opts); err != nil {
+		return Config{}, errors.New("failed to parse gateway config:" + err.Error())
+	}
+
 	port := Env("SERVER_PORT")
 	if port == "" {
 		port = "8080"
@@ -86,6 +110,12 @@ func parse(envPrefix string) (Config, error) {
 		FeatureFlag: FeatureFlag{
 			EnableCreateSpender: feats.EnableCreateSpender,
 		},
+		Admin: Admin{
+			Token: admin.Token,
+		},
+		Gateway: Gateway{
+			Secret: gateway.Secret,
+		},
 	}, nil
 }
 
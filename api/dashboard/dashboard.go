@@ -0,0 +1,101 @@
+// Package dashboard assembles a spender's home screen in one response. Each
+// section is queried on its own with its own deadline, a slow or failing
+// section is reported in Errors and leaves the others intact.
+package dashboard
+
+import (
+	"math"
+	"time"
+)
+
+// Sections of the dashboard, the keys of Dashboard.Errors.
+const (
+	CurrentMonth       = "current_month"
+	PreviousMonth      = "previous_month"
+	TopCategories      = "top_categories"
+	LargestExpenses    = "largest_expenses"
+	LatestTransactions = "latest_transactions"
+)
+
+type Totals struct {
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
+	Balance  float64 `json:"balance"`
+}
+
+// Comparison is how the current month moved against the previous one. The
+// percentages are left out when the previous month had nothing to compare
+// to.
+type Comparison struct {
+	Previous              Totals   `json:"previous"`
+	IncomeChange          float64  `json:"income_change"`
+	ExpensesChange        float64  `json:"expenses_change"`
+	BalanceChange         float64  `json:"balance_change"`
+	IncomeChangePercent   *float64 `json:"income_change_percent"`
+	ExpensesChangePercent *float64 `json:"expenses_change_percent"`
+}
+
+type Category struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+type Transaction struct {
+	ID              int64     `json:"id"`
+	Date            time.Time `json:"date"`
+	Amount          float64   `json:"amount"`
+	Category        string    `json:"category"`
+	TransactionType string    `json:"transaction_type"`
+	Note            string    `json:"note"`
+	Merchant        string    `json:"merchant"`
+}
+
+// Month is the spender's current month as their preferences define it.
+type Month struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+	Currency string `json:"currency"`
+}
+
+// Dashboard leaves a section null when it timed out or failed, Errors says
+// which and why.
+type Dashboard struct {
+	SpenderID          int64             `json:"spender_id"`
+	Month              Month             `json:"month"`
+	Summary            *Totals           `json:"summary"`
+	Comparison         *Comparison       `json:"comparison"`
+	TopCategories      []Category        `json:"top_categories"`
+	LargestExpenses    []Transaction     `json:"largest_expenses"`
+	LatestTransactions []Transaction     `json:"latest_transactions"`
+	Errors             map[string]string `json:"errors,omitempty"`
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func totals(income, expenses float64) Totals {
+	return Totals{Income: income, Expenses: expenses, Balance: float64(cents(income)-cents(expenses)) / 100}
+}
+
+// percent is the change from previous to current, rounded to one decimal.
+func percent(previous, current float64) *float64 {
+	if cents(previous) == 0 {
+		return nil
+	}
+	p := math.Round(float64(cents(current)-cents(previous))/float64(cents(previous))*1000) / 10
+	return &p
+}
+
+func compare(previous, current Totals) *Comparison {
+	return &Comparison{
+		Previous:              previous,
+		IncomeChange:          float64(cents(current.Income)-cents(previous.Income)) / 100,
+		ExpensesChange:        float64(cents(current.Expenses)-cents(previous.Expenses)) / 100,
+		BalanceChange:         float64(cents(current.Balance)-cents(previous.Balance)) / 100,
+		IncomeChangePercent:   percent(previous.Income, current.Income),
+		ExpensesChangePercent: percent(previous.Expenses, current.Expenses),
+	}
+}
@@ -0,0 +1,28 @@
+package dashboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	t.Run("change against the previous month", func(t *testing.T) {
+		c := compare(totals(30000, 12000), totals(31500, 9000.1))
+
+		assert.Equal(t, Totals{Income: 30000, Expenses: 12000, Balance: 18000}, c.Previous)
+		assert.Equal(t, 1500.0, c.IncomeChange)
+		assert.Equal(t, -2999.9, c.ExpensesChange)
+		assert.Equal(t, 4499.9, c.BalanceChange)
+		assert.Equal(t, 5.0, *c.IncomeChangePercent)
+		assert.Equal(t, -25.0, *c.ExpensesChangePercent)
+	})
+
+	t.Run("no percentage after an empty month", func(t *testing.T) {
+		c := compare(totals(0, 0), totals(100, 50))
+
+		assert.Nil(t, c.IncomeChangePercent)
+		assert.Nil(t, c.ExpensesChangePercent)
+		assert.Equal(t, 50.0, c.BalanceChange)
+	})
+}
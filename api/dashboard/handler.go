@@ -0,0 +1,217 @@
+package dashboard
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// sectionTimeout is how long each section may take, the dashboard
+	// answers without the ones that run over.
+	sectionTimeout = 2 * time.Second
+
+	// listSize is how many categories and transactions a list section shows.
+	listSize = 5
+)
+
+const (
+	spenderStmt = `SELECT EXISTS (SELECT 1 FROM spender WHERE id = $1 AND deleted_at IS NULL)`
+	latestStmt  = `SELECT id, date, amount, category, transaction_type, COALESCE(note, ''), merchant FROM "transaction"
+		WHERE spender_id = $1 AND deleted_at IS NULL ORDER BY date DESC, id DESC LIMIT $2`
+)
+
+// The money the month adds up is in the spender's home currency only, the
+// same as their period summary.
+var (
+	totalsStmt = `SELECT COALESCE(SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE 0 END), 0), COALESCE(SUM(CASE WHEN transaction_type = 'expense' THEN amount ELSE 0 END), 0) FROM "transaction"
+		WHERE spender_id = $1 AND deleted_at IS NULL AND date >= $2 AND date < $3 AND ` + transaction.HomeCurrency("", 4)
+	// split lines count under their own category, like the category summary
+	topCategoriesStmt = `SELECT COALESCE(s.category, t.category) AS category, SUM(COALESCE(s.amount, t.amount)) AS total, COUNT(*) FROM "transaction" t LEFT JOIN transaction_split s ON s.transaction_id = t.id
+		WHERE t.spender_id = $1 AND t.deleted_at IS NULL AND t.transaction_type = 'expense' AND t.date >= $2 AND t.date < $3 AND ` + transaction.HomeCurrency("t.", 5) + ` GROUP BY 1 ORDER BY total DESC, category LIMIT $4`
+	largestStmt = `SELECT id, date, amount, category, transaction_type, COALESCE(note, ''), merchant FROM "transaction"
+		WHERE spender_id = $1 AND deleted_at IS NULL AND transaction_type = 'expense' AND date >= $2 AND date < $3 AND ` + transaction.HomeCurrency("", 5) + ` ORDER BY amount DESC, id DESC LIMIT $4`
+)
+
+type handler struct {
+	flag    config.FeatureFlag
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db, sectionTimeout}
+}
+
+// section fills its part of the dashboard, it must only write to its own
+// fields.
+type section struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// GET /api/v1/spenders/:id/dashboard
+// The current month is the spender's, as set in their preferences.
+func (h handler) Get(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var ok bool
+	if err := h.db.QueryRowContext(ctx, spenderStmt, id).Scan(&ok); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !ok {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+
+	prefs, err := spender.LoadPreferences(ctx, h.db, id)
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	from, to, err := prefs.Period(spender.Month, time.Now())
+	if err != nil {
+		logger.Error("period error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	prevFrom := from.AddDate(0, -1, 0)
+
+	d := Dashboard{
+		SpenderID: id,
+		Month: Month{
+			From:     from.Format(time.DateOnly),
+			To:       to.AddDate(0, 0, -1).Format(time.DateOnly),
+			Timezone: prefs.Timezone,
+			Currency: prefs.Currency,
+		},
+	}
+	var current, previous *Totals
+	d.Errors = h.gather(ctx, logger, []section{
+		{CurrentMonth, func(ctx context.Context) (err error) {
+			current, err = h.totals(ctx, id, from, to, prefs.Currency)
+			return err
+		}},
+		{PreviousMonth, func(ctx context.Context) (err error) {
+			previous, err = h.totals(ctx, id, prevFrom, from, prefs.Currency)
+			return err
+		}},
+		{TopCategories, func(ctx context.Context) (err error) {
+			d.TopCategories, err = h.topCategories(ctx, id, from, to, prefs.Currency)
+			return err
+		}},
+		{LargestExpenses, func(ctx context.Context) (err error) {
+			d.LargestExpenses, err = h.transactions(ctx, largestStmt, id, from, to, listSize, prefs.Currency)
+			return err
+		}},
+		{LatestTransactions, func(ctx context.Context) (err error) {
+			d.LatestTransactions, err = h.transactions(ctx, latestStmt, id, listSize)
+			return err
+		}},
+	})
+
+	d.Summary = current
+	if current != nil && previous != nil {
+		d.Comparison = compare(*previous, *current)
+	}
+	return c.JSON(http.StatusOK, d)
+}
+
+// gather runs the sections concurrently, each under its own timeout, and
+// returns why the ones that didn't finish failed.
+func (h handler) gather(ctx context.Context, logger *zap.Logger, sections []section) map[string]string {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs map[string]string
+	)
+	for _, s := range sections {
+		wg.Add(1)
+		go func(s section) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			err := s.run(ctx)
+			if err == nil {
+				return
+			}
+			reason := "Please check server logs"
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				reason = "timed out"
+			}
+			logger.Error("dashboard section error", zap.String("section", s.name), zap.Error(err))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errs == nil {
+				errs = map[string]string{}
+			}
+			errs[s.name] = reason
+		}(s)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (h handler) totals(ctx context.Context, id int64, from, to time.Time, currency string) (*Totals, error) {
+	var income, expenses float64
+	if err := h.db.QueryRowContext(ctx, totalsStmt, id, from, to, currency).Scan(&income, &expenses); err != nil {
+		return nil, err
+	}
+	t := totals(income, expenses)
+	return &t, nil
+}
+
+func (h handler) topCategories(ctx context.Context, id int64, from, to time.Time, currency string) ([]Category, error) {
+	rows, err := h.db.QueryContext(ctx, topCategoriesStmt, id, from, to, listSize, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.Category, &c.Total, &c.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (h handler) transactions(ctx context.Context, query string, args ...any) ([]Transaction, error) {
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txs := []Transaction{}
+	for rows.Next() {
+		var t Transaction
+		if err := rows.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.Note, &t.Merchant); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+	return txs, rows.Err()
+}
@@ -0,0 +1,115 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const prefStmt = `SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`
+
+var txColumns = []string{"id", "date", "amount", "category", "transaction_type", "note", "merchant"}
+
+func newContext(id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestGetDashboard(t *testing.T) {
+	from, to, _ := spender.DefaultPreferences(1).Period(spender.Month, time.Now())
+	prevFrom := from.AddDate(0, -1, 0)
+	date := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+
+	expectMonth := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(spenderStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(prefStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"timezone"}))
+		mock.MatchExpectationsInOrder(false)
+		mock.ExpectQuery(totalsStmt).WithArgs(int64(1), from, to, "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"income", "expenses"}).AddRow(30000, 9000))
+		mock.ExpectQuery(totalsStmt).WithArgs(int64(1), prevFrom, from, "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"income", "expenses"}).AddRow(30000, 12000))
+		mock.ExpectQuery(topCategoriesStmt).WithArgs(int64(1), from, to, listSize, "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"category", "total", "count"}).AddRow("Food", 6000, 12).AddRow("Transport", 3000, 4))
+		mock.ExpectQuery(largestStmt).WithArgs(int64(1), from, to, listSize, "THB").
+			WillReturnRows(sqlmock.NewRows(txColumns).AddRow(7, date, 2500, "Food", "expense", "dinner", "Sushi Hiro"))
+	}
+
+	t.Run("get every section of the dashboard", func(t *testing.T) {
+		c, rec := newContext("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectMonth(mock)
+		mock.ExpectQuery(latestStmt).WithArgs(int64(1), listSize).
+			WillReturnRows(sqlmock.NewRows(txColumns).AddRow(9, date, 120, "Transport", "expense", "", "BTS"))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Get(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"spender_id": 1,
+			"month": {"from": "`+from.Format(time.DateOnly)+`", "to": "`+to.AddDate(0, 0, -1).Format(time.DateOnly)+`", "timezone": "UTC", "currency": "THB"},
+			"summary": {"income": 30000, "expenses": 9000, "balance": 21000},
+			"comparison": {
+				"previous": {"income": 30000, "expenses": 12000, "balance": 18000},
+				"income_change": 0, "expenses_change": -3000, "balance_change": 3000,
+				"income_change_percent": 0, "expenses_change_percent": -25
+			},
+			"top_categories": [{"category": "Food", "total": 6000, "count": 12}, {"category": "Transport", "total": 3000, "count": 4}],
+			"largest_expenses": [{"id": 7, "date": "2024-05-03T12:00:00Z", "amount": 2500, "category": "Food", "transaction_type": "expense", "note": "dinner", "merchant": "Sushi Hiro"}],
+			"latest_transactions": [{"id": 9, "date": "2024-05-03T12:00:00Z", "amount": 120, "category": "Transport", "transaction_type": "expense", "note": "", "merchant": "BTS"}]
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("answer without a section that timed out", func(t *testing.T) {
+		c, rec := newContext("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectMonth(mock)
+		mock.ExpectQuery(latestStmt).WithArgs(int64(1), listSize).WillDelayFor(500 * time.Millisecond).
+			WillReturnRows(sqlmock.NewRows(txColumns))
+
+		h := New(config.FeatureFlag{}, db)
+		h.timeout = 50 * time.Millisecond
+		err := h.Get(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"latest_transactions":null`)
+		assert.Contains(t, rec.Body.String(), `"summary":{"income":30000,"expenses":9000,"balance":21000}`)
+		assert.Contains(t, rec.Body.String(), `"errors":{"latest_transactions":"timed out"}`)
+	})
+
+	t.Run("get dashboard failed on unknown spender", func(t *testing.T) {
+		c, rec := newContext("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(spenderStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Get(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -6,16 +6,16 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
 func Upload(c echo.Context) error {
 	form, err := c.MultipartForm()
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": "Failed to parse form",
-			"error":   err.Error(),
-		})
+		return apierror.Respond(c, http.StatusBadRequest, "Failed to parse form")
 	}
 	images := form.File["images"]
 	var locations []string
@@ -23,20 +23,15 @@ func Upload(c echo.Context) error {
 		fmt.Printf("Uploading file: %+v\n", image.Filename)
 		src, err := image.Open()
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"message": "Failed to parse form",
-				"error":   err.Error(),
-			})
+			return apierror.Respond(c, http.StatusBadRequest, "Failed to parse form")
 		}
 		defer src.Close()
 
 		// upload to AWS S3 bucket
 		loc, err := UploadToS3(c, image.Filename, src)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"message": "Failed to upload image",
-				"error":   err.Error(),
-			})
+			mlog.L(c).Error("upload image error", zap.Error(err))
+			return apierror.Internal(c)
 		}
 		locations = append(locations, loc)
 	}
@@ -6,15 +6,17 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
 func Check(db *sql.DB) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		if err := db.Ping(); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("api server is live: but can't connect to database: %s", err.Error())})
+			mlog.L(c).Error("ping database error", zap.Error(err))
+			return apierror.Respond(c, http.StatusInternalServerError, "api server is live: but can't connect to database")
 		}
 
 		return c.JSON(http.StatusOK, map[string]string{
@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/merchant"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	maxFileSize = 5 << 20
+
+	existsStmt   = `SELECT EXISTS (SELECT 1 FROM spender WHERE id = $1 AND deleted_at IS NULL)`
+	existingStmt = `SELECT external_id FROM "transaction" WHERE spender_id = $1 AND external_id = ANY($2::text[])`
+
+	// a FITID already recorded for the spender inserts nothing
+	cStmt = `INSERT INTO "transaction" (date, amount, category, transaction_type, spender_id, note, external_id, merchant, merchant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (spender_id, external_id) DO NOTHING RETURNING id;`
+)
+
+type Result struct {
+	Preview    bool    `json:"preview"`
+	Format     string  `json:"format"`
+	Imported   int     `json:"imported"`
+	Duplicates int     `json:"duplicates"`
+	Entries    []Entry `json:"entries"`
+}
+
+// POST /api/v1/spenders/:id/transactions/import/statement?preview=true
+// Multipart form with the OFX or QIF file in "file", optional "category"
+// for the entries without one and "date_format" for QIF, DD/MM/YYYY by
+// default. A preview only parses and flags the entries already imported.
+func (h handler) Import(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var ok bool
+	if err := h.db.QueryRowContext(ctx, existsStmt, spenderID).Scan(&ok); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !ok {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	preview, _ := strconv.ParseBool(c.QueryParam("preview"))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "file is required")
+	}
+	src, err := file.Open()
+	if err != nil {
+		logger.Error("open upload error", zap.Error(err))
+		return apierror.Respond(c, http.StatusBadRequest, "cannot read the file")
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxFileSize+1))
+	if err != nil {
+		logger.Error("read upload error", zap.Error(err))
+		return apierror.Respond(c, http.StatusBadRequest, "cannot read the file")
+	}
+	if len(data) > maxFileSize {
+		return apierror.Respond(c, http.StatusRequestEntityTooLarge, "file is larger than 5 MB")
+	}
+
+	category := c.FormValue("category")
+	if category == "" {
+		category = "uncategorized"
+	}
+	dateFormat := c.FormValue("date_format")
+	if dateFormat == "" {
+		dateFormat = "DD/MM/YYYY"
+	}
+
+	format, err := detect(data)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+	var entries []Entry
+	if format == OFX {
+		entries, err = parseOFX(data, category)
+	} else {
+		entries, err = parseQIF(data, category, dateFormat)
+	}
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+	for i := range entries {
+		entries[i].Transaction.SpenderID = spenderID
+	}
+
+	result := Result{Preview: preview, Format: format, Entries: entries}
+	if preview {
+		err = categorize(ctx, h.db, spenderID, entries, false)
+		if err == nil {
+			err = h.markDuplicates(ctx, spenderID, entries)
+		}
+	} else {
+		err = h.record(ctx, spenderID, entries)
+	}
+	if err != nil {
+		logger.Error("import error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	for _, e := range entries {
+		if e.Duplicate {
+			result.Duplicates++
+		} else if !preview {
+			result.Imported++
+		}
+	}
+
+	if preview {
+		return c.JSON(http.StatusOK, result)
+	}
+	logger.Info("import successfully", zap.Int("imported", result.Imported), zap.Int("duplicates", result.Duplicates))
+	return c.JSON(http.StatusCreated, result)
+}
+
+func (h handler) markDuplicates(ctx context.Context, spenderID int, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.FITID
+	}
+
+	rows, err := h.db.QueryContext(ctx, existingStmt, spenderID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		existing[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entries[i].Duplicate = existing[entries[i].FITID]
+	}
+	return nil
+}
+
+// categorize runs the spender's merchant rules on the entries with a
+// payee, a preview leaves new merchants out of the merchant table.
+func categorize(ctx context.Context, q merchant.Querier, spenderID int, entries []Entry, create bool) error {
+	lines := make([]merchant.Line, len(entries))
+	for i, e := range entries {
+		lines[i] = merchant.Line{Name: e.Transaction.Merchant, Category: e.Transaction.Category}
+	}
+	res, err := merchant.CategorizeLines(ctx, q, int64(spenderID), lines, create)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		t := &entries[i].Transaction
+		t.Merchant, t.Category = res[i].Merchant, res[i].Category
+		entries[i].merchantID, entries[i].Tags = res[i].MerchantID, res[i].Tags
+	}
+	return nil
+}
+
+// record inserts the entries in one DB transaction, the unique FITID per
+// spender turns entries imported before into no-ops.
+func (h handler) record(ctx context.Context, spenderID int, entries []Entry) error {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := categorize(ctx, tx, spenderID, entries, true); err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		t := e.Transaction
+		var id int64
+		err := tx.QueryRowContext(ctx, cStmt, t.Date, t.Amount, t.Category, t.TransactionType, t.SpenderID, t.Note, e.FITID, t.Merchant, e.merchantID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			entries[i].Duplicate = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := tag.Attach(ctx, tx, id, int64(spenderID), e.Tags); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// the merchant package's statements, every statement line names its payee
+const (
+	rulesStmt   = `SELECT id, spender_id, match, pattern, category, tags, priority, created_at FROM merchant_rule WHERE spender_id = $1 ORDER BY priority DESC, id`
+	findStmt    = `SELECT id, name FROM merchant WHERE name = $1 UNION ALL SELECT m.id, m.name FROM merchant_alias a JOIN merchant m ON m.id = a.merchant_id WHERE a.alias = $1 LIMIT 1`
+	ensureStmt  = `INSERT INTO merchant (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`
+	tagStmt     = `INSERT INTO tag (spender_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (spender_id, name) DO NOTHING;`
+	tagLinkStmt = `INSERT INTO transaction_tag (transaction_id, tag_id) SELECT $1, id FROM tag WHERE spender_id = $2 AND name = ANY($3::text[]) ON CONFLICT DO NOTHING;`
+)
+
+var ruleColumns = []string{"id", "spender_id", "match", "pattern", "category", "tags", "priority", "created_at"}
+
+func uploadRequest(t *testing.T, target, content string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", "statement.ofx")
+	assert.NoError(t, err)
+	_, _ = part.Write([]byte(content))
+	assert.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+func TestImport(t *testing.T) {
+	t.Run("preview flags entries imported before", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(uploadRequest(t, "/?preview=true", sgmlOFX), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleColumns).
+			AddRow(3, 1, "prefix", "TOPS", "Groceries", "{grocery}", 0, time.Now()))
+		mock.ExpectQuery(findStmt).WithArgs("TOPS MARKET").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery(findStmt).WithArgs("SALARY").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		ids := []string{"123-4-56789:2024060100001", "123-4-56789:2024062500007"}
+		mock.ExpectQuery(existingStmt).WithArgs(1, pq.Array(ids)).WillReturnRows(sqlmock.NewRows([]string{"external_id"}).AddRow(ids[0]))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"preview":true,"format":"ofx","imported":0,"duplicates":1`)
+		assert.Contains(t, rec.Body.String(), `"category":"Groceries","transaction_type":"expense","spender_id":1,"note":"Tops Market - Groceries \u0026 snacks"`)
+		assert.Contains(t, rec.Body.String(), `"tags":["grocery"]`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("re-import only records new entries", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(uploadRequest(t, "/", sgmlOFX), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleColumns).
+			AddRow(3, 1, "equals", "SALARY", "", "{payroll}", 0, time.Now()))
+		mock.ExpectQuery(findStmt).WithArgs("TOPS MARKET").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "TOPS MARKET"))
+		mock.ExpectQuery(findStmt).WithArgs("SALARY").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery(ensureStmt).WithArgs("SALARY").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-01", 1250.0, "uncategorized", "expense", 1, "Tops Market - Groceries & snacks", "123-4-56789:2024060100001", "TOPS MARKET", int64(4)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-25", 30000.0, "uncategorized", "income", 1, "Salary", "123-4-56789:2024062500007", "SALARY", int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectExec(tagStmt).WithArgs(int64(1), pq.Array([]string{"payroll"})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(tagLinkStmt).WithArgs(int64(8), int64(1), pq.Array([]string{"payroll"})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"preview":false,"format":"ofx","imported":1,"duplicates":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject files that are not statements", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(uploadRequest(t, "/", "date,amount\n2024-06-01,10\n"), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("spender not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(uploadRequest(t, "/", sgmlOFX), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
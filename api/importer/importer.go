@@ -0,0 +1,78 @@
+// Package importer reads OFX and QIF statements downloaded from banks and
+// records their entries as transactions.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+)
+
+const (
+	OFX = "ofx"
+	QIF = "qif"
+)
+
+// Entry is one statement line mapped to a transaction. FITID is the bank's
+// id of the line, it makes importing the same statement twice harmless.
+// Tags come from the spender's merchant rules.
+type Entry struct {
+	FITID       string                         `json:"fitid"`
+	Transaction transaction.TransactionReqBody `json:"transaction"`
+	Tags        []string                       `json:"tags,omitempty"`
+	Duplicate   bool                           `json:"duplicate"`
+
+	merchantID *int64
+}
+
+var (
+	errUnknownFormat = errors.New("file is neither an OFX nor a QIF statement")
+	errZeroAmount    = errors.New("amount must be a non zero number")
+)
+
+// detect tells the statement format from the start of the file.
+func detect(data []byte) (string, error) {
+	head := bytes.ToUpper(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	switch {
+	case bytes.HasPrefix(head, []byte("OFXHEADER")), bytes.HasPrefix(head, []byte("<?XML")), bytes.HasPrefix(head, []byte("<OFX>")):
+		return OFX, nil
+	case bytes.HasPrefix(head, []byte("!TYPE:")), bytes.HasPrefix(head, []byte("!ACCOUNT")):
+		return QIF, nil
+	}
+	return "", errUnknownFormat
+}
+
+// entry builds the transaction of a signed statement amount, money out of
+// the account is an expense. A zero amount is refused as in CSV imports.
+func entry(fitid, date string, amount float64, category, payee string, note ...string) (Entry, error) {
+	if amount == 0 {
+		return Entry{}, errZeroAmount
+	}
+	kind := "income"
+	if amount < 0 {
+		kind = "expense"
+	}
+
+	var parts []string
+	for _, n := range note {
+		if n = strings.TrimSpace(n); n != "" && !slices.Contains(parts, n) {
+			parts = append(parts, n)
+		}
+	}
+
+	return Entry{
+		FITID: fitid,
+		Transaction: transaction.TransactionReqBody{
+			Date:            date,
+			Amount:          math.Abs(amount),
+			Category:        category,
+			TransactionType: kind,
+			Note:            strings.Join(parts, " - "),
+			Merchant:        strings.TrimSpace(payee),
+		},
+	}, nil
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+)
+
+// parseOFX reads the STMTTRN entries of an OFX statement. The SGML variant
+// (OFX 1.x) leaves elements unclosed, the XML variant (OFX 2.x) closes them;
+// reading every value up to the next tag handles both. Tag names are
+// uppercase in both.
+func parseOFX(data []byte, category string) ([]Entry, error) {
+	const open, end = "<STMTTRN>", "</STMTTRN>"
+
+	s := string(data)
+	entries := []Entry{}
+	for pos := 0; ; {
+		i := strings.Index(s[pos:], open)
+		if i < 0 {
+			break
+		}
+		start := pos + i + len(open)
+		j := strings.Index(s[start:], end)
+		if j < 0 {
+			return nil, fmt.Errorf("entry %d: missing %s", len(entries)+1, end)
+		}
+		pos = start + j + len(end)
+
+		f := ofxFields(s[start : start+j])
+		n := len(entries) + 1
+		if f["FITID"] == "" {
+			return nil, fmt.Errorf("entry %d: FITID is missing", n)
+		}
+		date, err := ofxDate(f["DTPOSTED"])
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: DTPOSTED %q is not a date", n, f["DTPOSTED"])
+		}
+		amount, err := transaction.ParseAmount(f["TRNAMT"])
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: TRNAMT %q: %w", n, f["TRNAMT"], err)
+		}
+
+		// FITIDs are only unique within one account of one bank
+		fitid := f["FITID"]
+		if acct := ofxAccount(s[:start]); acct != "" {
+			fitid = acct + ":" + fitid
+		}
+		e, err := entry(fitid, date, amount, category, f["NAME"], f["NAME"], f["MEMO"])
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: TRNAMT %q: %w", n, f["TRNAMT"], err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ofxFields maps the element names of an aggregate to their values.
+func ofxFields(block string) map[string]string {
+	fields := map[string]string{}
+	for _, part := range strings.Split(block, "<")[1:] {
+		name, value, ok := strings.Cut(part, ">")
+		if !ok || strings.HasPrefix(name, "/") {
+			continue
+		}
+		fields[strings.ToUpper(strings.TrimSpace(name))] = html.UnescapeString(strings.TrimSpace(value))
+	}
+	return fields
+}
+
+// ofxAccount is the ACCTID of the statement the entry at the end of before
+// belongs to.
+func ofxAccount(before string) string {
+	i := strings.LastIndex(before, "<ACCTID>")
+	if i < 0 {
+		return ""
+	}
+	value := before[i+len("<ACCTID>"):]
+	if j := strings.Index(value, "<"); j >= 0 {
+		value = value[:j]
+	}
+	return strings.TrimSpace(value)
+}
+
+// ofxDate keeps the day of YYYYMMDDHHMMSS.XXX[offset:TZ].
+func ofxDate(s string) (string, error) {
+	if len(s) < 8 {
+		return "", errors.New("too short")
+	}
+	d, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return "", err
+	}
+	return d.Format("2006-01-02"), nil
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/stretchr/testify/assert"
+)
+
+const sgmlOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>THB
+<BANKACCTFROM><BANKID>014<ACCTID>123-4-56789<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240601120000.000[+7:ICT]
+<TRNAMT>-1,250.00
+<FITID>2024060100001
+<NAME>Tops Market
+<MEMO>Groceries &amp; snacks
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240625
+<TRNAMT>30000.00
+<FITID>2024062500007
+<NAME>Salary
+<MEMO>Salary
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlOFX = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240603</DTPOSTED><TRNAMT>-89.00</TRNAMT><FITID>A1</FITID><NAME>Coffee</NAME></STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	t.Run("sgml statement", func(t *testing.T) {
+		entries, err := parseOFX([]byte(sgmlOFX), "uncategorized")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{FITID: "123-4-56789:2024060100001", Transaction: transaction.TransactionReqBody{
+				Date: "2024-06-01", Amount: 1250, Category: "uncategorized", TransactionType: "expense", Note: "Tops Market - Groceries & snacks", Merchant: "Tops Market"}},
+			{FITID: "123-4-56789:2024062500007", Transaction: transaction.TransactionReqBody{
+				Date: "2024-06-25", Amount: 30000, Category: "uncategorized", TransactionType: "income", Note: "Salary", Merchant: "Salary"}},
+		}, entries)
+	})
+
+	t.Run("xml statement", func(t *testing.T) {
+		entries, err := parseOFX([]byte(xmlOFX), "card")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{FITID: "4111:A1", Transaction: transaction.TransactionReqBody{
+				Date: "2024-06-03", Amount: 89, Category: "card", TransactionType: "expense", Note: "Coffee", Merchant: "Coffee"}},
+		}, entries)
+	})
+
+	t.Run("entry without FITID", func(t *testing.T) {
+		_, err := parseOFX([]byte("<OFX><STMTTRN><DTPOSTED>20240603<TRNAMT>-1</STMTTRN></OFX>"), "card")
+
+		assert.EqualError(t, err, "entry 1: FITID is missing")
+	})
+
+	t.Run("amount that is not a finite number", func(t *testing.T) {
+		_, err := parseOFX([]byte("<OFX><STMTTRN><DTPOSTED>20240603<TRNAMT>NaN<FITID>A1</STMTTRN></OFX>"), "card")
+
+		assert.EqualError(t, err, `entry 1: TRNAMT "NaN": amount is not a number`)
+	})
+
+	t.Run("zero amount", func(t *testing.T) {
+		_, err := parseOFX([]byte("<OFX><STMTTRN><DTPOSTED>20240603<TRNAMT>0.00<FITID>A1</STMTTRN></OFX>"), "card")
+
+		assert.EqualError(t, err, `entry 1: TRNAMT "0.00": amount must be a non zero number`)
+	})
+}
+
+func TestDetect(t *testing.T) {
+	for data, want := range map[string]string{sgmlOFX: OFX, xmlOFX: OFX, qifStatement: QIF} {
+		format, err := detect([]byte(data))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, format)
+	}
+
+	_, err := detect([]byte("date,amount\n"))
+	assert.ErrorIs(t, err, errUnknownFormat)
+}
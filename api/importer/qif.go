@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+)
+
+// qifDate turns DD/MM/YYYY style formats into a layout that also accepts
+// single digit days and months, as most QIF files have them.
+var qifDate = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "1", "DD", "2")
+
+// parseQIF reads the records of a QIF statement. QIF has no id per entry so
+// one is derived from the record content, identical records of the same
+// file are told apart by how many came before them.
+func parseQIF(data []byte, category, dateFormat string) ([]Entry, error) {
+	layout := qifDate.Replace(dateFormat)
+
+	entries := []Entry{}
+	seen := map[string]int{}
+	record := map[byte]string{}
+	skip := false
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if text == "" {
+			continue
+		}
+
+		switch code := text[0]; {
+		case code == '!':
+			// account lists describe accounts, not transactions
+			header := strings.ToUpper(text)
+			skip = strings.HasPrefix(header, "!ACCOUNT") || strings.HasPrefix(header, "!OPTION")
+		case code == '^':
+			if !skip && len(record) > 0 {
+				e, err := qifEntry(record, layout, category)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				key := e.FITID
+				e.FITID = fmt.Sprintf("%s#%d", key, seen[key])
+				seen[key]++
+				entries = append(entries, e)
+			}
+			record = map[byte]string{}
+		default:
+			record[code] = strings.TrimSpace(text[1:])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func qifEntry(record map[byte]string, layout, category string) (Entry, error) {
+	// Quicken writes years from 2000 on as 6/2'24
+	posted := strings.NewReplacer("'", "/20", " ", "").Replace(record['D'])
+	date, err := time.Parse(layout, posted)
+	if err != nil {
+		return Entry{}, fmt.Errorf("date %q does not match the date format", record['D'])
+	}
+
+	raw := record['T']
+	if raw == "" {
+		raw = record['U']
+	}
+	amount, err := transaction.ParseAmount(raw)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%q: %w", raw, err)
+	}
+
+	// [Savings] is a transfer to another account in QIF, keep the name only
+	if c := strings.Trim(record['L'], "[]"); c != "" {
+		category = c
+	}
+
+	day := date.Format("2006-01-02")
+	sum := sha1.Sum([]byte(strings.Join([]string{day, raw, record['P'], record['M'], record['N']}, "|")))
+	e, err := entry("qif:"+hex.EncodeToString(sum[:8]), day, amount, category, record['P'], record['P'], record['M'])
+	if err != nil {
+		return Entry{}, fmt.Errorf("%q: %w", raw, err)
+	}
+	return e, nil
+}
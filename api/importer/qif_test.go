@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const qifStatement = `!Type:Bank
+D01/06/2024
+T-1,250.00
+PTops Market
+LGroceries
+^
+D2/6'24
+T-65.00
+PCoffee
+^
+D2/6'24
+T-65.00
+PCoffee
+^
+D25/06/2024
+T30,000.00
+PSalary
+L[Savings]
+^
+`
+
+func TestParseQIF(t *testing.T) {
+	t.Run("map records and tell identical ones apart", func(t *testing.T) {
+		entries, err := parseQIF([]byte(qifStatement), "uncategorized", "DD/MM/YYYY")
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 4)
+
+		assert.Equal(t, "2024-06-01", entries[0].Transaction.Date)
+		assert.Equal(t, 1250.0, entries[0].Transaction.Amount)
+		assert.Equal(t, "expense", entries[0].Transaction.TransactionType)
+		assert.Equal(t, "Groceries", entries[0].Transaction.Category)
+		assert.Equal(t, "Tops Market", entries[0].Transaction.Note)
+		assert.Equal(t, "Tops Market", entries[0].Transaction.Merchant)
+
+		assert.Equal(t, "2024-06-02", entries[1].Transaction.Date)
+		assert.NotEqual(t, entries[1].FITID, entries[2].FITID)
+
+		assert.Equal(t, "income", entries[3].Transaction.TransactionType)
+		assert.Equal(t, "Savings", entries[3].Transaction.Category)
+	})
+
+	t.Run("ids are stable across imports", func(t *testing.T) {
+		first, _ := parseQIF([]byte(qifStatement), "uncategorized", "DD/MM/YYYY")
+		again, _ := parseQIF([]byte(qifStatement), "uncategorized", "DD/MM/YYYY")
+
+		assert.Equal(t, first, again)
+	})
+
+	t.Run("skip account lists", func(t *testing.T) {
+		data := "!Account\nNChecking\nTBank\n^\n!Type:Bank\nD06/01/2024\nT10\n^\n"
+
+		entries, err := parseQIF([]byte(data), "uncategorized", "MM/DD/YYYY")
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "2024-06-01", entries[0].Transaction.Date)
+	})
+
+	t.Run("date that does not match the format", func(t *testing.T) {
+		_, err := parseQIF([]byte("!Type:Bank\nD2024-06-01\nT10\n^\n"), "uncategorized", "DD/MM/YYYY")
+
+		assert.EqualError(t, err, `line 4: date "2024-06-01" does not match the date format`)
+	})
+
+	t.Run("zero amount", func(t *testing.T) {
+		_, err := parseQIF([]byte("!Type:Bank\nD01/06/2024\nT0\n^\n"), "uncategorized", "DD/MM/YYYY")
+
+		assert.EqualError(t, err, `line 4: "0": amount must be a non zero number`)
+	})
+}
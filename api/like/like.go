@@ -0,0 +1,11 @@
+// Package like builds the patterns of SQL LIKE and ILIKE searches.
+package like
+
+import "strings"
+
+var escaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Contains matches s anywhere in the text, its % and _ taken literally.
+func Contains(s string) string {
+	return "%" + escaper.Replace(s) + "%"
+}
@@ -0,0 +1,12 @@
+package like
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContains(t *testing.T) {
+	assert.Equal(t, `%100\% off\_now%`, Contains("100% off_now"))
+	assert.Equal(t, `%C:\\temp%`, Contains(`C:\temp`))
+}
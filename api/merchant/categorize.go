@@ -0,0 +1,147 @@
+package merchant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ruleColumns = `id, spender_id, match, pattern, category, tags, priority, created_at`
+	rulesStmt   = `SELECT ` + ruleColumns + ` FROM merchant_rule WHERE spender_id = $1 ORDER BY priority DESC, id`
+
+	findStmt   = `SELECT id, name FROM merchant WHERE name = $1 UNION ALL SELECT m.id, m.name FROM merchant_alias a JOIN merchant m ON m.id = a.merchant_id WHERE a.alias = $1 LIMIT 1`
+	ensureStmt = `INSERT INTO merchant (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`
+)
+
+// Querier is a *sql.DB or a *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Result is what a transaction gets from its merchant.
+type Result struct {
+	MerchantID *int64
+	Merchant   string
+	Category   string
+	Tags       []string
+}
+
+// Categorizer applies one spender's rules. It loads them once and
+// remembers the merchants it has looked up, an import calls it per line.
+type Categorizer struct {
+	q         Querier
+	rules     []Rule
+	merchants map[string]Merchant
+}
+
+func NewCategorizer(ctx context.Context, q Querier, spenderID int64) (*Categorizer, error) {
+	rules, err := listRules(ctx, q, rulesStmt, spenderID)
+	if err != nil {
+		return nil, err
+	}
+	return &Categorizer{q: q, rules: rules, merchants: map[string]Merchant{}}, nil
+}
+
+// Categorize resolves name to its merchant and runs the rules on it. With
+// create, a merchant seen for the first time is added, a preview passes
+// false to leave the tables alone. An empty name changes nothing.
+func (c *Categorizer) Categorize(ctx context.Context, name, category string, create bool) (Result, error) {
+	res := Result{Category: category}
+	norm := Normalize(name)
+	if norm == "" {
+		return res, nil
+	}
+
+	m, err := c.merchant(ctx, norm, create)
+	if err != nil {
+		return res, err
+	}
+	res.Merchant = m.Name
+	if m.ID != 0 {
+		res.MerchantID = &m.ID
+	}
+
+	if r := first(c.rules, m.Name); r != nil {
+		res.Category, res.Tags = r.apply(category)
+	}
+	return res, nil
+}
+
+// Line is the merchant name and category of one line of an import.
+type Line struct {
+	Name     string
+	Category string
+}
+
+// CategorizeLines runs the spender's rules on the lines of an import, one
+// Result per line. The rules are only loaded when a line names a merchant,
+// a line without one keeps its category.
+func CategorizeLines(ctx context.Context, q Querier, spenderID int64, lines []Line, create bool) ([]Result, error) {
+	res := make([]Result, len(lines))
+	for i, l := range lines {
+		res[i] = Result{Category: l.Category}
+	}
+	if !slices.ContainsFunc(lines, func(l Line) bool { return Normalize(l.Name) != "" }) {
+		return res, nil
+	}
+
+	c, err := NewCategorizer(ctx, q, spenderID)
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range lines {
+		if res[i], err = c.Categorize(ctx, l.Name, l.Category, create); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (c *Categorizer) merchant(ctx context.Context, norm string, create bool) (Merchant, error) {
+	if m, ok := c.merchants[norm]; ok && (m.ID != 0 || !create) {
+		return m, nil
+	}
+
+	m := Merchant{Name: norm}
+	err := c.q.QueryRowContext(ctx, findStmt, norm).Scan(&m.ID, &m.Name)
+	if errors.Is(err, sql.ErrNoRows) && create {
+		err = c.q.QueryRowContext(ctx, ensureStmt, norm).Scan(&m.ID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Merchant{}, err
+	}
+	c.merchants[norm] = m
+	return m, nil
+}
+
+// Ensure returns the id of the merchant named norm, a name Normalize
+// returned, adding it when it is new. A write passes its *sql.Tx so the
+// merchant is only added with the row that uses it.
+func Ensure(ctx context.Context, q Querier, norm string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, ensureStmt, norm).Scan(&id)
+	return id, err
+}
+
+func listRules(ctx context.Context, q Querier, query string, arg any) ([]Rule, error) {
+	rows, err := q.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []Rule{}
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.ID, &r.SpenderID, &r.Match, &r.Pattern, &r.Category, (*pq.StringArray)(&r.Tags), &r.Priority, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,89 @@
+package merchant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var ruleRows = []string{"id", "spender_id", "match", "pattern", "category", "tags", "priority", "created_at"}
+
+func TestCategorize(t *testing.T) {
+	t.Run("resolve aliases and apply the rule", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleRows).
+			AddRow(2, 1, "contains", "7-ELEVEN", "Food", "{convenience}", 10, time.Now()))
+		mock.ExpectQuery(findStmt).WithArgs("7-11").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "7-ELEVEN"))
+
+		cat, err := NewCategorizer(context.Background(), db, 1)
+		assert.NoError(t, err)
+
+		got, err := cat.Categorize(context.Background(), "7-11 #0042", Uncategorized, true)
+		assert.NoError(t, err)
+		id := int64(5)
+		assert.Equal(t, Result{MerchantID: &id, Merchant: "7-ELEVEN", Category: "Food", Tags: []string{"convenience"}}, got)
+
+		// looked up once per import
+		again, err := cat.Categorize(context.Background(), "7-11", "Snacks", true)
+		assert.NoError(t, err)
+		assert.Equal(t, "Snacks", again.Category)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("add a new merchant only when asked", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleRows))
+		mock.ExpectQuery(findStmt).WithArgs("LOTUS").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery(findStmt).WithArgs("LOTUS").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery(ensureStmt).WithArgs("LOTUS").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+		cat, err := NewCategorizer(context.Background(), db, 1)
+		assert.NoError(t, err)
+
+		preview, err := cat.Categorize(context.Background(), "Lotus", "", false)
+		assert.NoError(t, err)
+		assert.Nil(t, preview.MerchantID)
+		assert.Equal(t, "LOTUS", preview.Merchant)
+
+		saved, err := cat.Categorize(context.Background(), "Lotus", "", true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9), *saved.MerchantID)
+		assert.Empty(t, saved.Tags)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no merchant changes nothing", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleRows))
+
+		cat, err := NewCategorizer(context.Background(), db, 1)
+		assert.NoError(t, err)
+
+		got, err := cat.Categorize(context.Background(), "  ", "Food", true)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Category: "Food"}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCategorizeLines(t *testing.T) {
+	t.Run("lines without a merchant load no rules", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		got, err := CategorizeLines(context.Background(), db, 1, []Line{{Category: "Food"}, {Name: " ", Category: "Travel"}}, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{Category: "Food"}, {Category: "Travel"}}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
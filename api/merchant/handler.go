@@ -0,0 +1,411 @@
+package merchant
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/like"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	maxSamples = 50
+
+	listStmt = `SELECT m.id, m.name, COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM merchant m LEFT JOIN merchant_alias a ON a.merchant_id = m.id
+		WHERE m.name LIKE $1 GROUP BY m.id, m.name ORDER BY m.name LIMIT 50`
+	cStmt      = `INSERT INTO merchant (name) VALUES ($1) RETURNING id;`
+	cAliasStmt = `INSERT INTO merchant_alias (alias, merchant_id) VALUES ($1, $2);`
+
+	// an alias that was a merchant of its own is merged into the target
+	mergeTxStmt    = `UPDATE transaction SET merchant_id = $1, merchant = $2 WHERE merchant_id = (SELECT id FROM merchant WHERE name = $3);`
+	mergeAliasStmt = `UPDATE merchant_alias SET merchant_id = $1 WHERE merchant_id = (SELECT id FROM merchant WHERE name = $2);`
+	mergeDropStmt  = `DELETE FROM merchant WHERE name = $1 AND id <> $2;`
+	nameStmt       = `SELECT name FROM merchant WHERE id = $1`
+
+	cRuleStmt  = `INSERT INTO merchant_rule (spender_id, match, pattern, category, tags, priority) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at;`
+	uRuleStmt  = `UPDATE merchant_rule SET match = $1, pattern = $2, category = $3, tags = $4, priority = $5 WHERE id = $6 RETURNING spender_id, created_at;`
+	dRuleStmt  = `DELETE FROM merchant_rule WHERE id = $1;`
+	candidates = `SELECT id, to_char(date, 'YYYY-MM-DD'), merchant, COALESCE(note, ''), category FROM transaction
+		WHERE spender_id = $1 AND deleted_at IS NULL AND (upper(merchant) LIKE $2 OR upper(note) LIKE $2) ORDER BY date DESC, id DESC`
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// GET /api/v1/merchants?q=eleven
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	rows, err := h.db.QueryContext(ctx, listStmt, like.Contains(Normalize(c.QueryParam("q"))))
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	merchants := []Merchant{}
+	for rows.Next() {
+		var m Merchant
+		if err := rows.Scan(&m.ID, &m.Name, (*pq.StringArray)(&m.Aliases)); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		merchants = append(merchants, m)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, merchants)
+}
+
+// POST /api/v1/merchants
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var m Merchant
+	if err := c.Bind(&m); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if m.Name = Normalize(m.Name); m.Name == "" {
+		return apierror.Respond(c, http.StatusBadRequest, "name is required")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, cStmt, m.Name).Scan(&m.ID)
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "merchant already exists")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	aliases := []string{}
+	for _, a := range m.Aliases {
+		if a = Normalize(a); a == "" || a == m.Name {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, cAliasStmt, a, m.ID)
+		if isUniqueViolation(err) {
+			return apierror.Respond(c, http.StatusConflict, "alias "+a+" belongs to another merchant")
+		}
+		if err != nil {
+			logger.Error("exec error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		aliases = append(aliases, a)
+	}
+	m.Aliases = aliases
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("create successfully", zap.Int64("id", m.ID))
+	return c.JSON(http.StatusCreated, m)
+}
+
+type aliasReqBody struct {
+	Alias string `json:"alias"`
+}
+
+// POST /api/v1/merchants/:id/aliases
+// Merchants are added as transactions name them, so the alias may already
+// be a merchant: it is merged into this one, transactions included.
+func (h handler) AddAlias(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid merchant id")
+	}
+	var body aliasReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	alias := Normalize(body.Alias)
+	if alias == "" {
+		return apierror.Respond(c, http.StatusBadRequest, "alias is required")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRowContext(ctx, nameStmt, id).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "merchant not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if alias == name {
+		return apierror.Respond(c, http.StatusBadRequest, "alias is the merchant name")
+	}
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{mergeTxStmt, []any{id, name, alias}},
+		{mergeAliasStmt, []any{id, alias}},
+		{mergeDropStmt, []any{alias, id}},
+	} {
+		if _, err := tx.ExecContext(ctx, stmt.query, stmt.args...); err != nil {
+			logger.Error("exec error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, cAliasStmt, alias, id)
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "alias belongs to another merchant")
+	}
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("alias successfully", zap.Int64("id", id), zap.String("alias", alias))
+	return c.JSON(http.StatusCreated, map[string]any{"merchant_id": id, "alias": alias})
+}
+
+// POST /api/v1/spenders/:id/merchant-rules
+func (h handler) CreateRule(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var r Rule
+	if err := c.Bind(&r); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := r.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+	r.SpenderID = spenderID
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, cRuleStmt, r.SpenderID, r.Match, r.Pattern, r.Category, pq.Array(r.Tags), r.Priority).Scan(&r.ID, &r.CreatedAt)
+	})
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("create successfully", zap.Int64("id", r.ID))
+	return c.JSON(http.StatusCreated, r)
+}
+
+// GET /api/v1/spenders/:id/merchant-rules
+// In the order they are tried.
+func (h handler) GetRules(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	rules, err := listRules(ctx, h.db, rulesStmt, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// PUT /api/v1/merchant-rules/:id
+// Transactions already categorized keep their category.
+func (h handler) UpdateRule(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid rule id")
+	}
+
+	var r Rule
+	if err := c.Bind(&r); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := r.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+	r.ID = id
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, uRuleStmt, r.Match, r.Pattern, r.Category, pq.Array(r.Tags), r.Priority, r.ID).Scan(&r.SpenderID, &r.CreatedAt)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "rule not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("update successfully", zap.Int64("id", r.ID))
+	return c.JSON(http.StatusOK, r)
+}
+
+// DELETE /api/v1/merchant-rules/:id
+func (h handler) DeleteRule(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid rule id")
+	}
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, dRuleStmt, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "rule not found")
+	}
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("delete successfully", zap.Int64("id", id))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RuleMatch is a past transaction the tested rule applies to.
+type RuleMatch struct {
+	ID          int64  `json:"id"`
+	Date        string `json:"date"`
+	Merchant    string `json:"merchant"`
+	Note        string `json:"note"`
+	Category    string `json:"category"`
+	NewCategory string `json:"new_category"`
+}
+
+type RuleTest struct {
+	Matched       int         `json:"matched"`
+	Recategorized int         `json:"recategorized"`
+	Transactions  []RuleMatch `json:"transactions"`
+}
+
+// POST /api/v1/spenders/:id/merchant-rules/test
+// Runs a rule, saved or not, over the spender's past transactions without
+// changing them. Transactions from before merchants were recorded are
+// matched on their note. Lists the 50 most recent matches.
+func (h handler) TestRule(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var r Rule
+	if err := c.Bind(&r); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := r.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	// LIKE narrows it down, the rule itself decides
+	rows, err := h.db.QueryContext(ctx, candidates, spenderID, like.Contains(r.Pattern))
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	result := RuleTest{Transactions: []RuleMatch{}}
+	for rows.Next() {
+		var m RuleMatch
+		if err := rows.Scan(&m.ID, &m.Date, &m.Merchant, &m.Note, &m.Category); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		name := m.Merchant
+		if name == "" {
+			name = m.Note
+		}
+		if !r.Matches(Normalize(name)) {
+			continue
+		}
+
+		m.NewCategory, _ = r.apply(m.Category)
+		result.Matched++
+		if m.NewCategory != m.Category {
+			result.Recategorized++
+		}
+		if len(result.Transactions) < maxSamples {
+			result.Transactions = append(result.Transactions, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,246 @@
+package merchant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var createdAt = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newContext(method, body, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestGetAllMerchants(t *testing.T) {
+	t.Run("search by normalized name", func(t *testing.T) {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/?q=eleven", nil), rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(listStmt).WithArgs("%ELEVEN%").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "aliases"}).AddRow(5, "7-ELEVEN", "{7-11}"))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id": 5, "name": "7-ELEVEN", "aliases": ["7-11"]}]`, rec.Body.String())
+	})
+}
+
+func TestCreateMerchant(t *testing.T) {
+	t.Run("create merchant with aliases", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"name": "7-Eleven", "aliases": ["7-11 #12", "7-eleven", "Seven Eleven"]}`, "")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("7-ELEVEN").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectExec(cAliasStmt).WithArgs("7-11", int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(cAliasStmt).WithArgs("SEVEN ELEVEN", int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 5, "name": "7-ELEVEN", "aliases": ["7-11", "SEVEN ELEVEN"]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("create merchant failed on taken alias", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"name": "7-Eleven", "aliases": ["7-11"]}`, "")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("7-ELEVEN").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectExec(cAliasStmt).WithArgs("7-11", int64(5)).WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAddAlias(t *testing.T) {
+	t.Run("merge the merchant the alias was", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"alias": "7-11"}`, "5")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(nameStmt).WithArgs(int64(5)).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("7-ELEVEN"))
+		mock.ExpectExec(mergeTxStmt).WithArgs(int64(5), "7-ELEVEN", "7-11").WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(mergeAliasStmt).WithArgs(int64(5), "7-11").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(mergeDropStmt).WithArgs("7-11", int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(cAliasStmt).WithArgs("7-11", int64(5)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.AddAlias(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"merchant_id": 5, "alias": "7-11"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("add alias failed on unknown merchant", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"alias": "7-11"}`, "5")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(nameStmt).WithArgs(int64(5)).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.AddAlias(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestRules(t *testing.T) {
+	t.Run("create rule successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"pattern": "7-eleven", "category": "Food", "tags": ["#convenience"], "priority": 10}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cRuleStmt).WithArgs(int64(1), "contains", "7-ELEVEN", "Food", pq.Array([]string{"convenience"}), 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, createdAt))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.CreateRule(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 2, "spender_id": 1, "match": "contains", "pattern": "7-ELEVEN", "category": "Food", "tags": ["convenience"], "priority": 10, "created_at": "2024-06-01T00:00:00Z"}`, rec.Body.String())
+	})
+
+	t.Run("create rule failed on missing pattern", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"category": "Food"}`, "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.CreateRule(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("list rules in the order they are tried", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(rulesStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(ruleRows).
+			AddRow(2, 1, "contains", "7-ELEVEN", "Food", "{convenience}", 10, createdAt).
+			AddRow(1, 1, "prefix", "TOPS", "Groceries", "{}", 0, createdAt))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetRules(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+			{"id": 2, "spender_id": 1, "match": "contains", "pattern": "7-ELEVEN", "category": "Food", "tags": ["convenience"], "priority": 10, "created_at": "2024-06-01T00:00:00Z"},
+			{"id": 1, "spender_id": 1, "match": "prefix", "pattern": "TOPS", "category": "Groceries", "tags": [], "priority": 0, "created_at": "2024-06-01T00:00:00Z"}
+		]`, rec.Body.String())
+	})
+
+	t.Run("update rule failed on unknown id", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"match": "equals", "pattern": "tops", "category": "Groceries"}`, "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uRuleStmt).WithArgs("equals", "TOPS", "Groceries", pq.Array([]string{}), 0, int64(9)).
+			WillReturnRows(sqlmock.NewRows([]string{"spender_id", "created_at"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdateRule(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("delete rule successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "2")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dRuleStmt).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.DeleteRule(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
+
+func TestTestRule(t *testing.T) {
+	t.Run("report past transactions the rule matches", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"match": "prefix", "pattern": "7-eleven", "category": "Food"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(candidates).WithArgs(int64(1), "%7-ELEVEN%").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "date", "merchant", "note", "category"}).
+				AddRow(7, "2024-06-03", "7-ELEVEN", "", "uncategorized").
+				AddRow(6, "2024-06-02", "", "7-Eleven #0042 snacks", "Snacks").
+				AddRow(5, "2024-06-01", "SHOP 7-ELEVEN", "", "uncategorized"))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.TestRule(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"matched": 2, "recategorized": 1, "transactions": [
+			{"id": 7, "date": "2024-06-03", "merchant": "7-ELEVEN", "note": "", "category": "uncategorized", "new_category": "Food"},
+			{"id": 6, "date": "2024-06-02", "merchant": "", "note": "7-Eleven #0042 snacks", "category": "Snacks", "new_category": "Snacks"}
+		]}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,116 @@
+// Package merchant keeps one normalized name per merchant, with the other
+// spellings banks and slips use as aliases, and the spender's rules that
+// fill in a category and tags from the merchant of a transaction.
+package merchant
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
+)
+
+const (
+	MatchContains = "contains"
+	MatchEquals   = "equals"
+	MatchPrefix   = "prefix"
+
+	// Uncategorized is the category imports use when the file has none,
+	// rules may replace it.
+	Uncategorized = "uncategorized"
+)
+
+type Merchant struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// Rule reads "merchant contains 7-ELEVEN → category Food, tag convenience".
+// Higher priorities are tried first, the first rule that matches wins.
+type Rule struct {
+	ID        int64     `json:"id"`
+	SpenderID int64     `json:"spender_id"`
+	Match     string    `json:"match"`
+	Pattern   string    `json:"pattern"`
+	Category  string    `json:"category"`
+	Tags      []string  `json:"tags"`
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Normalize is the name merchants are stored and matched by: upper case,
+// single spaced and without a trailing branch number, so "7-Eleven #0123"
+// and "7-ELEVEN" are the same merchant.
+func Normalize(name string) string {
+	fields := strings.Fields(strings.ToUpper(name))
+	for len(fields) > 1 && isBranch(fields[len(fields)-1]) {
+		fields = fields[:len(fields)-1]
+	}
+	return strings.Join(fields, " ")
+}
+
+func isBranch(field string) bool {
+	field = strings.TrimPrefix(field, "#")
+	return field != "" && strings.IndexFunc(field, func(r rune) bool { return !unicode.IsDigit(r) }) == -1
+}
+
+// validate normalizes the rule in place.
+func (r *Rule) validate() error {
+	switch r.Match {
+	case "":
+		r.Match = MatchContains
+	case MatchContains, MatchEquals, MatchPrefix:
+	default:
+		return errors.New("match must be contains, equals or prefix")
+	}
+	r.Pattern = strings.Join(strings.Fields(strings.ToUpper(r.Pattern)), " ")
+	if r.Pattern == "" {
+		return errors.New("pattern is required")
+	}
+	r.Category = strings.TrimSpace(r.Category)
+	if len(r.Category) > 50 {
+		return errors.New("category is up to 50 characters")
+	}
+	tags, err := tag.NormalizeAll(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = tags
+	if r.Category == "" && len(r.Tags) == 0 {
+		return errors.New("a rule needs a category or tags to set")
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a normalized merchant name.
+func (r Rule) Matches(name string) bool {
+	switch r.Match {
+	case MatchEquals:
+		return name == r.Pattern
+	case MatchPrefix:
+		return strings.HasPrefix(name, r.Pattern)
+	default:
+		return strings.Contains(name, r.Pattern)
+	}
+}
+
+// apply fills category when it is still open and returns the rule's tags.
+func (r Rule) apply(category string) (string, []string) {
+	if r.Category != "" && (category == "" || category == Uncategorized) {
+		category = r.Category
+	}
+	return category, r.Tags
+}
+
+// first is the rule that decides for name, rules come by priority.
+func first(rules []Rule, name string) *Rule {
+	for i := range rules {
+		if rules[i].Matches(name) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
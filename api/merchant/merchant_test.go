@@ -0,0 +1,79 @@
+package merchant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("upper case, single spaced, without the branch", func(t *testing.T) {
+		for raw, want := range map[string]string{
+			"7-Eleven #0123":   "7-ELEVEN",
+			"  tops   market ": "TOPS MARKET",
+			"Starbucks 1042 2": "STARBUCKS",
+			"Café Amazon":      "CAFÉ AMAZON",
+			"1234":             "1234",
+			"ร้านข้าวมันไก่ 12": "ร้านข้าวมันไก่",
+			"": "",
+		} {
+			assert.Equal(t, want, Normalize(raw), raw)
+		}
+	})
+}
+
+func TestRule(t *testing.T) {
+	t.Run("validate defaults to contains and normalizes", func(t *testing.T) {
+		r := Rule{Pattern: " 7-eleven ", Category: " Food ", Tags: []string{"#Convenience"}}
+
+		err := r.validate()
+
+		assert.NoError(t, err)
+		assert.Equal(t, Rule{Match: MatchContains, Pattern: "7-ELEVEN", Category: "Food", Tags: []string{"convenience"}}, r)
+	})
+
+	t.Run("validate rejects rules that do nothing", func(t *testing.T) {
+		for _, r := range []Rule{
+			{Pattern: "TOPS", Match: "regex", Category: "Food"},
+			{Pattern: " ", Category: "Food"},
+			{Pattern: "TOPS"},
+			{Pattern: "TOPS", Tags: []string{"two words"}},
+		} {
+			assert.Error(t, r.validate(), r.Pattern)
+		}
+	})
+
+	t.Run("match kinds", func(t *testing.T) {
+		contains := Rule{Match: MatchContains, Pattern: "ELEVEN"}
+		equals := Rule{Match: MatchEquals, Pattern: "7-ELEVEN"}
+		prefix := Rule{Match: MatchPrefix, Pattern: "7-"}
+
+		assert.True(t, contains.Matches("7-ELEVEN"))
+		assert.True(t, equals.Matches("7-ELEVEN"))
+		assert.False(t, equals.Matches("7-ELEVEN EXPRESS"))
+		assert.True(t, prefix.Matches("7-ELEVEN"))
+		assert.False(t, prefix.Matches("SHOP 7-ELEVEN"))
+	})
+
+	t.Run("only fill an open category", func(t *testing.T) {
+		r := Rule{Category: "Food", Tags: []string{"convenience"}}
+
+		for category, want := range map[string]string{"": "Food", Uncategorized: "Food", "Snacks": "Snacks"} {
+			got, tags := r.apply(category)
+
+			assert.Equal(t, want, got)
+			assert.Equal(t, []string{"convenience"}, tags)
+		}
+	})
+
+	t.Run("first matching rule wins", func(t *testing.T) {
+		rules := []Rule{
+			{ID: 1, Match: MatchEquals, Pattern: "TOPS"},
+			{ID: 2, Match: MatchContains, Pattern: "ELEVEN"},
+			{ID: 3, Match: MatchPrefix, Pattern: "7-"},
+		}
+
+		assert.Equal(t, int64(2), first(rules, "7-ELEVEN").ID)
+		assert.Nil(t, first(rules, "LOTUS"))
+	})
+}
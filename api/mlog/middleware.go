@@ -25,6 +25,7 @@ func logParentID(c echo.Context, logger *zap.Logger) *zap.Logger {
 	if xParent == "" {
 		xParent = uuid.NewString()
 	}
+	c.Set(parentKey, xParent)
 	xSpan := uuid.NewString()
 	return logger.With(zap.String("parent-id", xParent),
 		zap.String("span-id", xSpan))
@@ -26,6 +26,18 @@ func TestLogMiddleware(t *testing.T) {
 	assert.IsType(t, &zap.Logger{}, L(ctx))
 }
 
+func TestParentID(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Parent-ID", "req-1")
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	err := Middleware(zap.NewNop())(func(c echo.Context) error { return nil })(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", ParentID(c))
+}
+
 func TestUnsetLogMiddleware(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
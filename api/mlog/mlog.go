@@ -5,7 +5,10 @@ import (
 	"go.uber.org/zap"
 )
 
-const key = "logger"
+const (
+	key       = "logger"
+	parentKey = "parent-id"
+)
 
 func L(c echo.Context) *zap.Logger {
 	switch logger := c.Get(key).(type) {
@@ -15,3 +18,10 @@ func L(c echo.Context) *zap.Logger {
 		return zap.NewNop()
 	}
 }
+
+// ParentID is the parent-id the request is logged with, empty outside the
+// middleware.
+func ParentID(c echo.Context) string {
+	id, _ := c.Get(parentKey).(string)
+	return id
+}
@@ -0,0 +1,26 @@
+// Package pagination is the page info of the list responses.
+package pagination
+
+// Info describes either an OFFSET page (current_page) or a keyset page
+// (next_cursor/prev_cursor). Keyset pages only count the total when asked
+// with include_total=true.
+type Info struct {
+	CurrentPage int    `json:"current_page"`
+	TotalPages  int    `json:"total_pages"`
+	PerPage     int    `json:"per_page"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	PrevCursor  string `json:"prev_cursor,omitempty"`
+	TotalCount  *int   `json:"total_count,omitempty"`
+}
+
+// Offset is OFFSET page page of limit rows out of total.
+func Offset(page, limit, total int) Info {
+	return Info{CurrentPage: page, PerPage: limit}.WithTotal(total)
+}
+
+// WithTotal fills in the counts.
+func (info Info) WithTotal(total int) Info {
+	info.TotalCount = &total
+	info.TotalPages = (total + info.PerPage - 1) / info.PerPage
+	return info
+}
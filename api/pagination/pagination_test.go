@@ -0,0 +1,12 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffset(t *testing.T) {
+	total := 41
+	assert.Equal(t, Info{CurrentPage: 2, TotalPages: 3, PerPage: 20, TotalCount: &total}, Offset(2, 20, 41))
+}
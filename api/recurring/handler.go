@@ -0,0 +1,303 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	columns = `id, spender_id, amount, category, transaction_type, note, frequency, interval_count, day_of_month, start_date, end_date, count, occurrences, next_run, active`
+
+	cStmt = `INSERT INTO recurring_transaction (spender_id, amount, category, transaction_type, note, frequency, interval_count, day_of_month, start_date, end_date, count, next_run, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id;`
+	uStmt = `UPDATE recurring_transaction SET amount = $1, category = $2, transaction_type = $3, note = $4, frequency = $5, interval_count = $6, day_of_month = $7, start_date = $8, end_date = $9, count = $10, next_run = $11, active = $12 WHERE id = $13 RETURNING spender_id, occurrences;`
+	dStmt = `DELETE FROM recurring_transaction WHERE id = $1;`
+	// locked so the scheduler can't materialize the old rule meanwhile
+	nextRunStmt = `SELECT next_run FROM recurring_transaction WHERE id = $1 FOR UPDATE`
+
+	listStmt = `SELECT ` + columns + ` FROM recurring_transaction WHERE spender_id = $1 ORDER BY id`
+	getStmt  = `SELECT ` + columns + ` FROM recurring_transaction WHERE id = $1`
+
+	defaultPreview = 5
+	maxPreview     = 50
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTemplate(row scanner) (Template, error) {
+	var t Template
+	var endDate, nextRun sql.NullTime
+	err := row.Scan(&t.ID, &t.SpenderID, &t.Amount, &t.Category, &t.TransactionType, &t.Note,
+		&t.Frequency, &t.Interval, &t.DayOfMonth, &t.StartDate, &endDate, &t.Count, &t.Occurrences, &nextRun, &t.Active)
+	if err != nil {
+		return Template{}, err
+	}
+	if endDate.Valid {
+		t.EndDate = &endDate.Time
+	}
+	if nextRun.Valid {
+		t.NextRun = &nextRun.Time
+	}
+	return t, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// schedule sets NextRun to the first occurrence after the given time and
+// deactivates the template when there is none left.
+func (t *Template) schedule(after time.Time) {
+	next, ok := t.Next(after)
+	t.Active = ok
+	t.NextRun = nil
+	if ok {
+		t.NextRun = &next
+	}
+}
+
+func bindTemplate(c echo.Context) (Template, error) {
+	var t Template
+	if err := c.Bind(&t); err != nil {
+		return Template{}, err
+	}
+	if t.Interval == 0 {
+		t.Interval = 1
+	}
+	return t, nil
+}
+
+// POST /api/v1/spenders/:id/recurring
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	t, err := bindTemplate(c)
+	if err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	t.SpenderID = spenderID
+	if err := t.Validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	// a start date in the past is backfilled by the scheduler
+	t.schedule(t.StartDate.Add(-time.Nanosecond))
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, cStmt, t.SpenderID, t.Amount, t.Category, t.TransactionType, t.Note,
+			t.Frequency, t.Interval, t.DayOfMonth, t.StartDate, nullTime(t.EndDate), t.Count, nullTime(t.NextRun), t.Active).Scan(&t.ID)
+	})
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("create successfully", zap.Int64("id", t.ID))
+	return c.JSON(http.StatusCreated, t)
+}
+
+// GET /api/v1/spenders/:id/recurring
+func (h handler) GetBySpenderID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	rows, err := h.db.QueryContext(ctx, listStmt, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, templates)
+}
+
+// PUT /api/v1/recurring/:id
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid recurring transaction id")
+	}
+
+	t, err := bindTemplate(c)
+	if err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	t.ID = id
+	if err := t.Validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	found, err := h.update(ctx, &t)
+	if err != nil {
+		logger.Error("update error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "recurring transaction not found")
+	}
+
+	logger.Info("update successfully", zap.Int64("id", t.ID))
+	return c.JSON(http.StatusOK, t)
+}
+
+// update reschedules t and saves it, it reports false when the id does not
+// exist. Occurrences already materialized are kept and the new rule applies
+// from now on, or from the current next run when the scheduler hasn't caught
+// up with it yet so the ones due are still created.
+func (h handler) update(ctx context.Context, t *Template) (bool, error) {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var due sql.NullTime
+	err = tx.QueryRowContext(ctx, nextRunStmt, t.ID).Scan(&due)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	after := time.Now()
+	if due.Valid && due.Time.Before(after) {
+		after = due.Time.Add(-time.Nanosecond)
+	}
+	if t.StartDate.After(after) {
+		after = t.StartDate.Add(-time.Nanosecond)
+	}
+	t.schedule(after)
+
+	err = tx.QueryRowContext(ctx, uStmt, t.Amount, t.Category, t.TransactionType, t.Note,
+		t.Frequency, t.Interval, t.DayOfMonth, t.StartDate, nullTime(t.EndDate), t.Count, nullTime(t.NextRun), t.Active, t.ID).Scan(&t.SpenderID, &t.Occurrences)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DELETE /api/v1/recurring/:id
+func (h handler) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid recurring transaction id")
+	}
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, dStmt, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "recurring transaction not found")
+	}
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("delete successfully", zap.Int64("id", id))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GET /api/v1/recurring/:id/preview?count=5
+func (h handler) Preview(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid recurring transaction id")
+	}
+
+	count := defaultPreview
+	if raw := c.QueryParam("count"); raw != "" {
+		count, err = strconv.Atoi(raw)
+		if err != nil || count < 1 || count > maxPreview {
+			return apierror.Respond(c, http.StatusBadRequest, "count must be between 1 and 50")
+		}
+	}
+
+	t, err := scanTemplate(h.db.QueryRowContext(ctx, getStmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "recurring transaction not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	occurrences := []time.Time{}
+	if t.Active && t.NextRun != nil {
+		occurrences = append(occurrences, *t.NextRun)
+		occurrences = append(occurrences, t.Upcoming(*t.NextRun, count-1)...)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"template":    t,
+		"occurrences": occurrences,
+	})
+}
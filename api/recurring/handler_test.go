@@ -0,0 +1,247 @@
+package recurring
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRecurring(t *testing.T) {
+	t.Run("create recurring transaction succesfully", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 15000, "category": "Rent", "transaction_type": "expense", "note": "Condo", "frequency": "monthly", "day_of_month": 1, "start_date": "2024-05-01T00:00:00Z"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		start := date("2024-05-01T00:00:00Z")
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).
+			WithArgs(1, 15000.0, "Rent", "expense", "Condo", Monthly, 1, 1, start, sql.NullTime{}, 0, sql.NullTime{Time: start, Valid: true}, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 7, "spender_id": 1, "amount": 15000, "category": "Rent", "transaction_type": "expense", "note": "Condo",
+			"frequency": "monthly", "interval": 1, "day_of_month": 1, "start_date": "2024-05-01T00:00:00Z",
+			"occurrences": 0, "next_run": "2024-05-01T00:00:00Z", "active": true}`, rec.Body.String())
+	})
+
+	t.Run("create recurring transaction failed on invalid rule", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 100, "transaction_type": "expense", "frequency": "hourly", "start_date": "2024-05-01T00:00:00Z"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "frequency must be one of daily, weekly, monthly or yearly"}`, rec.Body.String())
+	})
+}
+
+func TestGetRecurringBySpenderID(t *testing.T) {
+	t.Run("get recurring transactions by spender id succesfully", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows(templateColumns).
+			AddRow(7, 1, 15000.0, "Rent", "expense", "Condo", "monthly", 1, 1, date("2024-05-01T00:00:00Z"), nil, 0, 2, date("2024-07-01T00:00:00Z"), true)
+		mock.ExpectQuery(listStmt).WithArgs(1).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id": 7, "spender_id": 1, "amount": 15000, "category": "Rent", "transaction_type": "expense", "note": "Condo",
+			"frequency": "monthly", "interval": 1, "day_of_month": 1, "start_date": "2024-05-01T00:00:00Z",
+			"occurrences": 2, "next_run": "2024-07-01T00:00:00Z", "active": true}]`, rec.Body.String())
+	})
+
+	t.Run("get recurring transactions failed on database", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(listStmt).WithArgs(1).WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestUpdateRecurring(t *testing.T) {
+	t.Run("keep the occurrences the scheduler has not caught up with", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"amount": 60, "category": "Coffee", "transaction_type": "expense", "frequency": "weekly", "start_date": "2024-05-01T08:00:00Z"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("7")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		due := date("2024-05-08T08:00:00Z")
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(nextRunStmt).WithArgs(int64(7)).WillReturnRows(sqlmock.NewRows([]string{"next_run"}).AddRow(due))
+		mock.ExpectQuery(uStmt).
+			WithArgs(60.0, "Coffee", "expense", "", Weekly, 1, 0, date("2024-05-01T08:00:00Z"), sql.NullTime{}, 0, sql.NullTime{Time: due, Valid: true}, true, int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"spender_id", "occurrences"}).AddRow(1, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"next_run":"2024-05-08T08:00:00Z"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update unknown recurring transaction", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"amount": 60, "category": "Coffee", "transaction_type": "expense", "frequency": "weekly", "start_date": "2024-05-01T08:00:00Z"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("99")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(nextRunStmt).WithArgs(int64(99)).WillReturnRows(sqlmock.NewRows([]string{"next_run"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteRecurring(t *testing.T) {
+	t.Run("delete unknown recurring transaction", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("99")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(99)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestPreviewRecurring(t *testing.T) {
+	t.Run("preview upcoming occurrences", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?count=3", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("7")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows(templateColumns).
+			AddRow(7, 1, 50.0, "Coffee", "expense", "", "weekly", 1, 0, date("2024-05-06T08:00:00Z"), nil, 0, 1, date("2024-05-13T08:00:00Z"), true)
+		mock.ExpectQuery(getStmt).WithArgs(int64(7)).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Preview(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"occurrences":["2024-05-13T08:00:00Z","2024-05-20T08:00:00Z","2024-05-27T08:00:00Z"]`)
+	})
+
+	t.Run("preview rejects too many occurrences", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?count=500", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("7")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Preview(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,142 @@
+package recurring
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// Rule describes when a recurring transaction happens, RRULE style:
+// every Interval days/weeks/months/years from StartDate, optionally pinned
+// to DayOfMonth and bounded by EndDate or Count occurrences.
+type Rule struct {
+	Frequency  string     `json:"frequency"`
+	Interval   int        `json:"interval"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	StartDate  time.Time  `json:"start_date"`
+	EndDate    *time.Time `json:"end_date,omitempty"`
+	Count      int        `json:"count,omitempty"`
+}
+
+// Template is a stored recurring transaction that the scheduler
+// materializes into rows of the transaction table.
+type Template struct {
+	ID              int64   `json:"id"`
+	SpenderID       int     `json:"spender_id"`
+	Amount          float64 `json:"amount"`
+	Category        string  `json:"category"`
+	TransactionType string  `json:"transaction_type"`
+	Note            string  `json:"note"`
+	Rule
+	Occurrences int        `json:"occurrences"`
+	NextRun     *time.Time `json:"next_run"`
+	Active      bool       `json:"active"`
+}
+
+func (r Rule) Validate() error {
+	switch r.Frequency {
+	case Daily, Weekly, Monthly, Yearly:
+	default:
+		return errors.New("frequency must be one of daily, weekly, monthly or yearly")
+	}
+	if r.Interval < 1 {
+		return errors.New("interval must be at least 1")
+	}
+	if r.DayOfMonth < 0 || r.DayOfMonth > 31 {
+		return errors.New("day_of_month must be between 1 and 31")
+	}
+	if r.DayOfMonth != 0 && r.Frequency != Monthly && r.Frequency != Yearly {
+		return errors.New("day_of_month is only allowed for monthly or yearly frequency")
+	}
+	if r.StartDate.IsZero() {
+		return errors.New("start_date is required")
+	}
+	if r.EndDate != nil && r.EndDate.Before(r.StartDate) {
+		return errors.New("end_date must not be before start_date")
+	}
+	if r.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	return nil
+}
+
+func (t Template) Validate() error {
+	if t.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if t.TransactionType != "income" && t.TransactionType != "expense" {
+		return errors.New("transaction_type must be income or expense")
+	}
+	return t.Rule.Validate()
+}
+
+// at returns the n-th candidate date counted from StartDate, ignoring the
+// Count and EndDate bounds. Day of month is clamped to the month length so
+// a rule on the 31st falls on the last day of shorter months.
+func (r Rule) at(n int) time.Time {
+	s := r.StartDate
+	step := n * r.Interval
+	switch r.Frequency {
+	case Daily:
+		return s.AddDate(0, 0, step)
+	case Weekly:
+		return s.AddDate(0, 0, 7*step)
+	case Yearly:
+		return r.pinDay(s.Year()+step, s.Month())
+	default:
+		return r.pinDay(s.Year(), s.Month()+time.Month(step))
+	}
+}
+
+func (r Rule) pinDay(year int, month time.Month) time.Time {
+	s := r.StartDate
+	first := time.Date(year, month, 1, s.Hour(), s.Minute(), s.Second(), s.Nanosecond(), s.Location())
+	day := r.DayOfMonth
+	if day == 0 {
+		day = s.Day()
+	}
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Next returns the first occurrence strictly after the given time, or false
+// when the rule has no more occurrences.
+func (r Rule) Next(after time.Time) (time.Time, bool) {
+	n := 0
+	if r.at(0).Before(r.StartDate) {
+		// day_of_month lies before the start day, the first occurrence is next period
+		n = 1
+	}
+	for k := 0; r.Count == 0 || k < r.Count; k, n = k+1, n+1 {
+		occ := r.at(n)
+		if r.EndDate != nil && occ.After(*r.EndDate) {
+			return time.Time{}, false
+		}
+		if occ.After(after) {
+			return occ, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Upcoming lists at most limit occurrences strictly after the given time.
+func (r Rule) Upcoming(after time.Time, limit int) []time.Time {
+	occs := []time.Time{}
+	for len(occs) < limit {
+		occ, ok := r.Next(after)
+		if !ok {
+			break
+		}
+		occs = append(occs, occ)
+		after = occ
+	}
+	return occs
+}
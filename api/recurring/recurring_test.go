@@ -0,0 +1,94 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+func TestRuleNext(t *testing.T) {
+	t.Run("weekly every two weeks", func(t *testing.T) {
+		r := Rule{Frequency: Weekly, Interval: 2, StartDate: date("2024-05-01T09:00:00Z")}
+
+		next, ok := r.Next(date("2024-05-01T09:00:00Z"))
+
+		assert.True(t, ok)
+		assert.Equal(t, date("2024-05-15T09:00:00Z"), next)
+	})
+
+	t.Run("monthly on the 31st is clamped to the end of shorter months", func(t *testing.T) {
+		r := Rule{Frequency: Monthly, Interval: 1, DayOfMonth: 31, StartDate: date("2024-01-31T00:00:00Z")}
+
+		occs := r.Upcoming(date("2024-01-01T00:00:00Z"), 3)
+
+		assert.Equal(t, []time.Time{
+			date("2024-01-31T00:00:00Z"),
+			date("2024-02-29T00:00:00Z"),
+			date("2024-03-31T00:00:00Z"),
+		}, occs)
+	})
+
+	t.Run("monthly day before the start day begins the following month", func(t *testing.T) {
+		r := Rule{Frequency: Monthly, Interval: 1, DayOfMonth: 25, StartDate: date("2024-04-28T00:00:00Z")}
+
+		next, ok := r.Next(date("2024-04-01T00:00:00Z"))
+
+		assert.True(t, ok)
+		assert.Equal(t, date("2024-05-25T00:00:00Z"), next)
+	})
+
+	t.Run("stops after count occurrences", func(t *testing.T) {
+		r := Rule{Frequency: Daily, Interval: 1, Count: 2, StartDate: date("2024-05-01T00:00:00Z")}
+
+		occs := r.Upcoming(date("2024-04-01T00:00:00Z"), 5)
+
+		assert.Equal(t, []time.Time{date("2024-05-01T00:00:00Z"), date("2024-05-02T00:00:00Z")}, occs)
+	})
+
+	t.Run("stops after end date", func(t *testing.T) {
+		end := date("2026-01-01T00:00:00Z")
+		r := Rule{Frequency: Yearly, Interval: 1, StartDate: date("2024-02-29T00:00:00Z"), EndDate: &end}
+
+		occs := r.Upcoming(date("2024-01-01T00:00:00Z"), 5)
+
+		assert.Equal(t, []time.Time{date("2024-02-29T00:00:00Z"), date("2025-02-28T00:00:00Z")}, occs)
+	})
+}
+
+func TestTemplateValidate(t *testing.T) {
+	valid := Template{Amount: 100, TransactionType: "expense", Rule: Rule{Frequency: Monthly, Interval: 1, StartDate: date("2024-05-01T00:00:00Z")}}
+
+	tests := []struct {
+		name   string
+		modify func(*Template)
+		err    string
+	}{
+		{"valid", func(*Template) {}, ""},
+		{"unknown frequency", func(t *Template) { t.Frequency = "hourly" }, "frequency must be one of daily, weekly, monthly or yearly"},
+		{"zero amount", func(t *Template) { t.Amount = 0 }, "amount must be greater than zero"},
+		{"bad type", func(t *Template) { t.TransactionType = "gift" }, "transaction_type must be income or expense"},
+		{"day of month on weekly", func(t *Template) { t.Frequency = Weekly; t.DayOfMonth = 3 }, "day_of_month is only allowed for monthly or yearly frequency"},
+		{"end before start", func(t *Template) { end := date("2024-04-01T00:00:00Z"); t.EndDate = &end }, "end_date must not be before start_date"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := valid
+			tc.modify(&tmpl)
+
+			err := tmpl.Validate()
+
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"go.uber.org/zap"
+)
+
+const (
+	// lockKey is the Postgres advisory lock shared by every replica, only
+	// the replica holding it materializes occurrences on a given tick.
+	lockKey = 26_001
+
+	// maxCatchUp bounds how many past occurrences of one template are
+	// backfilled per tick, the rest are picked up by following ticks.
+	maxCatchUp = 100
+
+	lockStmt   = `SELECT pg_try_advisory_xact_lock($1)`
+	dueStmt    = `SELECT ` + columns + ` FROM recurring_transaction WHERE active AND next_run <= $1 ORDER BY next_run FOR UPDATE`
+	insertStmt = `INSERT INTO transaction (date, amount, category, transaction_type, spender_id, note, image_url, recurring_id) VALUES ($1, $2, $3, $4, $5, $6, '', $7) ON CONFLICT (recurring_id, date) DO NOTHING;`
+	advStmt    = `UPDATE recurring_transaction SET occurrences = $1, next_run = $2, active = $3 WHERE id = $4;`
+)
+
+type Scheduler struct {
+	db       *sql.DB
+	logger   *zap.Logger
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewScheduler(db *sql.DB, logger *zap.Logger, interval time.Duration) *Scheduler {
+	return &Scheduler{db: db, logger: logger, interval: interval, now: time.Now}
+}
+
+// Run materializes due occurrences every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Tick(ctx); err != nil {
+			s.logger.Error("recurring scheduler tick failed", zap.Error(err))
+		} else if n > 0 {
+			s.logger.Info("recurring transactions materialized", zap.Int("count", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick inserts every occurrence that is due and returns how many were
+// created. It runs in a single DB transaction guarded by an advisory lock,
+// and the unique (recurring_id, date) index makes re-running it harmless.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	tx, err := audit.BeginTx(audit.WithActor(ctx, audit.Actor{Type: audit.System, ID: "recurring"}, ""), s.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, lockStmt, lockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		// another replica is working on it
+		return 0, tx.Commit()
+	}
+
+	now := s.now()
+	due, err := dueTemplates(ctx, tx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, t := range due {
+		n, err := materialize(ctx, tx, &t, now)
+		if err != nil {
+			return 0, err
+		}
+		created += n
+	}
+
+	return created, tx.Commit()
+}
+
+func dueTemplates(ctx context.Context, tx *sql.Tx, now time.Time) ([]Template, error) {
+	rows, err := tx.QueryContext(ctx, dueStmt, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, t)
+	}
+	return due, rows.Err()
+}
+
+func materialize(ctx context.Context, tx *sql.Tx, t *Template, now time.Time) (int, error) {
+	created := 0
+	for i := 0; i < maxCatchUp && t.Active && !t.NextRun.After(now); i++ {
+		occ := *t.NextRun
+		res, err := tx.ExecContext(ctx, insertStmt, occ, t.Amount, t.Category, t.TransactionType, t.SpenderID, t.Note, t.ID)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			created++
+		}
+		t.Occurrences++
+		t.schedule(occ)
+	}
+
+	_, err := tx.ExecContext(ctx, advStmt, t.Occurrences, nullTime(t.NextRun), t.Active, t.ID)
+	return created, err
+}
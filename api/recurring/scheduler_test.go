@@ -0,0 +1,57 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/stretchr/testify/assert"
+)
+
+var templateColumns = []string{"id", "spender_id", "amount", "category", "transaction_type", "note", "frequency", "interval_count", "day_of_month", "start_date", "end_date", "count", "occurrences", "next_run", "active"}
+
+func TestSchedulerTick(t *testing.T) {
+	t.Run("materialize due occurrences and advance the template", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		now := date("2024-05-16T00:00:00Z")
+		audittest.ExpectBegin(mock, audit.Actor{Type: audit.System, ID: "recurring"})
+		mock.ExpectQuery(lockStmt).WithArgs(lockKey).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+		mock.ExpectQuery(dueStmt).WithArgs(now).WillReturnRows(sqlmock.NewRows(templateColumns).
+			AddRow(7, 1, 50.0, "Coffee", "expense", "", "weekly", 1, 0, date("2024-05-01T08:00:00Z"), nil, 0, 0, date("2024-05-01T08:00:00Z"), true))
+		mock.ExpectExec(insertStmt).WithArgs(date("2024-05-01T08:00:00Z"), 50.0, "Coffee", "expense", 1, "", int64(7)).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(insertStmt).WithArgs(date("2024-05-08T08:00:00Z"), 50.0, "Coffee", "expense", 1, "", int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(insertStmt).WithArgs(date("2024-05-15T08:00:00Z"), 50.0, "Coffee", "expense", 1, "", int64(7)).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec(advStmt).WithArgs(3, sql.NullTime{Time: date("2024-05-22T08:00:00Z"), Valid: true}, true, int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		s := NewScheduler(db, nil, time.Minute)
+		s.now = func() time.Time { return now }
+		n, err := s.Tick(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("skip when another replica holds the lock", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audit.Actor{Type: audit.System, ID: "recurring"})
+		mock.ExpectQuery(lockStmt).WithArgs(lockKey).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+		mock.ExpectCommit()
+
+		s := NewScheduler(db, nil, time.Minute)
+		n, err := s.Tick(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,86 @@
+package spender
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/like"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	listStmt  = `SELECT id, name, email FROM spender WHERE deleted_at IS NULL AND (name ILIKE $1 OR email ILIKE $1) ORDER BY %s LIMIT $2 OFFSET $3`
+	countStmt = `SELECT COUNT(*) FROM spender WHERE deleted_at IS NULL AND (name ILIKE $1 OR email ILIKE $1)`
+)
+
+// sortColumns are the orders GET /spenders accepts, "-" in front sorts
+// descending. Ties are broken by id.
+var sortColumns = map[string]string{
+	"id":    "id",
+	"name":  "lower(name)",
+	"email": "lower(email)",
+}
+
+type List struct {
+	Spenders   []Spender       `json:"spenders"`
+	Pagination pagination.Info `json:"pagination"`
+}
+
+type listQuery struct {
+	q       string
+	orderBy string
+	page    int
+	limit   int
+}
+
+func parseList(c echo.Context) (listQuery, error) {
+	lq := listQuery{q: strings.TrimSpace(c.QueryParam("q")), page: 1, limit: defaultLimit}
+
+	if s := c.QueryParam("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return lq, errors.New("page must be a positive number")
+		}
+		lq.page = page
+	}
+	if s := c.QueryParam("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return lq, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		lq.limit = limit
+	}
+
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "id"
+	}
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		sort, dir = sort[1:], "DESC"
+	}
+	column, ok := sortColumns[sort]
+	if !ok {
+		return lq, errors.New("sort must be id, name or email, with - for descending")
+	}
+	lq.orderBy = column + " " + dir
+	if column != "id" {
+		lq.orderBy += ", id " + dir
+	}
+	return lq, nil
+}
+
+// pattern matches q anywhere in name or email, taken literally.
+func (lq listQuery) pattern() string {
+	return like.Contains(lq.q)
+}
+
+func (lq listQuery) pagination(total int) pagination.Info {
+	return pagination.Offset(lq.page, lq.limit, total)
+}
@@ -0,0 +1,48 @@
+package spender
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	parse := func(target string) (listQuery, error) {
+		e := echo.New()
+		return parseList(e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), httptest.NewRecorder()))
+	}
+
+	t.Run("default to the first page by id", func(t *testing.T) {
+		lq, err := parse("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, listQuery{orderBy: "id ASC", page: 1, limit: defaultLimit}, lq)
+	})
+
+	t.Run("break ties by id", func(t *testing.T) {
+		lq, err := parse("/?sort=email")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "lower(email) ASC, id ASC", lq.orderBy)
+	})
+
+	t.Run("reject bad pages and limits", func(t *testing.T) {
+		for _, target := range []string{"/?page=0", "/?page=x", "/?limit=0", "/?limit=101", "/?sort=-"} {
+			_, err := parse(target)
+
+			assert.Error(t, err, target)
+		}
+	})
+
+	t.Run("count pages", func(t *testing.T) {
+		lq := listQuery{page: 1, limit: 20}
+
+		zero := 0
+		assert.Equal(t, pagination.Info{CurrentPage: 1, TotalPages: 0, PerPage: 20, TotalCount: &zero}, lq.pagination(0))
+		assert.Equal(t, 3, lq.pagination(41).TotalPages)
+	})
+}
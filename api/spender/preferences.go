@@ -0,0 +1,229 @@
+package spender
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // spenders pick any IANA zone, the host may not ship them
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Periods the summaries can be cut by.
+const (
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+	Year  = "year"
+)
+
+const (
+	prefStmt  = `SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`
+	uPrefStmt = `INSERT INTO spender_preference (spender_id, timezone, currency, locale, week_start, month_start_day) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (spender_id) DO UPDATE SET timezone = EXCLUDED.timezone, currency = EXCLUDED.currency, locale = EXCLUDED.locale, week_start = EXCLUDED.week_start, month_start_day = EXCLUDED.month_start_day;`
+)
+
+var (
+	currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	localeTag    = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	}
+)
+
+// Preferences say how a spender's periods and amounts read. Locale is how
+// the statement writes dates and amounts. MonthStartDay is for salaried
+// spenders whose month runs from payday to payday, with 25 the month of May
+// is 25 May to 24 June.
+type Preferences struct {
+	SpenderID     int64  `json:"spender_id"`
+	Timezone      string `json:"timezone"`
+	Currency      string `json:"currency"`
+	Locale        string `json:"locale"`
+	WeekStart     string `json:"week_start"`
+	MonthStartDay int    `json:"month_start_day"`
+}
+
+// DefaultPreferences apply until the spender saves their own.
+func DefaultPreferences(spenderID int64) Preferences {
+	return Preferences{SpenderID: spenderID, Timezone: "UTC", Currency: "THB", Locale: "th-TH", WeekStart: "monday", MonthStartDay: 1}
+}
+
+// validate fills the fields left out with the defaults.
+func (p *Preferences) validate() error {
+	def := DefaultPreferences(p.SpenderID)
+	for _, f := range []struct {
+		v   *string
+		def string
+	}{{&p.Timezone, def.Timezone}, {&p.Currency, def.Currency}, {&p.Locale, def.Locale}, {&p.WeekStart, def.WeekStart}} {
+		if *f.v = strings.TrimSpace(*f.v); *f.v == "" {
+			*f.v = f.def
+		}
+	}
+	if p.MonthStartDay == 0 {
+		p.MonthStartDay = def.MonthStartDay
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil || strings.EqualFold(p.Timezone, "Local") {
+		return errors.New("timezone must be an IANA zone like Asia/Bangkok")
+	}
+	p.Currency = strings.ToUpper(p.Currency)
+	if !currencyCode.MatchString(p.Currency) {
+		return errors.New("currency must be an ISO 4217 code like THB")
+	}
+	if !localeTag.MatchString(p.Locale) {
+		return errors.New("locale must be a language tag like th-TH")
+	}
+	p.WeekStart = strings.ToLower(p.WeekStart)
+	if _, ok := weekdays[p.WeekStart]; !ok {
+		return errors.New("week_start must be a day of the week like monday")
+	}
+	// every month has a 28th
+	if p.MonthStartDay < 1 || p.MonthStartDay > 28 {
+		return errors.New("month_start_day must be between 1 and 28")
+	}
+	return nil
+}
+
+// Location is the spender's time zone, UTC when it can't be loaded.
+func (p Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Period is the day, week, month or year containing at, as seen by the
+// spender. to is exclusive.
+func (p Preferences) Period(kind string, at time.Time) (from, to time.Time, err error) {
+	loc := p.Location()
+	y, m, d := at.In(loc).Date()
+	switch kind {
+	case Day:
+		from = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 0, 1), nil
+	case Week:
+		from = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		back := (int(from.Weekday()) - int(weekdays[p.WeekStart]) + 7) % 7
+		from = from.AddDate(0, 0, -back)
+		return from, from.AddDate(0, 0, 7), nil
+	case Month:
+		if d < p.MonthStartDay {
+			m--
+		}
+		from, to = p.Month(y, m)
+		return from, to, nil
+	case Year:
+		from = time.Date(y, time.January, p.MonthStartDay, 0, 0, 0, 0, loc)
+		if at.Before(from) {
+			from = from.AddDate(-1, 0, 0)
+		}
+		return from, from.AddDate(1, 0, 0), nil
+	}
+	return from, to, fmt.Errorf("period must be %s, %s, %s or %s", Day, Week, Month, Year)
+}
+
+// Month is the spender's month labelled y-m, it starts on their month
+// start day.
+func (p Preferences) Month(y int, m time.Month) (from, to time.Time) {
+	from = time.Date(y, m, p.MonthStartDay, 0, 0, 0, 0, p.Location())
+	return from, from.AddDate(0, 1, 0)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// LoadPreferences returns the spender's preferences, the defaults when
+// they never saved any.
+func LoadPreferences(ctx context.Context, q queryRower, spenderID int64) (Preferences, error) {
+	p := Preferences{SpenderID: spenderID}
+	err := q.QueryRowContext(ctx, prefStmt, spenderID).Scan(&p.Timezone, &p.Currency, &p.Locale, &p.WeekStart, &p.MonthStartDay)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences(spenderID), nil
+	}
+	return p, err
+}
+
+// GET /api/v1/spenders/:id/preferences
+func (h handler) GetPreferences(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var ok bool
+	if err := h.db.QueryRowContext(ctx, existsStmt, id).Scan(&ok); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !ok {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+
+	p, err := LoadPreferences(ctx, h.db, id)
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// PUT /api/v1/spenders/:id/preferences
+// Fields left out go back to their default.
+func (h handler) UpdatePreferences(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var p Preferences
+	if err := c.Bind(&p); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	p.SpenderID = id
+	if err := p.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	var ok bool
+	if err := h.db.QueryRowContext(ctx, existsStmt, id).Scan(&ok); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !ok {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, uPrefStmt, p.SpenderID, p.Timezone, p.Currency, p.Locale, p.WeekStart, p.MonthStartDay)
+		return err
+	})
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("update preferences successfully", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,125 @@
+package spender
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var prefColumns = []string{"timezone", "currency", "locale", "week_start", "month_start_day"}
+
+func TestPreferences(t *testing.T) {
+	t.Run("fill left out fields with the defaults", func(t *testing.T) {
+		p := Preferences{SpenderID: 1, Timezone: "Asia/Tokyo", Currency: "jpy"}
+
+		err := p.validate()
+
+		assert.NoError(t, err)
+		assert.Equal(t, Preferences{SpenderID: 1, Timezone: "Asia/Tokyo", Currency: "JPY", Locale: "th-TH", WeekStart: "monday", MonthStartDay: 1}, p)
+	})
+
+	t.Run("reject unknown values", func(t *testing.T) {
+		for _, p := range []Preferences{
+			{Timezone: "Mars/Olympus"},
+			{Timezone: "Local"},
+			{Currency: "BAHT"},
+			{Locale: "Thai"},
+			{WeekStart: "someday"},
+			{MonthStartDay: 31},
+		} {
+			assert.Error(t, p.validate(), "%+v", p)
+		}
+	})
+
+	t.Run("periods in the spender's calendar", func(t *testing.T) {
+		berlin, _ := time.LoadLocation("Europe/Berlin")
+		p := Preferences{Timezone: "Europe/Berlin", WeekStart: "sunday", MonthStartDay: 25}
+		// Wednesday 24 April 2024, 23:30 UTC is already the 25th in Berlin
+		at := time.Date(2024, 4, 24, 23, 30, 0, 0, time.UTC)
+
+		for kind, want := range map[string][2]time.Time{
+			Day:   {time.Date(2024, 4, 25, 0, 0, 0, 0, berlin), time.Date(2024, 4, 26, 0, 0, 0, 0, berlin)},
+			Week:  {time.Date(2024, 4, 21, 0, 0, 0, 0, berlin), time.Date(2024, 4, 28, 0, 0, 0, 0, berlin)},
+			Month: {time.Date(2024, 4, 25, 0, 0, 0, 0, berlin), time.Date(2024, 5, 25, 0, 0, 0, 0, berlin)},
+			Year:  {time.Date(2024, 1, 25, 0, 0, 0, 0, berlin), time.Date(2025, 1, 25, 0, 0, 0, 0, berlin)},
+		} {
+			from, to, err := p.Period(kind, at)
+
+			assert.NoError(t, err)
+			assert.True(t, want[0].Equal(from), "%s from %s", kind, from)
+			assert.True(t, want[1].Equal(to), "%s to %s", kind, to)
+		}
+
+		_, _, err := p.Period("fortnight", at)
+		assert.Error(t, err)
+	})
+
+	t.Run("a month before the start day belongs to the previous one", func(t *testing.T) {
+		p := Preferences{Timezone: "UTC", MonthStartDay: 25}
+
+		from, to, err := p.Period(Month, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), from)
+		assert.Equal(t, time.Date(2024, 1, 25, 0, 0, 0, 0, time.UTC), to)
+	})
+}
+
+func TestPreferencesHandler(t *testing.T) {
+	t.Run("get the defaults before anything is saved", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/", "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(prefStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows(prefColumns))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetPreferences(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"spender_id": 1, "timezone": "UTC", "currency": "THB", "locale": "th-TH", "week_start": "monday", "month_start_day": 1}`, rec.Body.String())
+	})
+
+	t.Run("save preferences", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"timezone": "Asia/Bangkok", "currency": "thb", "locale": "en-GB", "week_start": "Sunday", "month_start_day": 25}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(uPrefStmt).WithArgs(int64(1), "Asia/Bangkok", "THB", "en-GB", "sunday", 25).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdatePreferences(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"spender_id": 1, "timezone": "Asia/Bangkok", "currency": "THB", "locale": "en-GB", "week_start": "sunday", "month_start_day": 25}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("save preferences failed on unknown spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"timezone": "Asia/Bangkok"}`, "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existsStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdatePreferences(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -2,12 +2,20 @@ package spender
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/mail"
 	"strconv"
+	"strings"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -28,11 +36,67 @@ func New(cfg config.FeatureFlag, db *sql.DB) *handler {
 
 const (
 	cStmt = `INSERT INTO spender (name, email) VALUES ($1, $2) RETURNING id;`
+	uStmt = `UPDATE spender SET name = $1, email = $2 WHERE id = $3 AND deleted_at IS NULL RETURNING id, name, email;`
+	pStmt = `UPDATE spender SET name = COALESCE($1, name), email = COALESCE($2, email) WHERE id = $3 AND deleted_at IS NULL RETURNING id, name, email;`
+	dStmt = `UPDATE spender SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;`
+
+	existsStmt  = `SELECT EXISTS (SELECT 1 FROM spender WHERE id = $1 AND deleted_at IS NULL)`
+	txCountStmt = `SELECT COUNT(*) FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL`
+	// a transaction through the spender's accounts, or in a wallet the new
+	// spender may not record into, is left behind
+	reassignStmt  = `UPDATE transaction t SET spender_id = $2 WHERE spender_id = $1 AND deleted_at IS NULL AND account_id IS NULL AND to_account_id IS NULL AND (wallet_id IS NULL OR EXISTS (SELECT 1 FROM wallet_member m WHERE m.wallet_id = t.wallet_id AND m.spender_id = $2 AND m.role IN ('owner', 'editor')));`
+	deleteTxStmt  = `UPDATE transaction SET deleted_at = now() WHERE spender_id = $1 AND deleted_at IS NULL;`
+	stopRecurStmt = `UPDATE recurring_transaction SET active = false WHERE spender_id = $1 AND active;`
+)
+
+// What DELETE /spenders/:id does with the spender's transactions.
+const (
+	// Block refuses to delete a spender who still has transactions.
+	Block = "block"
+	// Reassign moves the transactions to the spender given in "to". It is
+	// refused when one of them uses the spender's accounts or a wallet "to"
+	// cannot record into.
+	Reassign = "reassign"
+	// SoftDelete deletes the transactions along with the spender.
+	SoftDelete = "delete"
 )
 
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func validName(name string) error {
+	if name == "" {
+		return apierror.Field("name", "name is required")
+	}
+	if len(name) > 255 {
+		return apierror.Field("name", "name is up to 255 characters")
+	}
+	return nil
+}
+
+// validEmail accepts a bare address, "Hong <hong@jot.ok>" is not one.
+func validEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return apierror.Field("email", "email must be a valid address")
+	}
+	return nil
+}
+
+// validate trims the spender in place.
+func (sp *Spender) validate() error {
+	sp.Name, sp.Email = strings.TrimSpace(sp.Name), strings.TrimSpace(sp.Email)
+	if err := validName(sp.Name); err != nil {
+		return err
+	}
+	return validEmail(sp.Email)
+}
+
 func (h handler) Create(c echo.Context) error {
 	if !h.flag.EnableCreateSpender {
-		return c.JSON(http.StatusForbidden, "create new spender feature is disabled")
+		return apierror.Respond(c, http.StatusForbidden, "create new spender feature is disabled")
 	}
 
 	logger := mlog.L(c)
@@ -41,14 +105,22 @@ func (h handler) Create(c echo.Context) error {
 	err := c.Bind(&sp)
 	if err != nil {
 		logger.Error("bad request body", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return apierror.Invalid(c, err)
+	}
+	if err := sp.validate(); err != nil {
+		return apierror.Invalid(c, err)
 	}
 
 	var lastInsertId int64
-	err = h.db.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&lastInsertId)
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&lastInsertId)
+	})
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "email is already used by another spender")
+	}
 	if err != nil {
 		logger.Error("query row error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apierror.Internal(c)
 	}
 
 	logger.Info("create successfully", zap.Int64("id", lastInsertId))
@@ -56,29 +128,46 @@ func (h handler) Create(c echo.Context) error {
 	return c.JSON(http.StatusCreated, sp)
 }
 
+// GET /api/v1/spenders?q=hong&sort=-name&page=2&limit=20
+// q searches name and email.
 func (h handler) GetAll(c echo.Context) error {
 	logger := mlog.L(c)
 	ctx := c.Request().Context()
 
-	rows, err := h.db.QueryContext(ctx, `SELECT id, name, email FROM spender`)
+	lq, err := parseList(c)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(listStmt, lq.orderBy), lq.pattern(), lq.limit, (lq.page-1)*lq.limit)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apierror.Internal(c)
 	}
 	defer rows.Close()
 
-	var sps []Spender
+	sps := []Spender{}
 	for rows.Next() {
 		var sp Spender
 		err := rows.Scan(&sp.ID, &sp.Name, &sp.Email)
 		if err != nil {
 			logger.Error("scan error", zap.Error(err))
-			return c.JSON(http.StatusInternalServerError, err.Error())
+			return apierror.Internal(c)
 		}
 		sps = append(sps, sp)
 	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	var total int
+	if err := h.db.QueryRowContext(ctx, countStmt, lq.pattern()).Scan(&total); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
 
-	return c.JSON(http.StatusOK, sps)
+	return c.JSON(http.StatusOK, List{Spenders: sps, Pagination: lq.pagination(total)})
 }
 
 func (h handler) GetByID(c echo.Context) error {
@@ -89,15 +178,200 @@ func (h handler) GetByID(c echo.Context) error {
 
 	//check if id is valid
 	if _, err := strconv.Atoi(id); err != nil {
-		return c.JSON(http.StatusBadRequest, "invalid spender id")
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
 	}
 
 	var sp Spender
-	err := h.db.QueryRowContext(ctx, `SELECT id, name, email FROM spender WHERE id = $1`, id).Scan(&sp.ID, &sp.Name, &sp.Email)
+	err := h.db.QueryRowContext(ctx, `SELECT id, name, email FROM spender WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&sp.ID, &sp.Name, &sp.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
 	if err != nil {
 		logger.Error("query row error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, "Please check server logs")
+		return apierror.Internal(c)
 	}
 
 	return c.JSON(http.StatusOK, sp)
 }
+
+// PUT /api/v1/spenders/:id
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var sp Spender
+	if err := c.Bind(&sp); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if err := sp.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	return h.save(c, uStmt, sp.Name, sp.Email, id)
+}
+
+type patchReqBody struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+// PATCH /api/v1/spenders/:id
+// Only the fields in the body change.
+func (h handler) Patch(c echo.Context) error {
+	logger := mlog.L(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var body patchReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if body.Name != nil {
+		*body.Name = strings.TrimSpace(*body.Name)
+		if err := validName(*body.Name); err != nil {
+			return apierror.Invalid(c, err)
+		}
+	}
+	if body.Email != nil {
+		*body.Email = strings.TrimSpace(*body.Email)
+		if err := validEmail(*body.Email); err != nil {
+			return apierror.Invalid(c, err)
+		}
+	}
+
+	return h.save(c, pStmt, body.Name, body.Email, id)
+}
+
+// save runs the update statement and answers with the spender as stored.
+func (h handler) save(c echo.Context, stmt string, args ...any) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var sp Spender
+	err := audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, stmt, args...).Scan(&sp.ID, &sp.Name, &sp.Email)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "email is already used by another spender")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("update successfully", zap.Int64("id", sp.ID))
+	return c.JSON(http.StatusOK, sp)
+}
+
+// DELETE /api/v1/spenders/:id?transactions=block|reassign|delete&to=2
+// The spender stays as a tombstone for the change feed. By default a
+// spender with transactions is not deleted, reassign hands them over to
+// another spender, their tags swapped for that spender's tags of the same
+// names, and delete removes them too. Recurring templates stop
+// either way.
+func (h handler) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	mode := c.QueryParam("transactions")
+	var to int64
+	switch mode {
+	case "":
+		mode = Block
+	case Block, SoftDelete:
+	case Reassign:
+		to, err = strconv.ParseInt(c.QueryParam("to"), 10, 64)
+		if err != nil || to == id {
+			return apierror.Respond(c, http.StatusBadRequest, "to must be the id of another spender")
+		}
+	default:
+		return apierror.Respond(c, http.StatusBadRequest, "transactions must be block, reassign or delete")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, dStmt, id)
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+
+	switch mode {
+	case Block:
+		var n int
+		if err := tx.QueryRowContext(ctx, txCountStmt, id).Scan(&n); err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if n > 0 {
+			return apierror.Respond(c, http.StatusConflict, "spender has transactions, reassign or delete them")
+		}
+	case Reassign:
+		var ok bool
+		if err := tx.QueryRowContext(ctx, existsStmt, to).Scan(&ok); err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if !ok {
+			return apierror.Respond(c, http.StatusBadRequest, "spender to reassign to not found")
+		}
+		if _, err := tx.ExecContext(ctx, reassignStmt, id, to); err != nil {
+			logger.Error("exec error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx, txCountStmt, id).Scan(&n); err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if n > 0 {
+			return apierror.Respond(c, http.StatusConflict, fmt.Sprintf("%d transactions use the spender's accounts or a wallet spender %d cannot record into, delete them or move them first", n, to))
+		}
+		if err := tag.Move(ctx, tx, to); err != nil {
+			logger.Error("exec error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+	case SoftDelete:
+		if _, err := tx.ExecContext(ctx, deleteTxStmt, id); err != nil {
+			logger.Error("exec error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, stopRecurStmt, id); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("delete successfully", zap.Int64("id", id), zap.String("transactions", mode))
+	return c.NoContent(http.StatusNoContent)
+}
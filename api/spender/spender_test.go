@@ -7,11 +7,20 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
+// the statements tag.Move runs to relink the tags of moved transactions
+var moveTagStmts = []string{
+	`INSERT INTO tag (spender_id, name) SELECT DISTINCT $1::int, g.name FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT (spender_id, name) DO NOTHING;`,
+	`INSERT INTO transaction_tag (transaction_id, tag_id) SELECT tt.transaction_id, n.id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id JOIN tag n ON n.spender_id = $1 AND n.name = g.name WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT DO NOTHING;`,
+	`DELETE FROM transaction_tag tt USING tag g, "transaction" t WHERE g.id = tt.tag_id AND t.id = tt.transaction_id AND t.spender_id = $1 AND g.spender_id <> $1;`,
+}
+
 func TestCreateSpender(t *testing.T) {
 
 	t.Run("create spender succesfully when feature toggle is enable", func(t *testing.T) {
@@ -27,7 +36,9 @@ func TestCreateSpender(t *testing.T) {
 		defer db.Close()
 
 		row := sqlmock.NewRows([]string{"id"}).AddRow(1)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
 		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnRows(row)
+		mock.ExpectCommit()
 		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
 		h := New(cfg, db)
@@ -74,6 +85,45 @@ func TestCreateSpender(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "invalid character")
 	})
 
+	t.Run("create spender failed on invalid email", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "HongJot", "email": "Hong <hong@jot.ok>"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{EnableCreateSpender: true}, nil)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("create spender failed on duplicate email", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "HongJot", "email": " Hong@Jot.ok "}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("HongJot", "Hong@Jot.ok").WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{EnableCreateSpender: true}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
 	t.Run("create spender failed on database (feature toggle is enable) ", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
@@ -86,7 +136,9 @@ func TestCreateSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
+		audittest.ExpectBegin(mock, audittest.Anonymous)
 		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(assert.AnError)
+		mock.ExpectRollback()
 		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
 		h := New(cfg, db)
@@ -112,15 +164,56 @@ func TestGetAllSpender(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "email"}).
 			AddRow(1, "HongJot", "hong@jot.ok").
 			AddRow(2, "JotHong", "jot@jot.ok")
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE deleted_at IS NULL AND (name ILIKE $1 OR email ILIKE $1) ORDER BY id ASC LIMIT $2 OFFSET $3`).WithArgs("%%", 20, 0).WillReturnRows(rows)
+		mock.ExpectQuery(countStmt).WithArgs("%%").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"spenders": [{"id": 1, "name": "HongJot", "email": "hong@jot.ok"},
+		{"id": 2, "name": "JotHong", "email": "jot@jot.ok"}],
+		"pagination": {"current_page": 1, "total_pages": 1, "per_page": 20, "total_count": 2}}`, rec.Body.String())
+	})
+
+	t.Run("search, sort and page", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?q=jot_&sort=-name&page=3&limit=2", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE deleted_at IS NULL AND (name ILIKE $1 OR email ILIKE $1) ORDER BY lower(name) DESC, id DESC LIMIT $2 OFFSET $3`).WithArgs(`%jot\_%`, 2, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+		mock.ExpectQuery(countStmt).WithArgs(`%jot\_%`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetAll(c)
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.JSONEq(t, `[{"id": 1, "name": "HongJot", "email": "hong@jot.ok"},
-		{"id": 2, "name": "JotHong", "email": "jot@jot.ok"}]`, rec.Body.String())
+		assert.JSONEq(t, `{"spenders": [], "pagination": {"current_page": 3, "total_pages": 2, "per_page": 2, "total_count": 3}}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get all spender failed on unknown sort", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?sort=password", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
 	t.Run("get all spender failed on database", func(t *testing.T) {
@@ -134,7 +227,7 @@ func TestGetAllSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE deleted_at IS NULL AND (name ILIKE $1 OR email ILIKE $1) ORDER BY id ASC LIMIT $2 OFFSET $3`).WillReturnError(assert.AnError)
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetAll(c)
@@ -161,7 +254,7 @@ func TestGetSpenderByID(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "email"}).
 			AddRow(1, "HongJot", "aa@bb.com")
 		//https://stackoverflow.com/questions/57719304/how-to-correctly-set-mock-row-and-query-for-go-sqlmock
-		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id = $1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id = $1 AND deleted_at IS NULL`).WithArgs("1").WillReturnRows(rows)
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetByID(c)
@@ -186,11 +279,254 @@ func TestGetSpenderByID(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id = $1`).WithArgs("not_exist_id").WillReturnError(assert.AnError)
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id = $1 AND deleted_at IS NULL`).WithArgs("not_exist_id").WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("get spender by id failed on unknown id", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, name, email FROM spender WHERE id = $1 AND deleted_at IS NULL`).WithArgs("9").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetByID(c)
 
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func newContext(method, target, body, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestUpdateSpender(t *testing.T) {
+	t.Run("update spender successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"name": "HongJot", "email": "hong@jot.dev"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uStmt).WithArgs("HongJot", "hong@jot.dev", int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(1, "HongJot", "hong@jot.dev"))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.dev"}`, rec.Body.String())
+	})
+
+	t.Run("update spender failed on unknown id", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"name": "HongJot", "email": "hong@jot.dev"}`, "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uStmt).WithArgs("HongJot", "hong@jot.dev", int64(9)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("update spender failed on duplicate email", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"name": "HongJot", "email": "jot@jot.ok"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uStmt).WithArgs("HongJot", "jot@jot.ok", int64(1)).WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("update spender failed on missing name", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", `{"email": "hong@jot.dev"}`, "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("patch only the email", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, "/", `{"email": "hong@jot.dev"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		email := "hong@jot.dev"
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(pStmt).WithArgs((*string)(nil), &email, int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(1, "HongJot", "hong@jot.dev"))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.dev"}`, rec.Body.String())
+	})
+
+	t.Run("patch failed on invalid email", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, "/", `{"email": "hong"}`, "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestDeleteSpender(t *testing.T) {
+	t.Run("block a spender with transactions", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/", "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(txCountStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reassign transactions to another spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/?transactions=reassign&to=2", "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(existsStmt).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(reassignStmt).WithArgs(int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectQuery(txCountStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		for _, stmt := range moveTagStmts {
+			mock.ExpectExec(stmt).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(stopRecurStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuse to reassign transactions through the spender's accounts", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/?transactions=reassign&to=2", "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(existsStmt).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(reassignStmt).WithArgs(int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(txCountStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "1 transactions use the spender's accounts or a wallet spender 2 cannot record into")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete transactions with the spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/?transactions=delete", "", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(deleteTxStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(stopRecurStmt).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete failed on unknown id", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/", "", "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs(int64(9)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("delete failed on reassigning to itself", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/?transactions=reassign&to=1", "", "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Delete(c)
+
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
@@ -0,0 +1,95 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const loadStmt = `SELECT model, trained_at FROM category_model WHERE spender_id = $1`
+
+type Result struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	Examples    int          `json:"examples"`
+	TrainedAt   *time.Time   `json:"trained_at"`
+}
+
+type rowQuerier interface {
+	querier
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Load returns the spender's model as last trained. A spender the trainer
+// has not reached yet gets one trained on the spot, with no trained_at.
+func Load(ctx context.Context, q rowQuerier, spenderID int64) (*Model, *time.Time, error) {
+	var (
+		data      []byte
+		trainedAt time.Time
+	)
+	err := q.QueryRowContext(ctx, loadStmt, spenderID).Scan(&data, &trainedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		model, err := train(ctx, q, spenderID)
+		return model, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var model Model
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, nil, err
+	}
+	return &model, &trainedAt, nil
+}
+
+// POST /api/v1/spenders/:id/transactions/suggest-category
+// Suggests categories for a transaction being drafted from what the
+// spender chose for similar ones before.
+func (h handler) SuggestCategory(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var in Input
+	if err := c.Bind(&in); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if in.Note == "" && in.Merchant == "" && in.Amount == 0 {
+		return apierror.Respond(c, http.StatusBadRequest, "note, merchant or amount is required")
+	}
+	if in.Amount < 0 {
+		return apierror.Respond(c, http.StatusBadRequest, "amount must not be negative")
+	}
+
+	model, trainedAt, err := Load(ctx, h.db, spenderID)
+	if err != nil {
+		logger.Error("load model error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, Result{Suggestions: model.Predict(in), Examples: model.Docs, TrainedAt: trainedAt})
+}
@@ -0,0 +1,79 @@
+package suggest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContext(body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	return c, rec
+}
+
+func TestSuggestCategory(t *testing.T) {
+	t.Run("suggest from the stored model", func(t *testing.T) {
+		c, rec := newContext(`{"merchant": "Starbucks", "amount": 110, "transaction_type": "expense"}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		model, _ := json.Marshal(Train(history()))
+		trainedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(loadStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"model", "trained_at"}).AddRow(model, trainedAt))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SuggestCategory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got Result
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "coffee", got.Suggestions[0].Category)
+		assert.Equal(t, 6, got.Examples)
+		assert.Equal(t, trainedAt, *got.TrainedAt)
+	})
+
+	t.Run("train on the spot before the trainer gets there", func(t *testing.T) {
+		c, rec := newContext(`{"note": "latte"}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(loadStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"model", "trained_at"}))
+		mock.ExpectQuery(examplesStmt).WithArgs(int64(1), maxExamples).
+			WillReturnRows(sqlmock.NewRows(exampleColumns).AddRow("latte", "", 120.0, "expense", "coffee"))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SuggestCategory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"suggestions": [{"category": "coffee", "confidence": 1}], "examples": 1, "trained_at": null}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject an empty draft", func(t *testing.T) {
+		c, rec := newContext(`{"transaction_type": "expense"}`)
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.SuggestCategory(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
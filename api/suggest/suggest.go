@@ -0,0 +1,176 @@
+// Package suggest learns which category a spender gives a transaction from
+// the ones they have already categorized, with a naive Bayes classifier
+// over the words of the note and merchant, the merchant itself, the amount
+// and the transaction type.
+package suggest
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/merchant"
+)
+
+const (
+	// maxSuggestions is how many categories a suggestion lists at most.
+	maxSuggestions = 3
+
+	// alpha is the Laplace smoothing of feature counts.
+	alpha = 1.0
+)
+
+// Input is what is known of a transaction before it has a category.
+type Input struct {
+	Note            string  `json:"note"`
+	Merchant        string  `json:"merchant"`
+	Amount          float64 `json:"amount"`
+	TransactionType string  `json:"transaction_type"`
+}
+
+// Example is a transaction the spender has categorized.
+type Example struct {
+	Input
+	Category string
+}
+
+type Suggestion struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+}
+
+// class holds the counts of one category.
+type class struct {
+	Docs     int            `json:"docs"`
+	Total    int            `json:"total"`
+	Features map[string]int `json:"features"`
+}
+
+// Model is a trained classifier, stored as JSON between trainings.
+type Model struct {
+	Docs       int               `json:"docs"`
+	Vocabulary int               `json:"vocabulary"`
+	Classes    map[string]*class `json:"classes"`
+}
+
+// features turns a transaction into the set of facts the model counts:
+// words, the normalized merchant, an amount bucket and the type.
+func features(in Input) []string {
+	var fs []string
+	for _, w := range words(in.Note + " " + in.Merchant) {
+		fs = append(fs, "w:"+w)
+	}
+	if m := merchant.Normalize(in.Merchant); m != "" {
+		fs = append(fs, "m:"+m)
+	}
+	if in.Amount > 0 {
+		// doubling buckets, 100 and 120 land together, 100 and 1000 don't
+		fs = append(fs, "a:"+strconv.Itoa(int(math.Log2(in.Amount+1))))
+	}
+	if in.TransactionType != "" {
+		fs = append(fs, "t:"+in.TransactionType)
+	}
+	slices.Sort(fs)
+	return slices.Compact(fs)
+}
+
+// words splits on anything but letters, digits and combining marks, Thai
+// vowels and tone marks are the latter. Numbers alone are mostly branch
+// codes and dates, they are left out.
+func words(s string) []string {
+	split := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+	ws := split[:0]
+	for _, w := range split {
+		if len([]rune(w)) < 2 || strings.IndexFunc(w, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			continue
+		}
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// Train builds a model from the spender's categorized transactions.
+func Train(examples []Example) *Model {
+	m := &Model{Classes: map[string]*class{}}
+	vocabulary := map[string]bool{}
+	for _, e := range examples {
+		c, ok := m.Classes[e.Category]
+		if !ok {
+			c = &class{Features: map[string]int{}}
+			m.Classes[e.Category] = c
+		}
+		c.Docs++
+		m.Docs++
+		for _, f := range features(e.Input) {
+			c.Features[f]++
+			c.Total++
+			vocabulary[f] = true
+		}
+	}
+	m.Vocabulary = len(vocabulary)
+	return m
+}
+
+// known reports whether any category has seen f.
+func (m *Model) known(f string) bool {
+	for _, c := range m.Classes {
+		if c.Features[f] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Predict lists the likeliest categories first with their share of the
+// probability. A model without history, or an input it has never seen
+// any part of, suggests nothing.
+func (m *Model) Predict(in Input) []Suggestion {
+	suggestions := []Suggestion{}
+	if m == nil || m.Docs == 0 {
+		return suggestions
+	}
+
+	var fs []string
+	for _, f := range features(in) {
+		if m.known(f) {
+			fs = append(fs, f)
+		}
+	}
+	if len(fs) == 0 {
+		return suggestions
+	}
+
+	scores := map[string]float64{}
+	best := math.Inf(-1)
+	for name, c := range m.Classes {
+		score := math.Log(float64(c.Docs) / float64(m.Docs))
+		for _, f := range fs {
+			score += math.Log((float64(c.Features[f]) + alpha) / (float64(c.Total) + alpha*float64(m.Vocabulary)))
+		}
+		scores[name] = score
+		best = max(best, score)
+	}
+
+	// back from log space, shifted by the best score so nothing underflows
+	var sum float64
+	for name, score := range scores {
+		p := math.Exp(score - best)
+		scores[name] = p
+		sum += p
+	}
+	for name, p := range scores {
+		suggestions = append(suggestions, Suggestion{Category: name, Confidence: math.Round(p/sum*1000) / 1000})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Category < suggestions[j].Category
+	})
+	return suggestions[:min(len(suggestions), maxSuggestions)]
+}
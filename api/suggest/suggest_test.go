@@ -0,0 +1,59 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func history() []Example {
+	return []Example{
+		{Input{Note: "latte", Merchant: "Starbucks #1042", Amount: 120, TransactionType: "expense"}, "coffee"},
+		{Input{Note: "americano", Merchant: "Starbucks", Amount: 95, TransactionType: "expense"}, "coffee"},
+		{Input{Note: "ข้าวมันไก่", Merchant: "", Amount: 60, TransactionType: "expense"}, "food"},
+		{Input{Note: "lunch set", Merchant: "MK Restaurant", Amount: 350, TransactionType: "expense"}, "food"},
+		{Input{Note: "dinner", Merchant: "MK Restaurant", Amount: 420, TransactionType: "expense"}, "food"},
+		{Input{Note: "salary june", Merchant: "", Amount: 30000, TransactionType: "income"}, "salary"},
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	t.Run("words, merchant, amount bucket and type", func(t *testing.T) {
+		got := features(Input{Note: "Latte x2 - 2024", Merchant: "Starbucks #1042", Amount: 120, TransactionType: "expense"})
+
+		assert.Equal(t, []string{"a:6", "m:STARBUCKS", "t:expense", "w:latte", "w:starbucks", "w:x2"}, got)
+	})
+
+	t.Run("keep thai marks inside words", func(t *testing.T) {
+		assert.Equal(t, []string{"ข้าวมันไก่", "ร้าน"}, words("ข้าวมันไก่, ร้าน"))
+	})
+}
+
+func TestPredict(t *testing.T) {
+	model := Train(history())
+
+	t.Run("suggest from the merchant", func(t *testing.T) {
+		got := model.Predict(Input{Merchant: "STARBUCKS 2201", Amount: 110, TransactionType: "expense"})
+
+		assert.Equal(t, "coffee", got[0].Category)
+		assert.Greater(t, got[0].Confidence, 0.5)
+		assert.LessOrEqual(t, len(got), maxSuggestions)
+	})
+
+	t.Run("suggest from the note", func(t *testing.T) {
+		got := model.Predict(Input{Note: "ข้าวมันไก่", Amount: 50, TransactionType: "expense"})
+
+		assert.Equal(t, "food", got[0].Category)
+	})
+
+	t.Run("suggest from the type and amount", func(t *testing.T) {
+		got := model.Predict(Input{Note: "bonus", Amount: 25000, TransactionType: "income"})
+
+		assert.Equal(t, "salary", got[0].Category)
+	})
+
+	t.Run("nothing known suggests nothing", func(t *testing.T) {
+		assert.Empty(t, model.Predict(Input{Note: "unheard of"}))
+		assert.Empty(t, Train(nil).Predict(Input{Note: "latte"}))
+	})
+}
@@ -0,0 +1,147 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// lockKey is the Postgres advisory lock shared by every replica, only
+	// the replica holding it trains on a given tick.
+	lockKey = 42_001
+
+	// maxExamples is how much of a spender's history a model learns from,
+	// the most recently changed transactions first.
+	maxExamples = 5000
+
+	// batchSize bounds how many spenders are retrained per tick, the rest
+	// are picked up by following ticks.
+	batchSize = 50
+
+	lockStmt = `SELECT pg_try_advisory_xact_lock($1)`
+	// every write moves a transaction to the end of the change feed, so a
+	// spender with a sync_seq past the model's has something new to learn
+	dirtyStmt = `SELECT t.spender_id, MAX(t.sync_seq) FROM "transaction" t LEFT JOIN category_model m ON m.spender_id = t.spender_id
+		WHERE t.spender_id IS NOT NULL AND t.sync_seq > COALESCE(m.trained_seq, 0) GROUP BY t.spender_id ORDER BY t.spender_id LIMIT $1`
+	examplesStmt = `SELECT COALESCE(note, ''), merchant, amount, transaction_type, category FROM "transaction"
+		WHERE spender_id = $1 AND deleted_at IS NULL AND category NOT IN ('', 'uncategorized') ORDER BY sync_seq DESC LIMIT $2`
+	saveStmt = `INSERT INTO category_model (spender_id, trained_seq, model, trained_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (spender_id) DO UPDATE SET trained_seq = EXCLUDED.trained_seq, model = EXCLUDED.model, trained_at = EXCLUDED.trained_at;`
+)
+
+// Trainer keeps every spender's model up to date in the background. Only
+// the spenders whose transactions changed since their last training are
+// retrained.
+type Trainer struct {
+	db       *sql.DB
+	logger   *zap.Logger
+	interval time.Duration
+}
+
+func NewTrainer(db *sql.DB, logger *zap.Logger, interval time.Duration) *Trainer {
+	return &Trainer{db: db, logger: logger, interval: interval}
+}
+
+// Run retrains every interval until ctx is cancelled.
+func (t *Trainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := t.Tick(ctx); err != nil {
+			t.logger.Error("category trainer tick failed", zap.Error(err))
+		} else if n > 0 {
+			t.logger.Info("category models retrained", zap.Int("count", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick retrains the models that are behind and returns how many were. It
+// runs in a single DB transaction guarded by an advisory lock.
+func (t *Trainer) Tick(ctx context.Context) (int, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, lockStmt, lockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		// another replica is working on it
+		return 0, tx.Commit()
+	}
+
+	type dirty struct{ spenderID, seq int64 }
+	rows, err := tx.QueryContext(ctx, dirtyStmt, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var spenders []dirty
+	for rows.Next() {
+		var d dirty
+		if err := rows.Scan(&d.spenderID, &d.seq); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		spenders = append(spenders, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range spenders {
+		model, err := train(ctx, tx, d.spenderID)
+		if err != nil {
+			return 0, err
+		}
+		data, err := json.Marshal(model)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, saveStmt, d.spenderID, d.seq, data); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(spenders), tx.Commit()
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// train learns a model from the spender's categorized transactions.
+func train(ctx context.Context, q querier, spenderID int64) (*Model, error) {
+	rows, err := q.QueryContext(ctx, examplesStmt, spenderID, maxExamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []Example
+	for rows.Next() {
+		var e Example
+		if err := rows.Scan(&e.Note, &e.Merchant, &e.Amount, &e.TransactionType, &e.Category); err != nil {
+			return nil, err
+		}
+		examples = append(examples, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return Train(examples), nil
+}
@@ -0,0 +1,51 @@
+package suggest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var exampleColumns = []string{"note", "merchant", "amount", "transaction_type", "category"}
+
+func TestTrainerTick(t *testing.T) {
+	t.Run("retrain the spenders with new transactions", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		model, _ := json.Marshal(Train([]Example{{Input{Note: "latte", Amount: 120, TransactionType: "expense"}, "coffee"}}))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(lockKey).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+		mock.ExpectQuery(dirtyStmt).WithArgs(batchSize).WillReturnRows(sqlmock.NewRows([]string{"spender_id", "max"}).AddRow(1, 42))
+		mock.ExpectQuery(examplesStmt).WithArgs(int64(1), maxExamples).
+			WillReturnRows(sqlmock.NewRows(exampleColumns).AddRow("latte", "", 120.0, "expense", "coffee"))
+		mock.ExpectExec(saveStmt).WithArgs(int64(1), int64(42), model).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := NewTrainer(db, nil, time.Minute).Tick(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("skip when another replica holds the lock", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(lockKey).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+		mock.ExpectCommit()
+
+		n, err := NewTrainer(db, nil, time.Minute).Tick(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,359 @@
+package tag
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type handler struct {
+	flag config.FeatureFlag
+	db   *sql.DB
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB) *handler {
+	return &handler{cfg, db}
+}
+
+const (
+	columns = `id, spender_id, name, created_at`
+
+	cStmt    = `INSERT INTO tag (spender_id, name) VALUES ($1, $2) RETURNING id, created_at;`
+	uStmt    = `UPDATE tag SET name = $1 WHERE id = $2 RETURNING spender_id, created_at;`
+	dStmt    = `DELETE FROM tag WHERE id = $1;`
+	listStmt = `SELECT ` + columns + ` FROM tag WHERE spender_id = $1 ORDER BY name`
+
+	summaryStmt = `SELECT g.id, g.name,
+		COALESCE(SUM(CASE WHEN t.transaction_type = 'income' THEN t.amount END), 0),
+		COALESCE(SUM(CASE WHEN t.transaction_type = 'expense' THEN t.amount END), 0),
+		COUNT(t.id)
+		FROM tag g LEFT JOIN transaction_tag tt ON tt.tag_id = g.id LEFT JOIN transaction t ON t.id = tt.transaction_id AND t.deleted_at IS NULL
+		WHERE g.spender_id = $1 GROUP BY g.id, g.name ORDER BY g.name`
+
+	txSpenderStmt = `SELECT spender_id FROM transaction WHERE id = $1 AND deleted_at IS NULL`
+	ensureStmt    = `INSERT INTO tag (spender_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (spender_id, name) DO NOTHING;`
+	unlinkStmt    = `DELETE FROM transaction_tag WHERE transaction_id = $1;`
+	linkStmt      = `INSERT INTO transaction_tag (transaction_id, tag_id) SELECT $1, id FROM tag WHERE spender_id = $2 AND name = ANY($3::text[]) ON CONFLICT DO NOTHING;`
+	// tags a transaction carries from the spender it belonged to before
+	// being moved are swapped for the new spender's tags of the same names
+	moveEnsureStmt = `INSERT INTO tag (spender_id, name) SELECT DISTINCT $1::int, g.name FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT (spender_id, name) DO NOTHING;`
+	moveLinkStmt   = `INSERT INTO transaction_tag (transaction_id, tag_id) SELECT tt.transaction_id, n.id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id JOIN "transaction" t ON t.id = tt.transaction_id JOIN tag n ON n.spender_id = $1 AND n.name = g.name WHERE t.spender_id = $1 AND g.spender_id <> $1 ON CONFLICT DO NOTHING;`
+	moveUnlinkStmt = `DELETE FROM transaction_tag tt USING tag g, "transaction" t WHERE g.id = tt.tag_id AND t.id = tt.transaction_id AND t.spender_id = $1 AND g.spender_id <> $1;`
+	byTxStmt       = `SELECT g.id, g.spender_id, g.name, g.created_at FROM tag g JOIN transaction_tag tt ON tt.tag_id = g.id WHERE tt.transaction_id = $1 ORDER BY g.name`
+)
+
+type reqBody struct {
+	Name string `json:"name"`
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func list(ctx context.Context, db queryer, query string, arg any) ([]Tag, error) {
+	rows, err := db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.SpenderID, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// POST /api/v1/spenders/:id/tags
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var body reqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	name, err := Normalize(body.Name)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	t := Tag{SpenderID: spenderID, Name: name}
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, cStmt, t.SpenderID, t.Name).Scan(&t.ID, &t.CreatedAt)
+	})
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "tag already exists")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("create successfully", zap.Int64("id", t.ID))
+	return c.JSON(http.StatusCreated, t)
+}
+
+// GET /api/v1/spenders/:id/tags
+func (h handler) GetBySpenderID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	tags, err := list(ctx, h.db, listStmt, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+// PUT /api/v1/tags/:id
+// Renaming keeps the tag on every transaction it labels.
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid tag id")
+	}
+
+	var body reqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	name, err := Normalize(body.Name)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	t := Tag{ID: id, Name: name}
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, uStmt, t.Name, t.ID).Scan(&t.SpenderID, &t.CreatedAt)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "tag not found")
+	}
+	if isUniqueViolation(err) {
+		return apierror.Respond(c, http.StatusConflict, "tag already exists")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("update successfully", zap.Int64("id", t.ID))
+	return c.JSON(http.StatusOK, t)
+}
+
+// DELETE /api/v1/tags/:id
+// The tag comes off its transactions, the transactions stay.
+func (h handler) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid tag id")
+	}
+
+	err = audit.InTx(ctx, h.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, dStmt, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "tag not found")
+	}
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("delete successfully", zap.Int64("id", id))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GET /api/v1/spenders/:id/tags/summary
+// A transaction with several tags counts towards each of them.
+func (h handler) GetSummary(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	rows, err := h.db.QueryContext(ctx, summaryStmt, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	summaries := []Summary{}
+	for rows.Next() {
+		var s Summary
+		if err := rows.Scan(&s.ID, &s.Name, &s.TotalIncome, &s.TotalExpenses, &s.Count); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, summaries)
+}
+
+// GET /api/v1/transactions/:id/tags
+func (h handler) GetByTransaction(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid transaction id")
+	}
+
+	tags, err := list(ctx, h.db, byTxStmt, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+type setReqBody struct {
+	Tags []string `json:"tags"`
+}
+
+// PUT /api/v1/transactions/:id/tags
+// Replaces the tags of a transaction, names the spender hasn't used yet
+// become new tags.
+func (h handler) SetOnTransaction(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid transaction id")
+	}
+
+	var body setReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	names, err := NormalizeAll(body.Tags)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	var spenderID int64
+	err = tx.QueryRowContext(ctx, txSpenderStmt, id).Scan(&spenderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "transaction not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	if _, err := tx.ExecContext(ctx, unlinkStmt, id); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := Attach(ctx, tx, id, spenderID, names); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	tags, err := list(ctx, tx, byTxStmt, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("tag successfully", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, tags)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Attach adds tags to a transaction of the spender, keeping the ones it
+// already has. Names the spender hasn't used yet become new tags.
+func Attach(ctx context.Context, db execer, transactionID, spenderID int64, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, ensureStmt, spenderID, pq.Array(names)); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, linkStmt, transactionID, spenderID, pq.Array(names))
+	return err
+}
+
+// Move relinks the transactions moved to the spender from the tags of
+// their former spender to the spender's tags of the same names, creating
+// the ones missing. It runs in the transaction that moved them.
+func Move(ctx context.Context, db execer, spenderID int64) error {
+	for _, stmt := range []string{moveEnsureStmt, moveLinkStmt, moveUnlinkStmt} {
+		if _, err := db.ExecContext(ctx, stmt, spenderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package tag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var createdAt = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newContext(method, body, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestCreateTag(t *testing.T) {
+	t.Run("create tag successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"name": "#Trip-Japan"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs(int64(1), "trip-japan").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 3, "spender_id": 1, "name": "trip-japan", "created_at": "2024-06-01T00:00:00Z"}`, rec.Body.String())
+	})
+
+	t.Run("create tag failed on duplicate name", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"name": "trip-japan"}`, "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs(int64(1), "trip-japan").WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("create tag failed on invalid name", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"name": "trip japan"}`, "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestUpdateTag(t *testing.T) {
+	t.Run("rename tag successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"name": "japan-2024"}`, "3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uStmt).WithArgs("japan-2024", int64(3)).WillReturnRows(sqlmock.NewRows([]string{"spender_id", "created_at"}).AddRow(1, createdAt))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 3, "spender_id": 1, "name": "japan-2024", "created_at": "2024-06-01T00:00:00Z"}`, rec.Body.String())
+	})
+
+	t.Run("rename tag not found", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"name": "japan-2024"}`, "3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(uStmt).WithArgs("japan-2024", int64(3)).WillReturnRows(sqlmock.NewRows([]string{"spender_id", "created_at"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestDeleteTag(t *testing.T) {
+	c, rec := newContext(http.MethodDelete, "", "3")
+
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	audittest.ExpectBegin(mock, audittest.Anonymous)
+	mock.ExpectExec(dStmt).WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := New(config.FeatureFlag{}, db)
+	err := h.Delete(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestGetSummary(t *testing.T) {
+	c, rec := newContext(http.MethodGet, "", "1")
+
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	mock.ExpectQuery(summaryStmt).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "income", "expenses", "count"}).
+		AddRow(4, "reimbursable", 0, 1200.5, 3).
+		AddRow(3, "trip-japan", 500, 42000, 12))
+
+	h := New(config.FeatureFlag{}, db)
+	err := h.GetSummary(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"id": 4, "name": "reimbursable", "total_income": 0, "total_expenses": 1200.5, "count": 3},
+		{"id": 3, "name": "trip-japan", "total_income": 500, "total_expenses": 42000, "count": 12}
+	]`, rec.Body.String())
+}
+
+func TestSetOnTransaction(t *testing.T) {
+	t.Run("replace the tags of a transaction", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"tags": ["#trip-japan", "Reimbursable", "trip-japan"]}`, "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		names := pq.Array([]string{"trip-japan", "reimbursable"})
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(txSpenderStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"spender_id"}).AddRow(1))
+		mock.ExpectExec(unlinkStmt).WithArgs(int64(9)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(ensureStmt).WithArgs(int64(1), names).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(linkStmt).WithArgs(int64(9), int64(1), names).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(byTxStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"id", "spender_id", "name", "created_at"}).
+			AddRow(4, 1, "reimbursable", createdAt).
+			AddRow(3, 1, "trip-japan", createdAt))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SetOnTransaction(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+			{"id": 4, "spender_id": 1, "name": "reimbursable", "created_at": "2024-06-01T00:00:00Z"},
+			{"id": 3, "spender_id": 1, "name": "trip-japan", "created_at": "2024-06-01T00:00:00Z"}
+		]`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("transaction not found", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"tags": ["food"]}`, "9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(txSpenderStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"spender_id"}))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.SetOnTransaction(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,60 @@
+// Package tag labels transactions across categories, like #trip-japan or
+// #reimbursable. A spender's tags are many-to-many with their transactions.
+package tag
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	MatchAny = "any"
+	MatchAll = "all"
+)
+
+var namePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{M}\p{N}_-]{0,49}$`)
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	SpenderID int64     `json:"spender_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summary is what a tag adds up to across the transactions it labels.
+type Summary struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	TotalIncome   float64 `json:"total_income"`
+	TotalExpenses float64 `json:"total_expenses"`
+	Count         int     `json:"count"`
+}
+
+// Normalize is the stored form of a tag name: without the leading # and
+// lower case, so #Trip-Japan and trip-japan are the same tag.
+func Normalize(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if !namePattern.MatchString(name) {
+		return "", errors.New("tag names are up to 50 letters, digits, - or _")
+	}
+	return name, nil
+}
+
+// NormalizeAll normalizes names and drops the repeats.
+func NormalizeAll(names []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, raw := range names {
+		name, err := Normalize(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,38 @@
+package tag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("drop the hash and lower case", func(t *testing.T) {
+		for raw, want := range map[string]string{
+			"#Trip-Japan":   "trip-japan",
+			" reimbursable": "reimbursable",
+			"#เที่ยวญี่ปุ่น": "เที่ยวญี่ปุ่น",
+			"q2_2024": "q2_2024",
+		} {
+			got, err := Normalize(raw)
+
+			assert.NoError(t, err, raw)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("reject names that aren't a single word", func(t *testing.T) {
+		for _, raw := range []string{"", "#", "trip japan", "-trip", "a,b", string(make([]byte, 51))} {
+			_, err := Normalize(raw)
+
+			assert.Error(t, err, raw)
+		}
+	})
+
+	t.Run("normalize all and drop repeats", func(t *testing.T) {
+		got, err := NormalizeAll([]string{"#Food", "food", "trip"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"food", "trip"}, got)
+	})
+}
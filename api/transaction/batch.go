@@ -0,0 +1,168 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// BatchAtomic creates every item or none of them.
+	BatchAtomic = "atomic"
+	// BatchPerItem creates each valid item on its own.
+	BatchPerItem = "per_item"
+
+	MaxBatchSize = 100
+)
+
+type BatchReqBody struct {
+	Mode  string               `json:"mode"`
+	Items []TransactionReqBody `json:"items"`
+}
+
+// BatchResult is the outcome of one item, Index is its position in the
+// request so clients can match results to what they sent.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode    string        `json:"mode"`
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []BatchResult `json:"results"`
+}
+
+// POST /api/v1/transactions/batch
+// In atomic mode, the default, one invalid item fails the whole batch with
+// a 422 problem and nothing is created, its results member has the outcome
+// of every item. In per_item mode every valid item is created
+// and the response is 207 when some items failed.
+func (h handlerTransaction) CreateBatch(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var body BatchReqBody
+	if err := c.Bind(&body); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if body.Mode == "" {
+		body.Mode = BatchAtomic
+	}
+	if body.Mode != BatchAtomic && body.Mode != BatchPerItem {
+		return apierror.Respond(c, http.StatusBadRequest, "mode must be atomic or per_item")
+	}
+	if len(body.Items) == 0 || len(body.Items) > MaxBatchSize {
+		return apierror.Respond(c, http.StatusBadRequest, fmt.Sprintf("a batch needs between 1 and %d items", MaxBatchSize))
+	}
+
+	res := BatchResponse{Mode: body.Mode, Results: make([]BatchResult, len(body.Items))}
+	shares := make([][]Share, len(body.Items))
+	for i := range body.Items {
+		res.Results[i] = BatchResult{Index: i}
+		s, herr := h.check(ctx, &body.Items[i])
+		if herr != nil && herr.Internal != nil {
+			logger.Error("query row error", zap.Error(herr.Internal))
+			return apierror.Internal(c)
+		}
+		if herr != nil {
+			res.Results[i].Status = herr.Code
+			res.Results[i].Error = fmt.Sprint(herr.Message)
+			res.Failed++
+			continue
+		}
+		shares[i] = s
+	}
+
+	if body.Mode == BatchAtomic {
+		if res.Failed > 0 {
+			// the valid items were fine but are not created either
+			for i := range res.Results {
+				if res.Results[i].Status == 0 {
+					res.Results[i].Status = http.StatusFailedDependency
+				}
+			}
+			return apierror.RespondWith(c, http.StatusUnprocessableEntity, fmt.Sprintf("%d of %d items are invalid, none was created", res.Failed, len(body.Items)),
+				map[string]any{"mode": res.Mode, "created": res.Created, "failed": res.Failed, "results": res.Results})
+		}
+		err := h.createAll(ctx, body.Items, shares, res.Results)
+		var missing missingParticipantError
+		if errors.As(err, &missing) {
+			return apierror.Respond(c, http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			logger.Error("batch insert error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		res.Created = len(body.Items)
+		logger.Info("create batch successfully", zap.Int("created", res.Created))
+		return c.JSON(http.StatusCreated, res)
+	}
+
+	for i, item := range body.Items {
+		if res.Results[i].Status != 0 {
+			continue
+		}
+		id, err := h.createWithDetails(ctx, item, shares[i])
+		var missing missingParticipantError
+		if errors.As(err, &missing) {
+			res.Results[i].Status = http.StatusBadRequest
+			res.Results[i].Error = missing.Error()
+			res.Failed++
+			continue
+		}
+		if err != nil {
+			logger.Error("batch item insert error", zap.Int("index", i), zap.Error(err))
+			res.Results[i].Status = http.StatusInternalServerError
+			res.Results[i].Error = "Please check server logs"
+			res.Failed++
+			continue
+		}
+		res.Results[i].Status = http.StatusCreated
+		res.Results[i].ID = id
+		res.Created++
+	}
+
+	logger.Info("create batch successfully", zap.Int("created", res.Created), zap.Int("failed", res.Failed))
+	if res.Failed > 0 {
+		return c.JSON(http.StatusMultiStatus, res)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+// createAll inserts every item in one DB transaction and fills in the
+// results once it is committed.
+func (h handlerTransaction) createAll(ctx context.Context, items []TransactionReqBody, shares [][]Share, results []BatchResult) error {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		if ids[i], err = insert(ctx, tx, item, shares[i]); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i := range results {
+		results[i].Status = http.StatusCreated
+		results[i].ID = ids[i]
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package transaction
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const batchItems = `[
+	{"date": "2024-06-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1},
+	{"date": "2024-06-02", "amount": 200, "category": "food", "transaction_type": "expense", "spender_id": 1, "splits": [{"category": "food", "amount": 150}]},
+	{"date": "2024-06-03", "amount": 300, "category": "salary", "transaction_type": "income", "spender_id": 1}
+]`
+
+func batchContext(t *testing.T, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	t.Cleanup(func() { e.Close() })
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestCreateBatch(t *testing.T) {
+	t.Run("atomic batch creates every item in one transaction", func(t *testing.T) {
+		c, rec := batchContext(t, `{"items": [
+			{"date": "2024-06-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1},
+			{"date": "2024-06-03", "amount": 300, "category": "salary", "transaction_type": "income", "spender_id": 1}
+		]}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-01", 100.0, "food", "expense", 1, "", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-03", 300.0, "salary", "income", 1, "", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.CreateBatch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"mode": "atomic", "created": 2, "failed": 0, "results": [
+			{"index": 0, "status": 201, "id": "1"},
+			{"index": 1, "status": 201, "id": "2"}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("atomic batch with an invalid item creates nothing", func(t *testing.T) {
+		c, rec := batchContext(t, `{"mode": "atomic", "items": `+batchItems+`}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.CreateBatch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, apierror.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Unprocessable Entity", "status": 422, "detail": "1 of 3 items are invalid, none was created",
+			"mode": "atomic", "created": 0, "failed": 1, "results": [
+			{"index": 0, "status": 424},
+			{"index": 1, "status": 400, "error": "sum of splits must equal the transaction amount"},
+			{"index": 2, "status": 424}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("per item batch creates the valid items", func(t *testing.T) {
+		c, rec := batchContext(t, `{"mode": "per_item", "items": `+batchItems+`}`)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-01", 100.0, "food", "expense", 1, "", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-03", 300.0, "salary", "income", 1, "", "", nil, nil, nil, "", nil).WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.CreateBatch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.JSONEq(t, `{"mode": "per_item", "created": 1, "failed": 2, "results": [
+			{"index": 0, "status": 201, "id": "1"},
+			{"index": 1, "status": 400, "error": "sum of splits must equal the transaction amount"},
+			{"index": 2, "status": 500, "error": "Please check server logs"}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject batches over the limit", func(t *testing.T) {
+		items := strings.TrimSuffix(strings.Repeat(`{"amount": 1},`, MaxBatchSize+1), ",")
+		c, rec := batchContext(t, `{"items": [`+items+`]}`)
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.CreateBatch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
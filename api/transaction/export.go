@@ -0,0 +1,235 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// fetchSize is how many rows are held in memory at a time while exporting
+	fetchSize = 500
+
+	exportCursor = `export_cursor`
+
+	// the balance runs over the exported rows only, transfers don't move it
+	exportStmt = `DECLARE ` + exportCursor + ` NO SCROLL CURSOR FOR SELECT id, to_char(date, 'YYYY-MM-DD'), transaction_type, category, amount, COALESCE(note, ''), COALESCE(image_url, ''),
+		SUM(CASE transaction_type WHEN 'income' THEN amount WHEN 'expense' THEN -amount ELSE 0 END) OVER (ORDER BY date, id) AS running_balance
+		FROM "transaction" WHERE %s ORDER BY date, id`
+)
+
+var fetchStmt = fmt.Sprintf("FETCH %d FROM %s", fetchSize, exportCursor)
+
+var exportHeader = []string{"id", "date", "transaction_type", "category", "amount", "note", "image_url", "running_balance"}
+
+// GET /api/v1/spenders/:id/transactions/export?format=csv&from=2024-01-01&to=2024-01-31
+// Accepts the same filters as GET /transactions. Rows are streamed from a
+// server side cursor so memory stays flat whatever the size of the export.
+func (h handlerTransaction) Export(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	if format := c.QueryParam("format"); format != "" && format != "csv" {
+		return apierror.Respond(c, http.StatusBadRequest, "format must be csv")
+	}
+	filter, err := parseFilter(c)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	where, args := filter.clauses([]string{"spender_id = $1", "deleted_at IS NULL"}, []any{spenderID})
+
+	// a cursor only lives as long as its transaction
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(exportStmt, strings.Join(where, " AND ")), args...); err != nil {
+		logger.Error("declare cursor error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="transactions-%d.csv"`, spenderID))
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	if err := w.Write(exportHeader); err != nil {
+		return err
+	}
+
+	// from here on the status is sent, failures can only cut the file short
+	if err := streamRows(ctx, tx, w, res.Flush); err != nil {
+		logger.Error("export error", zap.Error(err))
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+	}
+	return nil
+}
+
+// streamRows fetches the cursor batch by batch and writes every batch to w
+// before flushing it to the client.
+func streamRows(ctx context.Context, tx *sql.Tx, w *csv.Writer, flush func()) error {
+	for {
+		rows, err := tx.QueryContext(ctx, fetchStmt)
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		for rows.Next() {
+			var (
+				id                                   int64
+				date, kind, category, note, imageURL string
+				amount, balance                      float64
+			)
+			if err := rows.Scan(&id, &date, &kind, &category, &amount, &note, &imageURL, &balance); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+
+			record := []string{
+				strconv.FormatInt(id, 10), date, kind, category,
+				strconv.FormatFloat(amount, 'f', 2, 64), note, imageURL,
+				strconv.FormatFloat(balance, 'f', 2, 64),
+			}
+			if err := w.Write(record); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		flush()
+
+		if n < fetchSize {
+			return nil
+		}
+	}
+}
+
+const (
+	statementSpenderStmt = `SELECT id, name, email FROM spender WHERE id = $1 AND deleted_at IS NULL`
+	statementTxStmt      = `SELECT id, to_char(date AT TIME ZONE $4, 'YYYY-MM-DD'), amount, category, transaction_type, spender_id, COALESCE(note, ''), COALESCE(image_url, '') FROM "transaction" WHERE spender_id = $1 AND deleted_at IS NULL AND date >= $2 AND date < $3 ORDER BY date, id`
+)
+
+// GET /api/v1/spenders/:id/statement?month=2024-05&format=xlsx
+// format is xlsx or pdf. The month is the spender's, in their time zone
+// and from their month start day.
+func (h handlerTransaction) Statement(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	month, err := time.Parse(monthLayout, c.QueryParam("month"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "month must be like 2024-05")
+	}
+
+	var (
+		contentType string
+		render      func(Statement, io.Writer) error
+	)
+	switch format := c.QueryParam("format"); format {
+	case "xlsx":
+		contentType, render = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Statement.XLSX
+	case "pdf":
+		contentType, render = "application/pdf", Statement.PDF
+	default:
+		return apierror.Respond(c, http.StatusBadRequest, "format must be xlsx or pdf")
+	}
+
+	var sp spender.Spender
+	err = h.db.QueryRowContext(ctx, statementSpenderStmt, spenderID).Scan(&sp.ID, &sp.Name, &sp.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	prefs, err := spender.LoadPreferences(ctx, h.db, int64(spenderID))
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	txs, err := h.monthTransactions(ctx, spenderID, prefs, month)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	// rendered in memory first so a failure still gets a proper error status
+	var buf bytes.Buffer
+	statement := newStatement(sp, month, txs)
+	statement.Locale = prefs.Locale
+	if err := render(statement, &buf); err != nil {
+		logger.Error("render statement error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="statement-%d-%s.%s"`, spenderID, month.Format(monthLayout), c.QueryParam("format")))
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
+
+func (h handlerTransaction) monthTransactions(ctx context.Context, spenderID int, prefs spender.Preferences, month time.Time) ([]Transaction, error) {
+	from, to := prefs.Month(month.Year(), month.Month())
+	rows, err := h.db.QueryContext(ctx, statementTxStmt, spenderID, from, to, prefs.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txs := []Transaction{}
+	for rows.Next() {
+		var t Transaction
+		if err := rows.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.TransactionType, &t.SpenderID, &t.Note, &t.ImageURL); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return txs, attachSplits(ctx, h.db, txs)
+}
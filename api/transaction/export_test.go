@@ -0,0 +1,92 @@
+package transaction
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var exportColumns = []string{"id", "date", "transaction_type", "category", "amount", "note", "image_url", "running_balance"}
+
+func TestExport(t *testing.T) {
+	t.Run("stream filtered transactions as csv", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?format=csv&from=2024-01-01&to=2024-01-31&category=food", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(fmt.Sprintf(exportStmt, "spender_id = $1 AND deleted_at IS NULL AND category = $2 AND DATE(date) >= $3 AND DATE(date) <= $4")).
+			WithArgs(1, "food", "2024-01-01", "2024-01-31").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(fetchStmt).WillReturnRows(sqlmock.NewRows(exportColumns).
+			AddRow(1, "2024-01-02", "expense", "food", 120.5, "lunch, with team", "", -120.5).
+			AddRow(2, "2024-01-03", "expense", "food", 80.0, "", "http://image.com/2", -200.5))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="transactions-1.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "id,date,transaction_type,category,amount,note,image_url,running_balance\n"+
+			"1,2024-01-02,expense,food,120.50,\"lunch, with team\",,-120.50\n"+
+			"2,2024-01-03,expense,food,80.00,,http://image.com/2,-200.50\n", rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject unknown format", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?format=json", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("reject invalid date", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?from=01-01-2024", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "from must be a date like 2024-01-31"}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,109 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const dateLayout = "2006-01-02"
+
+// Filter holds the query parameters shared by listing and exporting
+// transactions, every value ends up as a bind parameter.
+type Filter struct {
+	Date            string
+	Amount          *float64
+	Category        string
+	TransactionType string
+	From            string
+	To              string
+	Tags            []string
+	TagsMatch       string // tag.MatchAny or tag.MatchAll
+}
+
+func parseFilter(c echo.Context) (Filter, error) {
+	f := Filter{
+		Date:            c.QueryParam("date"),
+		Category:        c.QueryParam("category"),
+		TransactionType: c.QueryParam("transaction_type"),
+		From:            c.QueryParam("from"),
+		To:              c.QueryParam("to"),
+	}
+
+	if amount := c.QueryParam("amount"); amount != "" {
+		v, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return Filter{}, errors.New("Invalid amount format")
+		}
+		f.Amount = &v
+	}
+
+	if raw := c.QueryParam("tags"); raw != "" {
+		tags, err := tag.NormalizeAll(strings.Split(raw, ","))
+		if err != nil {
+			return Filter{}, err
+		}
+		f.Tags = tags
+	}
+	switch f.TagsMatch = c.QueryParam("tags_match"); f.TagsMatch {
+	case "":
+		f.TagsMatch = tag.MatchAny
+	case tag.MatchAny, tag.MatchAll:
+	default:
+		return Filter{}, errors.New("tags_match must be any or all")
+	}
+
+	for _, p := range [][2]string{{"date", f.Date}, {"from", f.From}, {"to", f.To}} {
+		if p[1] == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, p[1]); err != nil {
+			return Filter{}, fmt.Errorf("%s must be a date like 2024-01-31", p[0])
+		}
+	}
+	return f, nil
+}
+
+// clauses appends the filter conditions to where, numbering the
+// placeholders after the args already bound by the caller.
+func (f Filter) clauses(where []string, args []any) ([]string, []any) {
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Date != "" {
+		add("DATE(date) = $%d", f.Date)
+	}
+	if f.Amount != nil {
+		add("amount = $%d", *f.Amount)
+	}
+	if f.Category != "" {
+		add("category = $%d", f.Category)
+	}
+	if f.TransactionType != "" {
+		add("transaction_type = $%d", f.TransactionType)
+	}
+	if f.From != "" {
+		add("DATE(date) >= $%d", f.From)
+	}
+	if f.To != "" {
+		add("DATE(date) <= $%d", f.To)
+	}
+	if len(f.Tags) > 0 {
+		// only the transaction's own spender's tags count, all of them means
+		// every distinct name asked for matched
+		cond := `id IN (SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id AND g.spender_id = "transaction".spender_id WHERE g.name = ANY($%d::text[]))`
+		if f.TagsMatch == tag.MatchAll {
+			cond = `id IN (SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id AND g.spender_id = "transaction".spender_id WHERE g.name = ANY($%[1]d::text[]) GROUP BY tt.transaction_id HAVING COUNT(DISTINCT g.name) = cardinality($%[1]d::text[]))`
+		}
+		add(cond, pq.Array(f.Tags))
+	}
+	return where, args
+}
@@ -0,0 +1,52 @@
+package transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func filterFrom(t *testing.T, query string) (Filter, error) {
+	e := echo.New()
+	t.Cleanup(func() { e.Close() })
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return parseFilter(e.NewContext(req, httptest.NewRecorder()))
+}
+
+func TestFilterTags(t *testing.T) {
+	t.Run("any of the tags", func(t *testing.T) {
+		f, err := filterFrom(t, "category=food&tags=%23Trip-Japan,reimbursable")
+		assert.NoError(t, err)
+
+		where, args := f.clauses([]string{"spender_id = $1"}, []any{1})
+
+		assert.Equal(t, []string{
+			"spender_id = $1",
+			"category = $2",
+			`id IN (SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id AND g.spender_id = "transaction".spender_id WHERE g.name = ANY($3::text[]))`,
+		}, where)
+		assert.Equal(t, []any{1, "food", pq.Array([]string{"trip-japan", "reimbursable"})}, args)
+	})
+
+	t.Run("all of the tags", func(t *testing.T) {
+		f, err := filterFrom(t, "tags=trip-japan,reimbursable&tags_match=all")
+		assert.NoError(t, err)
+
+		where, _ := f.clauses(nil, nil)
+
+		assert.Equal(t, []string{
+			`id IN (SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id AND g.spender_id = "transaction".spender_id WHERE g.name = ANY($1::text[]) GROUP BY tt.transaction_id HAVING COUNT(DISTINCT g.name) = cardinality($1::text[]))`,
+		}, where)
+	})
+
+	t.Run("reject unknown match", func(t *testing.T) {
+		_, err := filterFrom(t, "tags=food&tags_match=some")
+
+		assert.EqualError(t, err, "tags_match must be any or all")
+	})
+}
@@ -0,0 +1,375 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/merchant"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// NegativeIsExpense is the usual bank export, money out is negative.
+	NegativeIsExpense = "negative_is_expense"
+	// PositiveIsExpense is common on credit card exports.
+	PositiveIsExpense = "positive_is_expense"
+
+	maxImportRows = 5000
+
+	// maxAmount is the largest amount the DECIMAL(10,2) column holds
+	maxAmount = 99999999.99
+
+	spenderExistsStmt = `SELECT EXISTS (SELECT 1 FROM spender WHERE id = $1 AND deleted_at IS NULL)`
+	duplicateStmt     = `SELECT EXISTS (SELECT 1 FROM "transaction" WHERE spender_id = $1 AND DATE(date) = $2 AND amount = $3 AND transaction_type = $4 AND note = $5 AND deleted_at IS NULL)`
+)
+
+// ColumnMapping tells which CSV header holds which field. DateFormat uses
+// YYYY, MM and DD, e.g. DD/MM/YYYY.
+type ColumnMapping struct {
+	Date            string `json:"date"`
+	DateFormat      string `json:"date_format"`
+	Amount          string `json:"amount"`
+	AmountSign      string `json:"amount_sign"`
+	Description     string `json:"description"`
+	Category        string `json:"category"`
+	DefaultCategory string `json:"default_category"`
+	Merchant        string `json:"merchant"`
+}
+
+// ImportRow is one parsed line of the file, Line counts the header as 1.
+type ImportRow struct {
+	Line            int      `json:"line"`
+	Date            string   `json:"date,omitempty"`
+	Amount          float64  `json:"amount,omitempty"`
+	Category        string   `json:"category,omitempty"`
+	TransactionType string   `json:"transaction_type,omitempty"`
+	Note            string   `json:"note,omitempty"`
+	Merchant        string   `json:"merchant,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Duplicate       bool     `json:"duplicate"`
+	Error           string   `json:"error,omitempty"`
+
+	merchantID *int64
+}
+
+type ImportResult struct {
+	DryRun     bool        `json:"dry_run"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+var (
+	// ErrAmountRange is an amount too large to be recorded.
+	ErrAmountRange = errors.New("amount must be at most 99,999,999.99")
+	errNotANumber  = errors.New("amount is not a number")
+)
+
+var dateTokens = strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02")
+
+func (m *ColumnMapping) validate() error {
+	if m.Date == "" || m.Amount == "" {
+		return errors.New("mapping needs the date and amount columns")
+	}
+	if m.DateFormat == "" {
+		m.DateFormat = "YYYY-MM-DD"
+	}
+	switch m.AmountSign {
+	case "":
+		m.AmountSign = NegativeIsExpense
+	case NegativeIsExpense, PositiveIsExpense:
+	default:
+		return errors.New("amount_sign must be negative_is_expense or positive_is_expense")
+	}
+	if m.DefaultCategory == "" {
+		m.DefaultCategory = "uncategorized"
+	}
+	return nil
+}
+
+// parseCSV reads every line of r with the mapping, a line that can't be
+// used carries an Error instead of failing the whole file.
+func parseCSV(r io.Reader, m ColumnMapping) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the header: %w", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{m.Date, m.Amount, m.Description, m.Category, m.Merchant} {
+		if _, ok := col[name]; name != "" && !ok {
+			return nil, fmt.Errorf("column %q is not in the file", name)
+		}
+	}
+
+	layout := dateTokens.Replace(m.DateFormat)
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && name != "" && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rows := []ImportRow{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("a file can have at most %d rows", maxImportRows)
+		}
+
+		row := ImportRow{Line: line}
+		if err != nil {
+			row.Error = err.Error()
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Note = field(record, m.Description)
+		row.Merchant = field(record, m.Merchant)
+		row.Category = field(record, m.Category)
+		if row.Category == "" {
+			row.Category = m.DefaultCategory
+		}
+
+		date, err := time.Parse(layout, field(record, m.Date))
+		if err != nil {
+			row.Error = fmt.Sprintf("date must be like %s", m.DateFormat)
+			rows = append(rows, row)
+			continue
+		}
+		row.Date = date.Format(dateLayout)
+
+		amount, err := ParseAmount(field(record, m.Amount))
+		if errors.Is(err, ErrAmountRange) {
+			row.Error = err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		if err != nil || amount == 0 {
+			row.Error = "amount must be a non zero number"
+			rows = append(rows, row)
+			continue
+		}
+		if m.AmountSign == PositiveIsExpense {
+			amount = -amount
+		}
+		row.TransactionType = "income"
+		if amount < 0 {
+			row.TransactionType = "expense"
+		}
+		row.Amount = math.Abs(amount)
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseAmount reads the amount of a statement or CSV line. It accepts
+// thousands separators and accounting negatives such as (1,200.00), NaN,
+// infinities and amounts the amount column can't hold are errors.
+func ParseAmount(s string) (float64, error) {
+	s = strings.NewReplacer(",", "", " ", "").Replace(s)
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	if negative {
+		s = s[1 : len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errNotANumber
+	}
+	if math.Abs(v) > maxAmount {
+		return 0, ErrAmountRange
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// categorizeRows runs the spender's merchant rules on the rows that name a
+// merchant, a dry run leaves new merchants out of the merchant table.
+func categorizeRows(ctx context.Context, q merchant.Querier, spenderID int, rows []ImportRow, create bool) error {
+	lines := make([]merchant.Line, len(rows))
+	for i, r := range rows {
+		if r.Error == "" {
+			lines[i] = merchant.Line{Name: r.Merchant, Category: r.Category}
+		}
+	}
+	res, err := merchant.CategorizeLines(ctx, q, int64(spenderID), lines, create)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		if r := &rows[i]; r.Error == "" {
+			r.Merchant, r.merchantID, r.Category, r.Tags = res[i].Merchant, res[i].MerchantID, res[i].Category, res[i].Tags
+		}
+	}
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// markDuplicates flags the rows already recorded for the spender with the
+// same date, amount, type and note.
+func markDuplicates(ctx context.Context, q queryRower, spenderID int, rows []ImportRow) error {
+	for i := range rows {
+		if rows[i].Error != "" {
+			continue
+		}
+		r := rows[i]
+		if err := q.QueryRowContext(ctx, duplicateStmt, spenderID, r.Date, r.Amount, r.TransactionType, r.Note).Scan(&rows[i].Duplicate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// POST /api/v1/spenders/:id/transactions/import?dry_run=true
+// Multipart form with the CSV in "file" and a ColumnMapping as JSON in
+// "mapping". A dry run only parses and reports, otherwise every valid row
+// that is not a duplicate is inserted in one DB transaction.
+func (h handlerTransaction) Import(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var ok bool
+	if err := h.db.QueryRowContext(ctx, spenderExistsStmt, spenderID).Scan(&ok); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !ok {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+
+	var m ColumnMapping
+	if err := json.Unmarshal([]byte(c.FormValue("mapping")), &m); err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "mapping must be a JSON object")
+	}
+	if err := m.validate(); err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "file is required")
+	}
+	src, err := file.Open()
+	if err != nil {
+		logger.Error("open upload error", zap.Error(err))
+		return apierror.Respond(c, http.StatusBadRequest, "cannot read the file")
+	}
+	defer src.Close()
+
+	rows, err := parseCSV(src, m)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+
+	result := ImportResult{DryRun: dryRun, Rows: rows}
+	for _, r := range rows {
+		if r.Error != "" {
+			result.Invalid++
+		}
+	}
+
+	if dryRun {
+		if err := categorizeRows(ctx, h.db, spenderID, rows, false); err != nil {
+			logger.Error("query error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		if err := markDuplicates(ctx, h.db, spenderID, rows); err != nil {
+			logger.Error("query row error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		for _, r := range rows {
+			if r.Duplicate {
+				result.Duplicates++
+			}
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+
+	// all or nothing, a file with bad lines has to be fixed first, the
+	// problem lists every row with what is wrong with it
+	if result.Invalid > 0 {
+		return apierror.RespondWith(c, http.StatusUnprocessableEntity, fmt.Sprintf("%d lines are invalid, nothing was imported", result.Invalid),
+			map[string]any{"invalid": result.Invalid, "rows": result.Rows})
+	}
+
+	result.Imported, result.Duplicates, err = h.importRows(ctx, spenderID, rows)
+	if err != nil {
+		logger.Error("import error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("import successfully", zap.Int("imported", result.Imported), zap.Int("duplicates", result.Duplicates))
+	return c.JSON(http.StatusCreated, result)
+}
+
+// importRows inserts the rows that are not duplicates in one DB
+// transaction. Duplicates are looked up before the first insert so
+// identical lines of the same file are all kept.
+func (h handlerTransaction) importRows(ctx context.Context, spenderID int, rows []ImportRow) (imported, duplicates int, err error) {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := categorizeRows(ctx, tx, spenderID, rows, true); err != nil {
+		return 0, 0, err
+	}
+	if err := markDuplicates(ctx, tx, spenderID, rows); err != nil {
+		return 0, 0, err
+	}
+
+	for _, r := range rows {
+		if r.Duplicate {
+			duplicates++
+			continue
+		}
+		b := TransactionReqBody{Date: r.Date, Amount: r.Amount, Category: r.Category, TransactionType: r.TransactionType, SpenderID: spenderID, Note: r.Note,
+			Merchant: r.Merchant, merchantID: r.merchantID, ruleTags: r.Tags}
+		var id string
+		if err := tx.QueryRowContext(ctx, cStmt, b.args()...).Scan(&id); err != nil {
+			return 0, 0, err
+		}
+		if err := attachRuleTags(ctx, tx, id, b); err != nil {
+			return 0, 0, err
+		}
+		imported++
+	}
+	return imported, duplicates, tx.Commit()
+}
@@ -0,0 +1,222 @@
+package transaction
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const bankCSV = `Posted,Description,Amount
+31/05/2024,Salary,"30,000.00"
+01/06/2024,Coffee,-65.00
+02/06/2024,Refund,abc
+`
+
+var bankMapping = `{"date": "Posted", "date_format": "DD/MM/YYYY", "amount": "Amount", "description": "Description"}`
+
+func importRequest(t *testing.T, target, mapping, content string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	assert.NoError(t, w.WriteField("mapping", mapping))
+	part, err := w.CreateFormFile("file", "statement.csv")
+	assert.NoError(t, err)
+	_, _ = part.Write([]byte(content))
+	assert.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("map columns and flag bad lines", func(t *testing.T) {
+		m := ColumnMapping{Date: "Posted", DateFormat: "DD/MM/YYYY", Amount: "Amount", Description: "Description"}
+		assert.NoError(t, m.validate())
+
+		rows, err := parseCSV(strings.NewReader(bankCSV), m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ImportRow{
+			{Line: 2, Date: "2024-05-31", Amount: 30000, Category: "uncategorized", TransactionType: "income", Note: "Salary"},
+			{Line: 3, Date: "2024-06-01", Amount: 65, Category: "uncategorized", TransactionType: "expense", Note: "Coffee"},
+			{Line: 4, Date: "2024-06-02", Category: "uncategorized", Note: "Refund", Error: "amount must be a non zero number"},
+		}, rows)
+	})
+
+	t.Run("credit card exports count positive amounts as expenses", func(t *testing.T) {
+		m := ColumnMapping{Date: "date", Amount: "amount", AmountSign: PositiveIsExpense, DefaultCategory: "card"}
+		assert.NoError(t, m.validate())
+
+		rows, err := parseCSV(strings.NewReader("date,amount\n2024-06-01,(20.00)\n2024-06-02,120.50\n"), m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "income", rows[0].TransactionType)
+		assert.Equal(t, 20.0, rows[0].Amount)
+		assert.Equal(t, "expense", rows[1].TransactionType)
+		assert.Equal(t, 120.5, rows[1].Amount)
+	})
+
+	t.Run("amounts that are not finite or too large are bad lines", func(t *testing.T) {
+		m := ColumnMapping{Date: "date", Amount: "amount"}
+		assert.NoError(t, m.validate())
+
+		rows, err := parseCSV(strings.NewReader("date,amount\n2024-06-01,NaN\n2024-06-02,-Inf\n2024-06-03,1e308\n"), m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "amount must be a non zero number", rows[0].Error)
+		assert.Equal(t, "amount must be a non zero number", rows[1].Error)
+		assert.Equal(t, "amount must be at most 99,999,999.99", rows[2].Error)
+	})
+
+	t.Run("mapped column missing from the file", func(t *testing.T) {
+		m := ColumnMapping{Date: "Date", Amount: "Amount"}
+		assert.NoError(t, m.validate())
+
+		_, err := parseCSV(strings.NewReader(bankCSV), m)
+
+		assert.EqualError(t, err, `column "Date" is not in the file`)
+	})
+}
+
+func TestParseAmount(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want float64
+		err  error
+	}{
+		{"1,200.50", 1200.5, nil},
+		{"(1,200.00)", -1200, nil},
+		{"-99999999.99", -99999999.99, nil},
+		{"100000000", 0, ErrAmountRange},
+		{"1e308", 0, ErrAmountRange},
+		{"NaN", 0, errNotANumber},
+		{"Inf", 0, errNotANumber},
+		{"1e309", 0, errNotANumber},
+		{"abc", 0, errNotANumber},
+	} {
+		got, err := ParseAmount(tc.in)
+
+		assert.Equal(t, tc.want, got, tc.in)
+		assert.ErrorIs(t, err, tc.err, tc.in)
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Run("dry run reports rows, errors and duplicates", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(importRequest(t, "/?dry_run=true", bankMapping, bankCSV), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(spenderExistsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(duplicateStmt).WithArgs(1, "2024-05-31", 30000.0, "income", "Salary").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(duplicateStmt).WithArgs(1, "2024-06-01", 65.0, "expense", "Coffee").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"dry_run": true, "imported": 0, "duplicates": 1, "invalid": 1, "rows": [
+			{"line": 2, "date": "2024-05-31", "amount": 30000, "category": "uncategorized", "transaction_type": "income", "note": "Salary", "duplicate": true},
+			{"line": 3, "date": "2024-06-01", "amount": 65, "category": "uncategorized", "transaction_type": "expense", "note": "Coffee", "duplicate": false},
+			{"line": 4, "date": "2024-06-02", "category": "uncategorized", "note": "Refund", "duplicate": false, "error": "amount must be a non zero number"}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("commit refuses a file with invalid lines", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(importRequest(t, "/", bankMapping, bankCSV), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(spenderExistsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, apierror.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Unprocessable Entity", "status": 422, "detail": "1 lines are invalid, nothing was imported", "invalid": 1, "rows": [
+			{"line": 2, "date": "2024-05-31", "amount": 30000, "category": "uncategorized", "transaction_type": "income", "note": "Salary", "duplicate": false},
+			{"line": 3, "date": "2024-06-01", "amount": 65, "category": "uncategorized", "transaction_type": "expense", "note": "Coffee", "duplicate": false},
+			{"line": 4, "date": "2024-06-02", "category": "uncategorized", "note": "Refund", "duplicate": false, "error": "amount must be a non zero number"}
+		]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("commit inserts new rows in one transaction and skips duplicates", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		content := "Posted,Description,Amount\n31/05/2024,Salary,\"30,000.00\"\n01/06/2024,Coffee,-65.00\n"
+		rec := httptest.NewRecorder()
+		c := e.NewContext(importRequest(t, "/", bankMapping, content), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(spenderExistsStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(duplicateStmt).WithArgs(1, "2024-05-31", 30000.0, "income", "Salary").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(duplicateStmt).WithArgs(1, "2024-06-01", 65.0, "expense", "Coffee").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery(cStmt).WithArgs("2024-06-01", 65.0, "uncategorized", "expense", 1, "Coffee", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"imported":1,"duplicates":1,"invalid":0`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("spender not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(importRequest(t, "/", bankMapping, bankCSV), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(spenderExistsStmt).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,133 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Page asks for one page of a keyset listing in Sort order. Unlike OFFSET
+// it doesn't skip or repeat rows when transactions are added while the
+// client is paging.
+type Page struct {
+	Sort   Sort
+	Cursor *Cursor // nil for the first page
+	Limit  int
+	Total  bool // also count every matching row
+}
+
+// Cursor is the row a page continues from: its sort key values and id.
+// Before pages back towards the start, the way prev_cursor goes. Sort
+// is the order the cursor was made for.
+type Cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     string   `json:"id"`
+	Before bool     `json:"b,omitempty"`
+}
+
+func (cur Cursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor rejects cursors made for another sort, the values they hold
+// would not mean the same thing.
+func decodeCursor(s string, sort Sort) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cur Cursor
+	if err := json.Unmarshal(raw, &cur); err != nil || cur.Sort != sort.String() || !sort.valid(cur.Values) {
+		return nil, errInvalidCursor
+	}
+	if _, err := strconv.ParseInt(cur.ID, 10, 64); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cur, nil
+}
+
+// parsePage reads the keyset parameters. It reports false when the request
+// has no cursor parameter, old clients paging by page number keep OFFSET.
+// An empty cursor asks for the first page.
+func parsePage(c echo.Context, sort Sort) (Page, bool, error) {
+	if !c.QueryParams().Has("cursor") {
+		return Page{}, false, nil
+	}
+
+	p := Page{Sort: sort, Limit: defaultPageLimit, Total: c.QueryParam("include_total") == "true"}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return Page{}, true, errors.New("Please check your page limit")
+		}
+		p.Limit = min(limit, maxPageLimit)
+	}
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cur, err := decodeCursor(raw, sort)
+		if err != nil {
+			return Page{}, true, err
+		}
+		p.Cursor = cur
+	}
+	return p, true, nil
+}
+
+// clauses appends the keyset condition to where and returns the ORDER BY
+// and LIMIT to go with it. One row more than the limit is fetched to tell
+// whether there is another page.
+func (p Page) clauses(where []string, args []any) ([]string, []any, string) {
+	reverse := false
+	if p.Cursor != nil {
+		var cond string
+		cond, args = p.Sort.after(*p.Cursor, args)
+		where = append(where, cond)
+		reverse = p.Cursor.Before
+	}
+	return where, args, fmt.Sprintf("ORDER BY %s LIMIT %d", p.Sort.orderBy(reverse), p.Limit+1)
+}
+
+// paginate trims the extra row fetched by clauses, puts the rows back in
+// sort order and sets the cursors of the pages around them.
+func (p Page) paginate(txs []Transaction) ([]Transaction, PaginationInfo) {
+	more := len(txs) > p.Limit
+	if more {
+		txs = txs[:p.Limit]
+	}
+
+	later, earlier := more, p.Cursor != nil
+	if p.Cursor != nil && p.Cursor.Before {
+		slices.Reverse(txs)
+		later, earlier = true, more
+	}
+
+	info := PaginationInfo{PerPage: p.Limit}
+	if len(txs) == 0 {
+		return txs, info
+	}
+	if later {
+		last := txs[len(txs)-1]
+		info.NextCursor = p.cursor(last, false).encode()
+	}
+	if earlier {
+		info.PrevCursor = p.cursor(txs[0], true).encode()
+	}
+	return txs, info
+}
+
+func (p Page) cursor(tx Transaction, before bool) Cursor {
+	return Cursor{Sort: p.Sort.String(), Values: p.Sort.values(tx), ID: tx.ID, Before: before}
+}
@@ -0,0 +1,78 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var newestFirst = Sort{{column: "date", desc: true}}
+
+func TestCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		cur := Cursor{Sort: "-date", Values: []string{"2024-04-30T09:00:00.5+07:00"}, ID: "12", Before: true}
+
+		got, err := decodeCursor(cur.encode(), newestFirst)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &cur, got)
+	})
+
+	t.Run("reject cursors the server did not issue", func(t *testing.T) {
+		for _, raw := range []string{
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte("v1:n:1:2024-04-30T09:00:00Z")),
+			Cursor{Sort: "amount", Values: []string{"10"}, ID: "1"}.encode(),
+			Cursor{Sort: "-date", Values: []string{"2024-04-30T09:00:00Z"}, ID: "1 OR 1=1"}.encode(),
+			Cursor{Sort: "-date", Values: []string{"yesterday"}, ID: "1"}.encode(),
+			Cursor{Sort: "-date", ID: "1"}.encode(),
+		} {
+			_, err := decodeCursor(raw, newestFirst)
+
+			assert.ErrorIs(t, err, errInvalidCursor, raw)
+		}
+	})
+}
+
+func txsWithIDs(ids ...string) []Transaction {
+	txs := make([]Transaction, len(ids))
+	for i, id := range ids {
+		txs[i] = Transaction{ID: id, Date: "2024-04-0" + id + "T00:00:00Z"}
+	}
+	return txs
+}
+
+func cursorAt(id string, before bool) string {
+	return Cursor{Sort: "-date", Values: []string{"2024-04-0" + id + "T00:00:00Z"}, ID: id, Before: before}.encode()
+}
+
+func TestPaginate(t *testing.T) {
+	t.Run("first page only links to later rows", func(t *testing.T) {
+		txs, info := Page{Sort: newestFirst, Limit: 2}.paginate(txsWithIDs("5", "4", "3"))
+
+		assert.Equal(t, txsWithIDs("5", "4"), txs)
+		assert.Equal(t, cursorAt("4", false), info.NextCursor)
+		assert.Empty(t, info.PrevCursor)
+	})
+
+	t.Run("last page only links back", func(t *testing.T) {
+		page := Page{Sort: newestFirst, Limit: 2, Cursor: &Cursor{Sort: "-date", Values: []string{"2024-04-03T00:00:00Z"}, ID: "3"}}
+
+		txs, info := page.paginate(txsWithIDs("2"))
+
+		assert.Equal(t, txsWithIDs("2"), txs)
+		assert.Empty(t, info.NextCursor)
+		assert.Equal(t, cursorAt("2", true), info.PrevCursor)
+	})
+
+	t.Run("paging back returns rows in sort order", func(t *testing.T) {
+		page := Page{Sort: newestFirst, Limit: 2, Cursor: &Cursor{Sort: "-date", Values: []string{"2024-04-02T00:00:00Z"}, ID: "2", Before: true}}
+
+		txs, info := page.paginate(txsWithIDs("3", "4", "5"))
+
+		assert.Equal(t, txsWithIDs("4", "3"), txs)
+		assert.Equal(t, cursorAt("3", false), info.NextCursor)
+		assert.Equal(t, cursorAt("4", true), info.PrevCursor)
+	})
+}
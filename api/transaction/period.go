@@ -0,0 +1,86 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/labstack/echo/v4"
+)
+
+// Period bounds a summary to the spender's day, week, month or year, no
+// Kind is all time. From and To are the first and last day in the
+// spender's time zone. A summary only counts money in the home currency,
+// amounts in other currencies are not added up with it.
+type Period struct {
+	Kind     string `json:"kind"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+
+	start, end time.Time
+	currency   string
+}
+
+// parsePeriod reads ?period=month&at=2024-05-10, at defaults to today.
+// Without period the summary covers all time.
+func parsePeriod(c echo.Context, prefs spender.Preferences, now time.Time) (Period, error) {
+	kind := c.QueryParam("period")
+	if kind == "" {
+		if c.QueryParam("at") != "" {
+			return Period{}, errors.New("at needs a period")
+		}
+		return allTime(prefs.Currency), nil
+	}
+
+	at := now
+	if s := c.QueryParam("at"); s != "" {
+		var err error
+		if at, err = time.ParseInLocation(dateLayout, s, prefs.Location()); err != nil {
+			return Period{}, errors.New("at must be a date like 2024-01-31")
+		}
+	}
+
+	start, end, err := prefs.Period(kind, at)
+	if err != nil {
+		return Period{}, err
+	}
+	return Period{
+		Kind:     kind,
+		From:     start.Format(dateLayout),
+		To:       end.AddDate(0, 0, -1).Format(dateLayout),
+		Timezone: prefs.Timezone,
+		start:    start,
+		end:      end,
+		currency: prefs.Currency,
+	}, nil
+}
+
+// allTime is the period of a summary that isn't cut by date, in currency.
+func allTime(currency string) Period {
+	return Period{currency: currency}
+}
+
+// clause is the condition on the date and account columns of alias,
+// numbering its placeholders after the args already bound. A transaction
+// without an account is taken to be in the home currency.
+func (p Period) clause(alias string, args []any) (string, []any) {
+	var cond string
+	if p.Kind != "" {
+		args = append(args, p.start, p.end)
+		cond = fmt.Sprintf(" AND %[1]sdate >= $%[2]d AND %[1]sdate < $%[3]d", alias, len(args)-1, len(args))
+	}
+	if p.currency != "" {
+		args = append(args, p.currency)
+		cond += " AND " + HomeCurrency(alias, len(args))
+	}
+	return cond, args
+}
+
+// HomeCurrency is the condition keeping the transactions of alias in the
+// currency bound at placeholder n, for the queries outside this package
+// that add money up the way the summaries do.
+func HomeCurrency(alias string, n int) string {
+	return fmt.Sprintf("(%[1]saccount_id IS NULL OR %[1]saccount_id IN (SELECT id FROM account WHERE currency = $%[2]d))", alias, n)
+}
@@ -0,0 +1,85 @@
+package transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func periodFrom(t *testing.T, query string, prefs spender.Preferences, now time.Time) (Period, error) {
+	e := echo.New()
+	t.Cleanup(func() { e.Close() })
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return parsePeriod(e.NewContext(req, httptest.NewRecorder()), prefs, now)
+}
+
+func TestParsePeriod(t *testing.T) {
+	prefs := spender.Preferences{Timezone: "Asia/Bangkok", Currency: "THB", WeekStart: "monday", MonthStartDay: 25}
+	now := time.Date(2024, 5, 24, 18, 0, 0, 0, time.UTC)
+
+	t.Run("all time without a period", func(t *testing.T) {
+		p, err := periodFrom(t, "", prefs, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, allTime("THB"), p)
+	})
+
+	t.Run("the period of today in the spender's time zone", func(t *testing.T) {
+		// 18:00 UTC on the 24th is already payday in Bangkok
+		p, err := periodFrom(t, "period=month", prefs, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "month", p.Kind)
+		assert.Equal(t, "2024-05-25", p.From)
+		assert.Equal(t, "2024-06-24", p.To)
+		assert.Equal(t, "Asia/Bangkok", p.Timezone)
+	})
+
+	t.Run("the period of a given date", func(t *testing.T) {
+		p, err := periodFrom(t, "period=week&at=2024-05-01", prefs, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "2024-04-29", p.From)
+		assert.Equal(t, "2024-05-05", p.To)
+	})
+
+	t.Run("reject a bad period or date", func(t *testing.T) {
+		for _, query := range []string{"at=2024-05-01", "period=fortnight", "period=day&at=01/05/2024"} {
+			_, err := periodFrom(t, query, prefs, now)
+
+			assert.Error(t, err, query)
+		}
+	})
+}
+
+func TestPeriodClause(t *testing.T) {
+	t.Run("no condition for all time", func(t *testing.T) {
+		cond, args := Period{}.clause("t.", []any{"1"})
+
+		assert.Empty(t, cond)
+		assert.Equal(t, []any{"1"}, args)
+	})
+
+	t.Run("only the home currency for all time", func(t *testing.T) {
+		cond, args := allTime("THB").clause("", []any{"1"})
+
+		assert.Equal(t, " AND (account_id IS NULL OR account_id IN (SELECT id FROM account WHERE currency = $2))", cond)
+		assert.Equal(t, []any{"1", "THB"}, args)
+	})
+
+	t.Run("bound the date after the args already there", func(t *testing.T) {
+		from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		p := Period{Kind: "month", start: from, end: from.AddDate(0, 1, 0), currency: "THB"}
+
+		cond, args := p.clause("t.", []any{"1"})
+
+		assert.Equal(t, " AND t.date >= $2 AND t.date < $3 AND (t.account_id IS NULL OR t.account_id IN (SELECT id FROM account WHERE currency = $4))", cond)
+		assert.Equal(t, []any{"1", p.start, p.end, "THB"}, args)
+	})
+}
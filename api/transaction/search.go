@@ -0,0 +1,109 @@
+package transaction
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/like"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+
+	// total comes along with every row so a search is one round trip
+	searchStmt = `SELECT id, date, amount, category, transaction_type, spender_id, note, image_url, created_at, merchant, COUNT(*) OVER () AS total
+		FROM "transaction" WHERE %s ORDER BY %s DESC, date DESC, id DESC LIMIT %d OFFSET %d`
+)
+
+// tsQuery turns what the user typed into a prefix match of every word, so
+// "coff star" finds "Coffee at Starbucks". Anything that isn't part of a
+// word is dropped, it would be tsquery syntax otherwise.
+func tsQuery(q string) string {
+	var terms []string
+	for _, field := range strings.Fields(q) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+				return r
+			}
+			return -1
+		}, field)
+		if term != "" {
+			terms = append(terms, term+":*")
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// GET /api/v1/spenders/:id/transactions/search?q=coffee&from=2024-03-01&to=2024-03-31
+// Searches merchant, category and note, best match first. Takes the same
+// filters as GET /transactions and pages with page and limit.
+func (h handlerTransaction) Search(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	q := strings.TrimSpace(c.QueryParam("q"))
+	if q == "" {
+		return apierror.Respond(c, http.StatusBadRequest, "q is required")
+	}
+	filter, err := parseFilter(c)
+	if err != nil {
+		return apierror.Invalid(c, err)
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	where, args := filter.clauses([]string{"spender_id = $1", "deleted_at IS NULL"}, []any{spenderID})
+	args = append(args, tsQuery(q), strings.ToLower(q), like.Contains(strings.ToLower(q)))
+	n := len(args)
+	where = append(where, fmt.Sprintf("(search_vector @@ to_tsquery('simple', $%d) OR search_text ILIKE $%d)", n-2, n))
+	rank := fmt.Sprintf("ts_rank(search_vector, to_tsquery('simple', $%d)) + similarity(search_text, $%d)", n-2, n-1)
+
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(searchStmt, strings.Join(where, " AND "), rank, limit, (page-1)*limit), args...)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	txs := []Transaction{}
+	total := 0
+	for rows.Next() {
+		var tx Transaction
+		if err := rows.Scan(&tx.ID, &tx.Date, &tx.Amount, &tx.Category, &tx.TransactionType, &tx.SpenderID, &tx.Note, &tx.ImageURL, &tx.CreatedAt, &tx.Merchant, &total); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		txs = append(txs, tx)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	pagination := PaginationInfo{CurrentPage: page, PerPage: limit}
+	if len(txs) > 0 {
+		pagination = pagination.WithTotal(total)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"transactions": txs,
+		"pagination":   pagination,
+	})
+}
@@ -0,0 +1,78 @@
+package transaction
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTsQuery(t *testing.T) {
+	assert.Equal(t, "coff:* & star:*", tsQuery("coff  star"))
+	assert.Equal(t, "กาแฟ:* & น้ำ:* & cafe:*", tsQuery("กาแฟ น้ำ, cafe!"))
+	assert.Equal(t, "drop:* & x:*", tsQuery("drop | x:* & !"))
+	assert.Equal(t, "", tsQuery("&|!"))
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("rank matches within the filters", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?q="+url.QueryEscape("coffee shop")+"&from=2024-03-01&to=2024-03-31&limit=5", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		query := fmt.Sprintf(searchStmt,
+			"spender_id = $1 AND deleted_at IS NULL AND DATE(date) >= $2 AND DATE(date) <= $3 AND (search_vector @@ to_tsquery('simple', $4) OR search_text ILIKE $6)",
+			"ts_rank(search_vector, to_tsquery('simple', $4)) + similarity(search_text, $5)", 5, 0)
+		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at", "merchant", "total"}).
+			AddRow("7", "2024-03-14T08:00:00Z", 85.0, "coffee", "expense", 1, "latte", "", "2024-03-14T08:01:00Z", "Coffee Shop Ari", 6)
+		mock.ExpectQuery(query).WithArgs(1, "2024-03-01", "2024-03-31", "coffee:* & shop:*", "coffee shop", "%coffee shop%").WillReturnRows(rows)
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Search(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [
+				{"id": "7", "date": "2024-03-14T08:00:00Z", "amount": 85, "category": "coffee", "transaction_type": "expense", "spender_id": 1, "note": "latte", "image_url": "", "created_at": "2024-03-14T08:01:00Z", "merchant": "Coffee Shop Ari"}
+			],
+			"pagination": {"current_page": 1, "total_pages": 2, "per_page": 5, "total_count": 6}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("q is required", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?q=+", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Search(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "q is required"}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,176 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ShareEqual      = "equal"
+	ShareExact      = "exact"
+	SharePercentage = "percentage"
+)
+
+// ShareRequest splits the bill of an expense between participants, the
+// spender of the transaction is the payer and everybody else owes them
+// their share.
+type ShareRequest struct {
+	Type         string        `json:"type"`
+	Participants []Participant `json:"participants"`
+}
+
+// Participant carries Amount for exact shares and Percent for percentage
+// shares, equal shares need only the spender id.
+type Participant struct {
+	SpenderID int     `json:"spender_id"`
+	Amount    float64 `json:"amount,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+}
+
+type Share struct {
+	SpenderID int     `json:"spender_id"`
+	Amount    float64 `json:"amount"`
+}
+
+const (
+	// the share lock keeps a participant from being deleted before the
+	// shares commit
+	participantsStmt = `SELECT id FROM spender WHERE id = ANY($1::int[]) AND deleted_at IS NULL FOR SHARE`
+	cShareStmt       = `INSERT INTO transaction_share (transaction_id, spender_id, amount) VALUES ($1, $2, $3);`
+	dShareStmt       = `DELETE FROM transaction_share WHERE transaction_id = $1;`
+)
+
+// resolve turns the request into the amount owed by each participant. Cents
+// that can't be divided evenly go to the first participants so the shares
+// always add up to the transaction amount.
+func (r *ShareRequest) resolve(amount float64, transactionType string) ([]Share, error) {
+	if r == nil {
+		return nil, nil
+	}
+	if transactionType != "expense" {
+		return nil, errors.New("only expenses can be shared")
+	}
+	if len(r.Participants) == 0 {
+		return nil, errors.New("sharing needs at least one participant")
+	}
+
+	seen := make(map[int]bool, len(r.Participants))
+	for i, p := range r.Participants {
+		if p.SpenderID <= 0 {
+			return nil, fmt.Errorf("participants[%d]: spender_id is required", i)
+		}
+		if seen[p.SpenderID] {
+			return nil, fmt.Errorf("participants[%d]: spender %d is listed twice", i, p.SpenderID)
+		}
+		seen[p.SpenderID] = true
+	}
+
+	total := cents(amount)
+	parts := make([]int64, len(r.Participants))
+	switch r.Type {
+	case ShareEqual:
+		for i := range parts {
+			parts[i] = total / int64(len(parts))
+		}
+	case ShareExact:
+		for i, p := range r.Participants {
+			if p.Amount <= 0 {
+				return nil, fmt.Errorf("participants[%d]: amount must be greater than zero", i)
+			}
+			parts[i] = cents(p.Amount)
+		}
+	case SharePercentage:
+		var percent float64
+		for i, p := range r.Participants {
+			if p.Percent <= 0 {
+				return nil, fmt.Errorf("participants[%d]: percent must be greater than zero", i)
+			}
+			percent += p.Percent
+			parts[i] = int64(math.Floor(float64(total) * p.Percent / 100))
+		}
+		if math.Abs(percent-100) > 0.001 {
+			return nil, errors.New("percentages must add up to 100")
+		}
+	default:
+		return nil, errors.New("sharing type must be one of equal, exact or percentage")
+	}
+
+	var sum int64
+	for _, p := range parts {
+		sum += p
+	}
+	if r.Type == ShareExact && sum != total {
+		return nil, errors.New("sum of exact shares must equal the transaction amount")
+	}
+	for i := 0; sum < total; i, sum = (i+1)%len(parts), sum+1 {
+		parts[i]++
+	}
+
+	shares := make([]Share, len(parts))
+	for i, p := range r.Participants {
+		shares[i] = Share{SpenderID: p.SpenderID, Amount: float64(parts[i]) / 100}
+	}
+	return shares, nil
+}
+
+// missingParticipantError is a share owed by a spender that does not exist
+// or is deleted.
+type missingParticipantError struct {
+	spenderID int
+}
+
+func (e missingParticipantError) Error() string {
+	return fmt.Sprintf("participant spender %d not found", e.spenderID)
+}
+
+// checkParticipants makes sure every participant is a spender, until tx
+// ends none of them can be deleted.
+func checkParticipants(ctx context.Context, tx *sql.Tx, shares []Share) error {
+	ids := make([]int, len(shares))
+	for i, s := range shares {
+		ids[i] = s.SpenderID
+	}
+	rows, err := tx.QueryContext(ctx, participantsStmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return missingParticipantError{id}
+		}
+	}
+	return nil
+}
+
+func insertShares(ctx context.Context, tx *sql.Tx, transactionID string, shares []Share) error {
+	if len(shares) == 0 {
+		return nil
+	}
+	if err := checkParticipants(ctx, tx, shares); err != nil {
+		return err
+	}
+	for _, s := range shares {
+		if _, err := tx.ExecContext(ctx, cShareStmt, transactionID, s.SpenderID, s.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
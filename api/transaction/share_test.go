@@ -0,0 +1,60 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveShares(t *testing.T) {
+	t.Run("equal shares give leftover cents to the first participants", func(t *testing.T) {
+		r := &ShareRequest{Type: ShareEqual, Participants: []Participant{{SpenderID: 1}, {SpenderID: 2}, {SpenderID: 3}}}
+
+		shares, err := r.resolve(100, "expense")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Share{{1, 33.34}, {2, 33.33}, {3, 33.33}}, shares)
+	})
+
+	t.Run("exact shares must add up", func(t *testing.T) {
+		r := &ShareRequest{Type: ShareExact, Participants: []Participant{{SpenderID: 1, Amount: 70}, {SpenderID: 2, Amount: 20}}}
+
+		_, err := r.resolve(100, "expense")
+
+		assert.EqualError(t, err, "sum of exact shares must equal the transaction amount")
+	})
+
+	t.Run("percentage shares", func(t *testing.T) {
+		r := &ShareRequest{Type: SharePercentage, Participants: []Participant{{SpenderID: 1, Percent: 50}, {SpenderID: 2, Percent: 25}, {SpenderID: 3, Percent: 25}}}
+
+		shares, err := r.resolve(99.99, "expense")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Share{{1, 50}, {2, 25}, {3, 24.99}}, shares)
+	})
+
+	t.Run("percentages must add up to 100", func(t *testing.T) {
+		r := &ShareRequest{Type: SharePercentage, Participants: []Participant{{SpenderID: 1, Percent: 50}, {SpenderID: 2, Percent: 40}}}
+
+		_, err := r.resolve(100, "expense")
+
+		assert.EqualError(t, err, "percentages must add up to 100")
+	})
+
+	t.Run("income cannot be shared", func(t *testing.T) {
+		r := &ShareRequest{Type: ShareEqual, Participants: []Participant{{SpenderID: 1}}}
+
+		_, err := r.resolve(100, "income")
+
+		assert.EqualError(t, err, "only expenses can be shared")
+	})
+
+	t.Run("no sharing", func(t *testing.T) {
+		var r *ShareRequest
+
+		shares, err := r.resolve(100, "expense")
+
+		assert.NoError(t, err)
+		assert.Nil(t, shares)
+	})
+}
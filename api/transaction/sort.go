@@ -0,0 +1,162 @@
+package transaction
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSort = "-date"
+
+// sortColumns are the columns a listing can be sorted by, with the kind of
+// value they hold so cursors can be checked before reaching the query.
+var sortColumns = map[string]string{
+	"date":       "time",
+	"amount":     "number",
+	"category":   "text",
+	"created_at": "time",
+}
+
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// Sort is the order of a listing, as in sort=-date,amount. Rows that tie on
+// every key are ordered by id, in the direction of the last key.
+type Sort []sortKey
+
+func parseSort(raw string) (Sort, error) {
+	if raw == "" {
+		raw = defaultSort
+	}
+
+	var s Sort
+	seen := map[string]bool{}
+	for _, field := range strings.Split(raw, ",") {
+		k := sortKey{column: strings.TrimPrefix(field, "-"), desc: strings.HasPrefix(field, "-")}
+		if _, ok := sortColumns[k.column]; !ok || seen[k.column] {
+			return nil, fmt.Errorf("sort must be a list of date, amount, category or created_at, each optionally prefixed with -")
+		}
+		seen[k.column] = true
+		s = append(s, k)
+	}
+	return s, nil
+}
+
+func (s Sort) String() string {
+	fields := make([]string, len(s))
+	for i, k := range s {
+		fields[i] = k.column
+		if k.desc {
+			fields[i] = "-" + k.column
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// keys are the sort keys followed by the id tie breaker.
+func (s Sort) keys() []sortKey {
+	return append(s[:len(s):len(s)], sortKey{column: "id", desc: s[len(s)-1].desc})
+}
+
+// orderBy is the ORDER BY list, reversed when paging backwards.
+func (s Sort) orderBy(reverse bool) string {
+	keys := s.keys()
+	terms := make([]string, len(keys))
+	for i, k := range keys {
+		dir := "ASC"
+		if k.desc != reverse {
+			dir = "DESC"
+		}
+		terms[i] = k.column + " " + dir
+	}
+	return strings.Join(terms, ", ")
+}
+
+// after is the condition for rows past the cursor in the sort order, or
+// before it when the cursor pages back. The placeholders are numbered
+// after the args already bound.
+func (s Sort) after(cur Cursor, args []any) (string, []any) {
+	keys := s.keys()
+	values := append(cur.Values[:len(cur.Values):len(cur.Values)], cur.ID)
+
+	cmp := func(k sortKey) string {
+		if k.desc != cur.Before {
+			return "<"
+		}
+		return ">"
+	}
+
+	// a row comparison when every key goes the same way, it can use the index
+	same := true
+	for _, k := range s {
+		same = same && k.desc == s[0].desc
+	}
+	if same {
+		columns := make([]string, len(keys))
+		params := make([]string, len(keys))
+		for i, k := range keys {
+			args = append(args, values[i])
+			columns[i] = k.column
+			params[i] = "$" + strconv.Itoa(len(args))
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), cmp(keys[0]), strings.Join(params, ", ")), args
+	}
+
+	// otherwise (a < x) OR (a = x AND b > y) OR ...
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		args = append(args, values[i])
+		placeholders[i] = "$" + strconv.Itoa(len(args))
+	}
+	ors := make([]string, len(keys))
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("%s = %s", keys[j].column, placeholders[j]))
+		}
+		ands = append(ands, fmt.Sprintf("%s %s %s", k.column, cmp(k), placeholders[i]))
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// values are the sort key values of tx, as stored in a cursor.
+func (s Sort) values(tx Transaction) []string {
+	values := make([]string, len(s))
+	for i, k := range s {
+		switch k.column {
+		case "date":
+			values[i] = tx.Date
+		case "amount":
+			values[i] = strconv.FormatFloat(tx.Amount, 'f', -1, 64)
+		case "category":
+			values[i] = tx.Category
+		case "created_at":
+			values[i] = tx.CreatedAt
+		}
+	}
+	return values
+}
+
+// valid checks cursor values against the kind of their column.
+func (s Sort) valid(values []string) bool {
+	if len(values) != len(s) {
+		return false
+	}
+	for i, k := range s {
+		switch sortColumns[k.column] {
+		case "time":
+			if _, err := time.Parse(time.RFC3339Nano, values[i]); err != nil {
+				return false
+			}
+		case "number":
+			if _, err := strconv.ParseFloat(values[i], 64); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
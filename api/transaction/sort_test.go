@@ -0,0 +1,53 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	t.Run("newest first by default", func(t *testing.T) {
+		s, err := parseSort("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "date DESC, id DESC", s.orderBy(false))
+	})
+
+	t.Run("mixed directions break ties by id the way the last key goes", func(t *testing.T) {
+		s, err := parseSort("-date,amount")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "-date,amount", s.String())
+		assert.Equal(t, "date DESC, amount ASC, id ASC", s.orderBy(false))
+		assert.Equal(t, "date ASC, amount DESC, id DESC", s.orderBy(true))
+	})
+
+	t.Run("reject columns outside the whitelist", func(t *testing.T) {
+		for _, raw := range []string{"note", "date;DROP TABLE transaction", "date,-date", "-", "date,"} {
+			_, err := parseSort(raw)
+
+			assert.Error(t, err, raw)
+		}
+	})
+}
+
+func TestSortAfter(t *testing.T) {
+	t.Run("row comparison when every key goes the same way", func(t *testing.T) {
+		s, _ := parseSort("category,amount")
+
+		cond, args := s.after(Cursor{Values: []string{"food", "10.5"}, ID: "7"}, []any{"1"})
+
+		assert.Equal(t, "(category, amount, id) > ($2, $3, $4)", cond)
+		assert.Equal(t, []any{"1", "food", "10.5", "7"}, args)
+	})
+
+	t.Run("expanded comparison for mixed directions", func(t *testing.T) {
+		s, _ := parseSort("-date,amount")
+
+		cond, args := s.after(Cursor{Values: []string{"2024-04-30T09:00:00Z", "10"}, ID: "7", Before: true}, nil)
+
+		assert.Equal(t, "((date > $1) OR (date = $1 AND amount < $2) OR (date = $1 AND amount = $2 AND id < $3))", cond)
+		assert.Equal(t, []any{"2024-04-30T09:00:00Z", "10", "7"}, args)
+	})
+}
@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/lib/pq"
+)
+
+// Split is one line of a transaction that covers several categories,
+// e.g. groceries and household items on the same supermarket slip.
+type Split struct {
+	ID       int64   `json:"id,omitempty"`
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Note     string  `json:"note"`
+}
+
+const (
+	cSplitStmt    = `INSERT INTO transaction_split (transaction_id, category, amount, note) VALUES ($1, $2, $3, $4) RETURNING id;`
+	dSplitStmt    = `DELETE FROM transaction_split WHERE transaction_id = $1;`
+	splitsByTxIDs = `SELECT id, transaction_id, category, amount, note FROM transaction_split WHERE transaction_id = ANY($1::int[]) ORDER BY id`
+)
+
+// cents avoids float rounding when comparing money amounts.
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// validateSplits checks that every line is usable and that the lines add up
+// to the parent amount. No splits at all is valid.
+func validateSplits(amount float64, splits []Split) error {
+	if len(splits) == 0 {
+		return nil
+	}
+
+	var total int64
+	for i, s := range splits {
+		if s.Category == "" {
+			return apierror.Field(fmt.Sprintf("splits[%d].category", i), fmt.Sprintf("splits[%d]: category is required", i))
+		}
+		if s.Amount <= 0 {
+			return apierror.Field(fmt.Sprintf("splits[%d].amount", i), fmt.Sprintf("splits[%d]: amount must be greater than zero", i))
+		}
+		total += cents(s.Amount)
+	}
+	if total != cents(amount) {
+		return apierror.Field("splits", "sum of splits must equal the transaction amount")
+	}
+	return nil
+}
+
+func insertSplits(ctx context.Context, tx *sql.Tx, transactionID string, splits []Split) error {
+	for i := range splits {
+		err := tx.QueryRowContext(ctx, cSplitStmt, transactionID, splits[i].Category, splits[i].Amount, splits[i].Note).Scan(&splits[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachSplits loads the split lines of the given transactions in one query.
+func attachSplits(ctx context.Context, db *sql.DB, txs []Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(txs))
+	byID := make(map[string]*Transaction, len(txs))
+	for i := range txs {
+		ids[i] = txs[i].ID
+		byID[txs[i].ID] = &txs[i]
+	}
+
+	rows, err := db.QueryContext(ctx, splitsByTxIDs, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Split
+		var txID string
+		if err := rows.Scan(&s.ID, &txID, &s.Category, &s.Amount, &s.Note); err != nil {
+			return err
+		}
+		if t, ok := byID[txID]; ok {
+			t.Splits = append(t.Splits, s)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,336 @@
+package transaction
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"unicode"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/go-pdf/fpdf"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+const monthLayout = "2006-01"
+
+// statementFont is a UTF-8 TrueType font embedded in the PDF, core fonts
+// only know cp1252 and would garble names, categories and notes. DejaVu has
+// no Thai, thaiFont draws the runs of Thai text. It only comes in regular,
+// which stands in for bold too.
+const (
+	statementFont = "DejaVuSansCondensed"
+	thaiFont      = "NotoSansThai"
+)
+
+//go:embed fonts/*.ttf
+var fonts embed.FS
+
+// Statement is everything a spender did in one month, ready to be rendered
+// as an XLSX workbook or a PDF document. The PDF writes dates and amounts
+// the way Locale does, the workbook keeps plain values for the spreadsheet
+// to format.
+type Statement struct {
+	Spender      spender.Spender
+	Month        time.Time
+	Locale       string
+	Summary      TransactionSummary
+	Categories   []CategorySummary
+	Transactions []Transaction
+}
+
+// newStatement totals the transactions of the month, split lines count
+// towards their own category and transfers are left out like in summaries.
+func newStatement(sp spender.Spender, month time.Time, txs []Transaction) Statement {
+	s := Statement{Spender: sp, Month: month, Transactions: txs, Categories: []CategorySummary{}}
+
+	var income, expenses int64
+	byKey := map[[2]string]*CategorySummary{}
+	add := func(category, kind string, amount float64) {
+		k := [2]string{category, kind}
+		if byKey[k] == nil {
+			byKey[k] = &CategorySummary{Category: category, TransactionType: kind}
+		}
+		byKey[k].Total = float64(cents(byKey[k].Total)+cents(amount)) / 100
+		byKey[k].Count++
+	}
+
+	for _, t := range txs {
+		switch t.TransactionType {
+		case "income":
+			income += cents(t.Amount)
+		case "expense":
+			expenses += cents(t.Amount)
+		default:
+			continue
+		}
+		if len(t.Splits) == 0 {
+			add(t.Category, t.TransactionType, t.Amount)
+		}
+		for _, sp := range t.Splits {
+			add(sp.Category, t.TransactionType, sp.Amount)
+		}
+	}
+
+	s.Summary = TransactionSummary{
+		TotalIncome:    float64(income) / 100,
+		TotalExpenses:  float64(expenses) / 100,
+		CurrentBalance: float64(income-expenses) / 100,
+	}
+	for _, c := range byKey {
+		s.Categories = append(s.Categories, *c)
+	}
+	sort.Slice(s.Categories, func(i, j int) bool {
+		if s.Categories[i].Total != s.Categories[j].Total {
+			return s.Categories[i].Total > s.Categories[j].Total
+		}
+		return s.Categories[i].Category < s.Categories[j].Category
+	})
+	return s
+}
+
+// XLSX writes a workbook with a Transactions sheet and a Categories sheet.
+func (s Statement) XLSX(w io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const txSheet, catSheet = "Transactions", "Categories"
+	if err := f.SetSheetName("Sheet1", txSheet); err != nil {
+		return err
+	}
+	if _, err := f.NewSheet(catSheet); err != nil {
+		return err
+	}
+
+	rows := [][]any{{"Date", "Type", "Category", "Amount", "Note"}}
+	for _, t := range s.Transactions {
+		rows = append(rows, []any{t.Date, t.TransactionType, t.Category, t.Amount, t.Note})
+	}
+	if err := setRows(f, txSheet, rows); err != nil {
+		return err
+	}
+
+	rows = [][]any{{"Category", "Type", "Total", "Count"}}
+	for _, c := range s.Categories {
+		rows = append(rows, []any{c.Category, c.TransactionType, c.Total, c.Count})
+	}
+	rows = append(rows, []any{},
+		[]any{"Total income", nil, s.Summary.TotalIncome},
+		[]any{"Total expenses", nil, s.Summary.TotalExpenses},
+		[]any{"Balance", nil, s.Summary.CurrentBalance},
+	)
+	if err := setRows(f, catSheet, rows); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+func setRows(f *excelize.File, sheet string, rows [][]any) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PDF writes an A4 statement: spender header, monthly totals and the
+// itemized transactions.
+func (s Statement) PDF(w io.Writer) error {
+	pdf := statementPDF{Fpdf: fpdf.New("P", "mm", "A4", "")}
+	local := newLocaleFormat(s.Locale)
+	for _, f := range []struct{ family, style, file string }{
+		{statementFont, "", statementFont + ".ttf"},
+		{statementFont, "B", statementFont + "-Bold.ttf"},
+		{thaiFont, "", thaiFont + "-Regular.ttf"},
+		{thaiFont, "B", thaiFont + "-Regular.ttf"},
+	} {
+		b, err := fonts.ReadFile("fonts/" + f.file)
+		if err != nil {
+			return err
+		}
+		pdf.AddUTF8FontFromBytes(f.family, f.style, b)
+	}
+	pdf.SetTitle(fmt.Sprintf("Statement %s", s.Month.Format(monthLayout)), true)
+	pdf.AddPage()
+
+	pdf.font("B", 16)
+	pdf.cell(0, 10, "Statement "+s.Month.Format("January 2006"), "", 1, "L")
+	pdf.font("", 11)
+	pdf.cell(0, 6, s.Spender.Name, "", 1, "L")
+	pdf.cell(0, 6, s.Spender.Email, "", 1, "L")
+	pdf.Ln(4)
+
+	for _, line := range []struct {
+		label  string
+		amount float64
+	}{
+		{"Total income", s.Summary.TotalIncome},
+		{"Total expenses", s.Summary.TotalExpenses},
+		{"Balance", s.Summary.CurrentBalance},
+	} {
+		pdf.cell(50, 6, line.label, "", 0, "L")
+		pdf.cell(40, 6, local.money(line.amount), "", 1, "R")
+	}
+	pdf.Ln(4)
+
+	widths := []float64{25, 22, 40, 28, 75}
+	pdf.font("B", 10)
+	for i, h := range []string{"Date", "Type", "Category", "Amount", "Note"} {
+		pdf.cell(widths[i], 7, h, "B", 0, "L")
+	}
+	pdf.Ln(-1)
+
+	pdf.font("", 10)
+	for _, t := range s.Transactions {
+		cells := []string{local.date(t.Date), t.TransactionType, t.Category, local.money(t.Amount), truncate(t.Note, 45)}
+		for i, v := range cells {
+			align := "L"
+			if i == 3 {
+				align = "R"
+			}
+			pdf.cell(widths[i], 6, v, "", 0, align)
+		}
+		pdf.Ln(-1)
+	}
+
+	return pdf.Output(w)
+}
+
+// statementPDF writes its cells in statementFont, switching to thaiFont for
+// the runs of Thai text.
+type statementPDF struct {
+	*fpdf.Fpdf
+	style string
+	size  float64
+}
+
+func (p *statementPDF) font(style string, size float64) {
+	p.style, p.size = style, size
+	p.SetFont(statementFont, style, size)
+}
+
+// cell is CellFormat for ln 0 and 1, a text in more than one font is
+// written run by run inside the cell.
+func (p *statementPDF) cell(w, h float64, txt, border string, ln int, align string) {
+	runs := fontRuns(txt)
+	if len(runs) == 0 || len(runs) == 1 && runs[0].font == statementFont {
+		p.CellFormat(w, h, txt, border, ln, align, false, 0, "")
+		return
+	}
+
+	x, y := p.GetXY()
+	left, _, right, _ := p.GetMargins()
+	if w == 0 {
+		pageWidth, _ := p.GetPageSize()
+		w = pageWidth - right - x
+	}
+	var width float64
+	for _, r := range runs {
+		p.SetFont(r.font, p.style, p.size)
+		width += p.GetStringWidth(r.text)
+	}
+
+	p.CellFormat(w, h, "", border, 0, "", false, 0, "")
+	margin := p.GetCellMargin()
+	if align == "R" {
+		p.SetX(x + w - margin - width)
+	} else {
+		p.SetX(x + margin)
+	}
+	p.SetCellMargin(0)
+	for _, r := range runs {
+		p.SetFont(r.font, p.style, p.size)
+		p.CellFormat(p.GetStringWidth(r.text), h, r.text, "", 0, "L", false, 0, "")
+	}
+	p.SetCellMargin(margin)
+	p.SetFont(statementFont, p.style, p.size)
+
+	if ln == 1 {
+		p.SetXY(left, y+h)
+	} else {
+		p.SetXY(x+w, y)
+	}
+}
+
+// fontRun is a piece of text drawn in one font.
+type fontRun struct {
+	font string
+	text string
+}
+
+// fontRuns splits txt where it goes from Thai to other scripts or back, a
+// space stays in the run before it.
+func fontRuns(txt string) []fontRun {
+	var runs []fontRun
+	for _, r := range txt {
+		font := statementFont
+		if unicode.Is(unicode.Thai, r) {
+			font = thaiFont
+		}
+		if n := len(runs); n > 0 && (runs[n-1].font == font || r == ' ') {
+			runs[n-1].text += string(r)
+			continue
+		}
+		runs = append(runs, fontRun{font, string(r)})
+	}
+	return runs
+}
+
+// localeFormat writes amounts and dates like the spender's locale, the
+// digit grouping and decimal mark come from CLDR.
+type localeFormat struct {
+	printer *message.Printer
+	layout  string
+}
+
+func newLocaleFormat(locale string) localeFormat {
+	tag := language.Make(locale)
+	return localeFormat{message.NewPrinter(tag), localeDateLayout(tag)}
+}
+
+// localeDateLayout is the numeric date of the locale, day first unless the
+// region or language writes it otherwise.
+func localeDateLayout(tag language.Tag) string {
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	switch {
+	case region.String() == "US":
+		return "01/02/2006"
+	case base.String() == "ja", base.String() == "zh", base.String() == "ko":
+		return "2006/01/02"
+	case base.String() == "de":
+		return "02.01.2006"
+	}
+	return "02/01/2006"
+}
+
+func (f localeFormat) money(amount float64) string {
+	return f.printer.Sprint(number.Decimal(amount, number.Scale(2)))
+}
+
+// date rewrites a YYYY-MM-DD day, as it is when it doesn't parse.
+func (f localeFormat) date(day string) string {
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return day
+	}
+	return t.Format(f.layout)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
@@ -0,0 +1,280 @@
+package transaction
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+var may = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func statementFixture() Statement {
+	return newStatement(spender.Spender{ID: 1, Name: "Somchai", Email: "somchai@example.com"}, may, []Transaction{
+		{ID: "1", Date: "2024-05-01", Amount: 30000, Category: "salary", TransactionType: "income"},
+		{ID: "2", Date: "2024-05-02", Amount: 1200, Category: "supermarket", TransactionType: "expense",
+			Splits: []Split{{Category: "food", Amount: 800}, {Category: "household", Amount: 400}}},
+		{ID: "3", Date: "2024-05-03", Amount: 200, Category: "food", TransactionType: "expense", Note: "lunch"},
+		{ID: "4", Date: "2024-05-04", Amount: 5000, Category: "saving", TransactionType: Transfer},
+	})
+}
+
+func TestNewStatement(t *testing.T) {
+	t.Run("totals leave transfers out and count split lines by category", func(t *testing.T) {
+		s := statementFixture()
+
+		assert.Equal(t, TransactionSummary{TotalIncome: 30000, TotalExpenses: 1400, CurrentBalance: 28600}, s.Summary)
+		assert.Equal(t, []CategorySummary{
+			{Category: "salary", TransactionType: "income", Total: 30000, Count: 1},
+			{Category: "food", TransactionType: "expense", Total: 1000, Count: 2},
+			{Category: "household", TransactionType: "expense", Total: 400, Count: 1},
+		}, s.Categories)
+	})
+}
+
+func TestStatementXLSX(t *testing.T) {
+	t.Run("workbook has transactions and categories sheets", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := statementFixture().XLSX(&buf)
+		assert.NoError(t, err)
+
+		f, err := excelize.OpenReader(&buf)
+		assert.NoError(t, err)
+		defer f.Close()
+
+		assert.Equal(t, []string{"Transactions", "Categories"}, f.GetSheetList())
+		rows, _ := f.GetRows("Transactions")
+		assert.Len(t, rows, 5)
+		assert.Equal(t, []string{"2024-05-03", "expense", "food", "200", "lunch"}, rows[3])
+		total, _ := f.GetCellValue("Categories", "C6")
+		assert.Equal(t, "30000", total)
+	})
+}
+
+func TestStatementPDF(t *testing.T) {
+	t.Run("renders a pdf document", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := statementFixture().PDF(&buf)
+
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("renders thai text with the embedded font", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := newStatement(spender.Spender{ID: 2, Name: "สมชาย ใจดี", Email: "somchai@example.com"}, may, []Transaction{
+			{ID: "1", Date: "2024-05-03", Amount: 200, Category: "อาหาร", TransactionType: "expense", Note: "ข้าวมันไก่ร้านป้าแดง"},
+		})
+
+		err := s.PDF(&buf)
+
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "/BaseFont /utf8"+strings.ToLower(thaiFont))
+		drawn := drawnText(t, buf.Bytes())
+		assert.Contains(t, drawn, "สมชาย ใจดี")
+		assert.Contains(t, drawn, "ข้าวมันไก่ร้านป้าแดง")
+		assert.Empty(t, missingGlyphs(t, buf.Bytes()))
+	})
+
+	t.Run("writes dates and amounts the way the locale does", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := statementFixture()
+		s.Locale = "de-DE"
+
+		err := s.PDF(&buf)
+
+		assert.NoError(t, err)
+		drawn := drawnText(t, buf.Bytes())
+		assert.Contains(t, drawn, "01.05.2024")
+		assert.Contains(t, drawn, "30.000,00")
+		assert.Contains(t, drawn, "28.600,00")
+	})
+}
+
+func TestLocaleFormat(t *testing.T) {
+	for locale, want := range map[string][2]string{
+		"th-TH": {"03/05/2024", "1,250.50"},
+		"en-US": {"05/03/2024", "1,250.50"},
+		"ja-JP": {"2024/05/03", "1,250.50"},
+		"de-DE": {"03.05.2024", "1.250,50"},
+	} {
+		f := newLocaleFormat(locale)
+
+		assert.Equal(t, want, [2]string{f.date("2024-05-03"), f.money(1250.5)}, locale)
+	}
+}
+
+func TestFontRuns(t *testing.T) {
+	assert.Equal(t, []fontRun{{statementFont, "7-ELEVEN "}, {thaiFont, "ข้าวมันไก่ "}, {statementFont, "45.00"}}, fontRuns("7-ELEVEN ข้าวมันไก่ 45.00"))
+	assert.Equal(t, []fontRun{{statementFont, "lunch"}}, fontRuns("lunch"))
+}
+
+var (
+	pdfObject   = regexp.MustCompile(`(?s)(\d+) 0 obj\n(.*?)endobj`)
+	pdfFontRes  = regexp.MustCompile(`/F(\w+) (\d+) 0 R`)
+	pdfText     = regexp.MustCompile(`BT /F(\w+) [\d.]+ Tf ET|Td \(((?:\\.|[^\\)])*)\)Tj`)
+	pdfUnescape = strings.NewReplacer(`\\`, `\`, `\(`, `(`, `\)`, `)`, `\r`, "\r")
+)
+
+// pdfObjects is every object of doc by number with its stream inflated.
+func pdfObjects(t *testing.T, doc []byte) map[string][2]string {
+	objects := map[string][2]string{}
+	for _, m := range pdfObject.FindAllSubmatch(doc, -1) {
+		dict, stream, _ := bytes.Cut(m[2], []byte("stream\n"))
+		stream, _, _ = bytes.Cut(stream, []byte("\nendstream"))
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			assert.NoError(t, err)
+			stream, err = io.ReadAll(r)
+			assert.NoError(t, err)
+		}
+		objects[string(m[1])] = [2]string{string(dict), string(stream)}
+	}
+	return objects
+}
+
+// textRun is text drawn with the font resource /F<font>.
+type textRun struct {
+	font string
+	text string
+}
+
+// pdfTextRuns is the text the pages of doc draw, run by run.
+func pdfTextRuns(t *testing.T, doc []byte) []textRun {
+	var runs []textRun
+	for _, obj := range pdfObjects(t, doc) {
+		var font string
+		for _, m := range pdfText.FindAllStringSubmatch(obj[1], -1) {
+			if m[1] != "" {
+				font = m[1]
+				continue
+			}
+			utf16be := []byte(pdfUnescape.Replace(m[2]))
+			units := make([]uint16, len(utf16be)/2)
+			for i := range units {
+				units[i] = uint16(utf16be[2*i])<<8 | uint16(utf16be[2*i+1])
+			}
+			runs = append(runs, textRun{font, string(utf16.Decode(units))})
+		}
+	}
+	return runs
+}
+
+// pdfRef matches the object number key refers to.
+func pdfRef(key string) *regexp.Regexp {
+	return regexp.MustCompile(key + ` \[?(\d+) 0 R`)
+}
+
+// drawnText is all the text doc draws, run after run.
+func drawnText(t *testing.T, doc []byte) string {
+	var b strings.Builder
+	for _, r := range pdfTextRuns(t, doc) {
+		b.WriteString(r.text)
+	}
+	return b.String()
+}
+
+// missingGlyphs lists the runes doc draws with a font that has no glyph for
+// them, as told by the CIDToGIDMap of the font.
+func missingGlyphs(t *testing.T, doc []byte) []string {
+	objects := pdfObjects(t, doc)
+	glyphs := map[string]string{}
+	for _, obj := range objects {
+		if !strings.Contains(obj[0], "/Font <<") {
+			continue
+		}
+		for _, m := range pdfFontRes.FindAllStringSubmatch(obj[0], -1) {
+			cid := pdfRef("/DescendantFonts").FindStringSubmatch(objects[m[2]][0])
+			if cid == nil {
+				continue
+			}
+			gids := pdfRef("/CIDToGIDMap").FindStringSubmatch(objects[cid[1]][0])
+			glyphs[m[1]] = objects[gids[1]][1]
+		}
+	}
+
+	var missing []string
+	for _, r := range pdfTextRuns(t, doc) {
+		for _, c := range r.text {
+			if c == ' ' {
+				continue
+			}
+			gids := glyphs[r.font]
+			if int(c)*2+1 >= len(gids) || gids[2*c] == 0 && gids[2*c+1] == 0 {
+				missing = append(missing, string(c))
+			}
+		}
+	}
+	return missing
+}
+
+func TestStatementHandler(t *testing.T) {
+	t.Run("download monthly statement as pdf", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?month=2024-05&format=pdf", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(statementSpenderStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(1, "Somchai", "somchai@example.com"))
+		// paid on the 25th in Bangkok, May runs from 25 May to 24 June
+		bangkok, _ := time.LoadLocation("Asia/Bangkok")
+		bangkokPayMonth := time.Date(2024, 5, 25, 0, 0, 0, 0, bangkok)
+		mock.ExpectQuery(`SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"timezone", "currency", "locale", "week_start", "month_start_day"}).AddRow("Asia/Bangkok", "THB", "th-TH", "monday", 25))
+		mock.ExpectQuery(statementTxStmt).WithArgs(1, bangkokPayMonth, bangkokPayMonth.AddDate(0, 1, 0), "Asia/Bangkok").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url"}).
+				AddRow("3", "2024-05-03", 200.0, "food", "expense", 1, "lunch", ""))
+		mock.ExpectQuery(splitsByTxIDs).WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "category", "amount", "note"}))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Statement(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="statement-1-2024-05.pdf"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject unknown format", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/?month=2024-05&format=docx", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Statement(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -1,12 +1,15 @@
 package transaction
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/merchant"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/pagination"
 	"github.com/labstack/echo/v4"
 )
 
@@ -20,6 +23,13 @@ type Transaction struct {
 	SpenderID       int     `json:"spender_id"`
 	Note            string  `json:"note"`
 	ImageURL        string  `json:"image_url"`
+	WalletID        *int    `json:"wallet_id,omitempty"`
+	AccountID       *int    `json:"account_id,omitempty"`
+	ToAccountID     *int    `json:"to_account_id,omitempty"`
+	Splits          []Split `json:"splits,omitempty"`
+	Shares          []Share `json:"shares,omitempty"`
+	CreatedAt       string  `json:"created_at,omitempty"`
+	Merchant        string  `json:"merchant,omitempty"`
 }
 
 // ResponseData includes transactions array, summary, and pagination details.
@@ -29,18 +39,29 @@ type ResponseData struct {
 	Pagination   PaginationInfo     `json:"pagination"`
 }
 
+// TransactionSummary is all time unless it names the Period it covers. A
+// period only adds up the transactions in the spender's home Currency.
 type TransactionSummary struct {
 	TotalIncome    float64 `json:"total_income"`
 	TotalExpenses  float64 `json:"total_expenses"`
 	CurrentBalance float64 `json:"current_balance"`
+	Period         *Period `json:"period,omitempty"`
+	Currency       string  `json:"currency,omitempty"`
 }
 
-type PaginationInfo struct {
-	CurrentPage int `json:"current_page"`
-	TotalPages  int `json:"total_pages"`
-	PerPage     int `json:"per_page"`
+// CategorySummary is the total of one category, split lines count towards
+// their own category rather than the parent transaction's.
+type CategorySummary struct {
+	Category        string  `json:"category"`
+	TransactionType string  `json:"transaction_type"`
+	Total           float64 `json:"total"`
+	Count           int     `json:"count"`
 }
 
+// PaginationInfo describes either an OFFSET page (current_page) or a keyset
+// page (next_cursor/prev_cursor).
+type PaginationInfo = pagination.Info
+
 type TransactionWithDetail struct {
 	Transactions []Transaction      `json:"transactions"`
 	Summary      TransactionSummary `json:"summary"`
@@ -48,19 +69,112 @@ type TransactionWithDetail struct {
 }
 
 type TransactionReqBody struct {
-	Date            string  `json:"date"`
-	Amount          float64 `json:"amount"`
-	Category        string  `json:"category"`
-	TransactionType string  `json:"transaction_type"`
-	SpenderID       int     `json:"spender_id"`
-	Note            string  `json:"note"`
-	ImageURL        string  `json:"image_url"`
+	Date            string        `json:"date"`
+	Amount          float64       `json:"amount"`
+	Category        string        `json:"category"`
+	TransactionType string        `json:"transaction_type"`
+	SpenderID       int           `json:"spender_id"`
+	Note            string        `json:"note"`
+	ImageURL        string        `json:"image_url"`
+	WalletID        *int          `json:"wallet_id"`
+	AccountID       *int          `json:"account_id"`
+	ToAccountID     *int          `json:"to_account_id"`
+	Splits          []Split       `json:"splits"`
+	Sharing         *ShareRequest `json:"sharing"`
+	Merchant        string        `json:"merchant,omitempty"`
+
+	// filled in from the merchant by its rules
+	merchantID *int64
+	ruleTags   []string
+	// a reverted version keeps the merchant it had, see keepMerchant
+	restored bool
+}
+
+// validate checks the body and resolves how the bill is shared.
+func (b TransactionReqBody) validate() ([]Share, error) {
+	if err := validateTransfer(b); err != nil {
+		return nil, err
+	}
+	if err := validateSplits(b.Amount, b.Splits); err != nil {
+		return nil, err
+	}
+	return b.Sharing.resolve(b.Amount, b.TransactionType)
+}
+
+// args are the column values of cStmt and uStmt, in order.
+func (b TransactionReqBody) args() []any {
+	return []any{b.Date, b.Amount, b.Category, b.TransactionType, b.SpenderID, b.Note, b.ImageURL, b.WalletID, b.AccountID, b.ToAccountID, b.Merchant, b.merchantID}
+}
+
+func (b TransactionReqBody) transaction(id string) Transaction {
+	return Transaction{
+		ID:              id,
+		Date:            b.Date,
+		Amount:          b.Amount,
+		Category:        b.Category,
+		TransactionType: b.TransactionType,
+		SpenderID:       b.SpenderID,
+		Note:            b.Note,
+		ImageURL:        b.ImageURL,
+		WalletID:        b.WalletID,
+		AccountID:       b.AccountID,
+		ToAccountID:     b.ToAccountID,
+		Splits:          b.Splits,
+		Merchant:        b.Merchant,
+	}
+}
+
+// categorize looks up the merchant and lets the spender's rules fill in
+// the category and tags. A merchant seen for the first time is left without
+// an id, ensureMerchant adds it in the write.
+func (b *TransactionReqBody) categorize(ctx context.Context, q merchant.Querier) error {
+	if b.Merchant == "" {
+		return nil
+	}
+	cat, err := merchant.NewCategorizer(ctx, q, int64(b.SpenderID))
+	if err != nil {
+		return err
+	}
+	res, err := cat.Categorize(ctx, b.Merchant, b.Category, false)
+	if err != nil {
+		return err
+	}
+	b.Merchant, b.merchantID, b.Category, b.ruleTags = res.Merchant, res.MerchantID, res.Category, res.Tags
+	return nil
+}
+
+// ensureMerchant adds the merchant categorize did not find inside tx, so a
+// rejected or rolled back write leaves no merchant behind.
+func (b *TransactionReqBody) ensureMerchant(ctx context.Context, tx *sql.Tx) error {
+	if b.restored {
+		return b.keepMerchant(ctx, tx)
+	}
+	if b.Merchant == "" || b.merchantID != nil {
+		return nil
+	}
+	id, err := merchant.Ensure(ctx, tx, b.Merchant)
+	if err != nil {
+		return err
+	}
+	b.merchantID = &id
+	return nil
 }
 
 // For pre-commit
 // GetTransactionsHandler returns a handler function to fetch transactions with optional pagination and filtering.
 func GetTransactionsHandler(db *sql.DB) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		sort, err := parseSort(c.QueryParam("sort"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
+		// A cursor parameter switches to keyset pagination
+		keyset, isKeyset, err := parsePage(c, sort)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
 		// Parse and validate pagination parameters
 		page, _ := strconv.Atoi(c.QueryParam("page"))
 		if page <= 0 {
@@ -72,48 +186,38 @@ func GetTransactionsHandler(db *sql.DB) echo.HandlerFunc {
 		}
 
 		// Fetch and validate filter parameters
-		date := c.QueryParam("date")
-		amount := c.QueryParam("amount")
-		category := c.QueryParam("category")
-		transactionType := c.QueryParam("transaction_type")
+		filter, err := parseFilter(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
 
 		// SQL query construction with filters
-		query := `SELECT id, date, amount, category, transaction_type, spender_id, note, image_url FROM "transaction"`
-		whereClauses := []string{"TRUE"}
-		if date != "" {
-			whereClauses = append(whereClauses, fmt.Sprintf("DATE(date) = '%s'", date))
-		}
-		if amount != "" {
-			if _, err := strconv.ParseFloat(amount, 64); err == nil {
-				whereClauses = append(whereClauses, fmt.Sprintf("amount = %s", amount))
-			} else {
-				return echo.NewHTTPError(http.StatusBadRequest, "Invalid amount format")
-			}
-		}
-		if category != "" {
-			whereClauses = append(whereClauses, fmt.Sprintf("category = '%s'", category))
-		}
-		if transactionType != "" {
-			whereClauses = append(whereClauses, fmt.Sprintf("transaction_type = '%s'", transactionType))
-		}
+		query := `SELECT ` + listColumns + ` FROM "transaction"`
+		whereClauses, args := filter.clauses([]string{"deleted_at IS NULL"}, nil)
 
 		// Execute the filtered query
-		filteredQuery := fmt.Sprintf("%s WHERE %s ORDER BY id LIMIT %d OFFSET %d", query, strings.Join(whereClauses, " AND "), limit, (page-1)*limit)
-		rows, err := db.Query(filteredQuery)
+		filteredQuery := fmt.Sprintf("%s WHERE %s ORDER BY %s LIMIT %d OFFSET %d", query, strings.Join(whereClauses, " AND "), sort.orderBy(false), limit, (page-1)*limit)
+		queryArgs := args
+		if isKeyset {
+			var keysetClauses []string
+			var tail string
+			keysetClauses, queryArgs, tail = keyset.clauses(whereClauses, args)
+			filteredQuery = fmt.Sprintf("%s WHERE %s %s", query, strings.Join(keysetClauses, " AND "), tail)
+		}
+		rows, err := db.Query(filteredQuery, queryArgs...)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to fetch transactions: %s", err.Error()))
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch transactions").SetInternal(err)
 		}
 		defer rows.Close()
 
 		// Process query results
 		var transactions []Transaction
-		var totalIncome, totalExpenses float64
 		for rows.Next() {
 			var t Transaction
 			// var date sql.NullTime
 			var amount sql.NullFloat64
-			if err := rows.Scan(&t.ID, &t.Date, &amount, &t.Category, &t.TransactionType, &t.SpenderID, &t.Note, &t.ImageURL); err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error scanning transaction: %s", err.Error()))
+			if err := rows.Scan(&t.ID, &t.Date, &amount, &t.Category, &t.TransactionType, &t.SpenderID, &t.Note, &t.ImageURL, &t.CreatedAt); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error scanning transaction").SetInternal(err)
 			}
 
 			// Populate valid data fields
@@ -122,7 +226,21 @@ func GetTransactionsHandler(db *sql.DB) echo.HandlerFunc {
 			}
 
 			transactions = append(transactions, t)
-			// Update income and expenses based on transaction type
+		}
+
+		// Handle post-query errors
+		if err = rows.Err(); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error fetching transactions").SetInternal(err)
+		}
+
+		pagination := PaginationInfo{CurrentPage: page, PerPage: limit}
+		if isKeyset {
+			transactions, pagination = keyset.paginate(transactions)
+		}
+
+		// Update income and expenses based on transaction type
+		var totalIncome, totalExpenses float64
+		for _, t := range transactions {
 			if strings.ToLower(t.TransactionType) == "income" {
 				totalIncome += t.Amount
 			} else if strings.ToLower(t.TransactionType) == "expense" {
@@ -130,18 +248,19 @@ func GetTransactionsHandler(db *sql.DB) echo.HandlerFunc {
 			}
 		}
 
-		// Handle post-query errors
-		if err = rows.Err(); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error fetching transactions: %s", err.Error()))
-		}
-
-		// Calculate total pages for pagination
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM \"transaction\" WHERE %s", strings.Join(whereClauses, " AND "))
-		var totalRecords int
-		if err = db.QueryRow(countQuery).Scan(&totalRecords); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to count transactions: %s", err.Error()))
+		// Calculate total pages for pagination, keyset pages count only when asked
+		if !isKeyset || keyset.Total {
+			countQuery := fmt.Sprintf("SELECT COUNT(*) FROM \"transaction\" WHERE %s", strings.Join(whereClauses, " AND "))
+			var totalRecords int
+			if err = db.QueryRow(countQuery, args...).Scan(&totalRecords); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count transactions").SetInternal(err)
+			}
+			if isKeyset {
+				pagination = pagination.WithTotal(totalRecords)
+			} else {
+				pagination.TotalPages = (totalRecords + limit - 1) / limit
+			}
 		}
-		totalPages := (totalRecords + limit - 1) / limit
 
 		// Prepare and return the API response
 		response := ResponseData{
@@ -151,11 +270,7 @@ func GetTransactionsHandler(db *sql.DB) echo.HandlerFunc {
 				TotalExpenses:  totalExpenses,
 				CurrentBalance: totalIncome - totalExpenses,
 			},
-			Pagination: PaginationInfo{
-				CurrentPage: page,
-				TotalPages:  totalPages,
-				PerPage:     limit,
-			},
+			Pagination: pagination,
 		}
 
 		return c.JSON(http.StatusOK, response)
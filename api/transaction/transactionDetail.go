@@ -3,12 +3,17 @@ package transaction
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
@@ -19,8 +24,11 @@ type handler struct {
 }
 
 type TxDetailStorer interface {
-	GetTransactionDetailBySpenderId(ctx context.Context, id string, offset int, limit int) (TransactionWithDetail, error)
-	GetTransactionSummaryBySpenderId(ctx context.Context, id string) (TransactionSummary, error)
+	GetTransactionDetailBySpenderId(ctx context.Context, id string, filter Filter, offset int, limit int, sort Sort) (TransactionWithDetail, error)
+	GetTransactionPageBySpenderId(ctx context.Context, id string, filter Filter, page Page) (TransactionWithDetail, error)
+	GetTransactionSummaryBySpenderId(ctx context.Context, id string, period Period) (TransactionSummary, error)
+	GetCategorySummaryBySpenderId(ctx context.Context, id string, period Period) ([]CategorySummary, error)
+	GetPreferences(ctx context.Context, id string) (spender.Preferences, error)
 }
 
 func New(cfg config.FeatureFlag, storer TxDetailStorer) *handler {
@@ -64,6 +72,12 @@ func New(cfg config.FeatureFlag, storer TxDetailStorer) *handler {
 		"per_page": 10
 	}
 }
+
+Newest first unless ?sort=-date,amount asks otherwise, see Sort. Takes
+the same filters as GET /transactions, ?tags=food,trip&tags_match=all too.
+With ?cursor= (empty for the first page) the listing pages by keyset
+instead, pagination then has next_cursor/prev_cursor and, with
+include_total=true, total_count.
 */
 
 func (h handler) GetTransactionDetailBySpenderIdHandler(c echo.Context) error {
@@ -73,6 +87,29 @@ func (h handler) GetTransactionDetailBySpenderIdHandler(c echo.Context) error {
 
 	id := c.Param("id")
 
+	sort, err := parseSort(c.QueryParam("sort"))
+	if err != nil {
+		logger.Error("bad request", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+
+	filter, err := parseFilter(c)
+	if err != nil {
+		logger.Error("bad request", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+
+	keyset, ok, err := parsePage(c, sort)
+	if err != nil {
+		logger.Error("bad request", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if ok {
+		return h.respond(c, id, func() (TransactionWithDetail, error) {
+			return h.storer.GetTransactionPageBySpenderId(ctx, id, filter, keyset)
+		})
+	}
+
 	//Get page number
 	rawPage := c.QueryParam("page")
 	page := 1
@@ -81,7 +118,7 @@ func (h handler) GetTransactionDetailBySpenderIdHandler(c echo.Context) error {
 		page, err = strconv.Atoi(rawPage)
 		if err != nil {
 			logger.Error("bad request", zap.Error(err))
-			return c.JSON(http.StatusBadRequest, "Please check your page number")
+			return apierror.Respond(c, http.StatusBadRequest, "Please check your page number")
 		}
 	}
 
@@ -93,38 +130,70 @@ func (h handler) GetTransactionDetailBySpenderIdHandler(c echo.Context) error {
 		limit, err = strconv.Atoi(rawLimit)
 		if err != nil {
 			logger.Error("bad request", zap.Error(err))
-			return c.JSON(http.StatusBadRequest, "Please check your page limit")
+			return apierror.Respond(c, http.StatusBadRequest, "Please check your page limit")
 		}
 	}
 
-	txDetail, err := h.storer.GetTransactionDetailBySpenderId(ctx, id, page, limit)
+	return h.respond(c, id, func() (TransactionWithDetail, error) {
+		return h.storer.GetTransactionDetailBySpenderId(ctx, id, filter, page, limit, sort)
+	})
+}
+
+// respond sends the page fetched by list along with the spender's all time
+// summary in their home currency.
+func (h handler) respond(c echo.Context, id string, list func() (TransactionWithDetail, error)) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	txDetail, err := list()
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	prefs, err := h.storer.GetPreferences(ctx, id)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, "Please check server logs")
+		return apierror.Internal(c)
 	}
 
-	txSum, errTxSum := h.storer.GetTransactionSummaryBySpenderId(ctx, id)
+	txSum, errTxSum := h.storer.GetTransactionSummaryBySpenderId(ctx, id, allTime(prefs.Currency))
 	if errTxSum != nil {
-		logger.Error("query error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, "Please check server logs")
+		logger.Error("query error", zap.Error(errTxSum))
+		return apierror.Internal(c)
 	}
+	txSum.Currency = prefs.Currency
 	txDetail.Summary = txSum
 	return c.JSON(http.StatusOK, txDetail)
-
 }
 
 type Postgres struct {
 	Db *sql.DB
 }
 
-func (p *Postgres) GetTransactionDetailBySpenderId(ctx context.Context, id string, page int, limit int) (TransactionWithDetail, error) {
+const (
+	listColumns = `id, date, amount, category, transaction_type, spender_id, note, image_url, created_at`
+	listStmt    = `SELECT ` + listColumns + ` FROM transaction WHERE %s ORDER BY %s OFFSET $%d LIMIT $%d`
+	pageStmt    = `SELECT ` + listColumns + ` FROM transaction WHERE %s %s`
+	countStmt   = `SELECT COUNT(*) FROM transaction WHERE %s`
+)
+
+const (
+	summaryStmt         = `SELECT COALESCE(SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE 0 END), 0) AS total_income, COALESCE(SUM(CASE WHEN transaction_type = 'expense' THEN amount ELSE 0 END), 0) AS total_expenses, COALESCE(SUM(CASE transaction_type WHEN 'income' THEN amount WHEN 'expense' THEN -amount ELSE 0 END), 0) AS current_balance FROM transaction WHERE %s = $1 AND deleted_at IS NULL%s`
+	categorySummaryStmt = `SELECT COALESCE(s.category, t.category) AS category, t.transaction_type, SUM(COALESCE(s.amount, t.amount)) AS total, COUNT(*) AS count FROM transaction t LEFT JOIN transaction_split s ON s.transaction_id = t.id WHERE t.%s = $1 AND t.deleted_at IS NULL AND t.transaction_type <> 'transfer'%s GROUP BY 1, 2 ORDER BY total DESC`
+)
+
+func (p *Postgres) GetTransactionDetailBySpenderId(ctx context.Context, id string, filter Filter, page int, limit int, sort Sort) (TransactionWithDetail, error) {
 
 	//Query
 	//SELECT * FROM transaction WHERE spender_id = id
+	where, args := filter.clauses([]string{"spender_id = $1", "deleted_at IS NULL"}, []any{id})
+	cond := strings.Join(where, " AND ")
 	skip := (page - 1) * limit
 	//OFFSET 0
 	//LIMIT 10
-	rows, err := p.Db.QueryContext(ctx, `SELECT id,date,amount,category, transaction_type,spender_id, note, image_url FROM transaction WHERE spender_id = $1 OFFSET $2 LIMIT $3`, id, skip, limit)
+	query := fmt.Sprintf(listStmt, cond, sort.orderBy(false), len(args)+1, len(args)+2)
+	rows, err := p.Db.QueryContext(ctx, query, append(args, skip, limit)...)
 	if err != nil {
 
 		return TransactionWithDetail{}, err
@@ -134,17 +203,20 @@ func (p *Postgres) GetTransactionDetailBySpenderId(ctx context.Context, id strin
 	var txs []Transaction
 	for rows.Next() {
 		var tx Transaction
-		err := rows.Scan(&tx.ID, &tx.Date, &tx.Amount, &tx.Category, &tx.TransactionType, &tx.SpenderID, &tx.Note, &tx.ImageURL)
+		err := rows.Scan(&tx.ID, &tx.Date, &tx.Amount, &tx.Category, &tx.TransactionType, &tx.SpenderID, &tx.Note, &tx.ImageURL, &tx.CreatedAt)
 		if err != nil {
 			return TransactionWithDetail{}, err
 		}
 		txs = append(txs, tx)
 	}
 
+	if err := attachSplits(ctx, p.Db, txs); err != nil {
+		return TransactionWithDetail{}, err
+	}
+
 	//Count total pages
 	var total int
-	errCountTx := p.Db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transaction WHERE spender_id = $1`, id).Scan(&total)
-	if errCountTx != nil {
+	if err := p.Db.QueryRowContext(ctx, fmt.Sprintf(countStmt, cond), args...).Scan(&total); err != nil {
 		return TransactionWithDetail{}, err
 	}
 
@@ -153,12 +225,71 @@ func (p *Postgres) GetTransactionDetailBySpenderId(ctx context.Context, id strin
 	return TransactionWithDetail{
 		Transactions: txs,
 		Summary:      TransactionSummary{},
-		Pagination:   PaginationInfo{page, totalPages, limit},
+		Pagination:   PaginationInfo{CurrentPage: page, TotalPages: totalPages, PerPage: limit},
 	}, nil
 }
 
+// GetTransactionPageBySpenderId is the keyset version of
+// GetTransactionDetailBySpenderId, it only counts rows when page.Total is set.
+func (p *Postgres) GetTransactionPageBySpenderId(ctx context.Context, id string, filter Filter, page Page) (TransactionWithDetail, error) {
+	filtered, filterArgs := filter.clauses([]string{"spender_id = $1", "deleted_at IS NULL"}, []any{id})
+	where, args, tail := page.clauses(filtered, filterArgs)
+	rows, err := p.Db.QueryContext(ctx, fmt.Sprintf(pageStmt, strings.Join(where, " AND "), tail), args...)
+	if err != nil {
+		return TransactionWithDetail{}, err
+	}
+	defer rows.Close()
+
+	txs := []Transaction{}
+	for rows.Next() {
+		var tx Transaction
+		if err := rows.Scan(&tx.ID, &tx.Date, &tx.Amount, &tx.Category, &tx.TransactionType, &tx.SpenderID, &tx.Note, &tx.ImageURL, &tx.CreatedAt); err != nil {
+			return TransactionWithDetail{}, err
+		}
+		txs = append(txs, tx)
+	}
+	if err := rows.Err(); err != nil {
+		return TransactionWithDetail{}, err
+	}
+
+	txs, info := page.paginate(txs)
+	if err := attachSplits(ctx, p.Db, txs); err != nil {
+		return TransactionWithDetail{}, err
+	}
+
+	if page.Total {
+		var total int
+		if err := p.Db.QueryRowContext(ctx, fmt.Sprintf(countStmt, strings.Join(filtered, " AND ")), filterArgs...).Scan(&total); err != nil {
+			return TransactionWithDetail{}, err
+		}
+		info = info.WithTotal(total)
+	}
+
+	return TransactionWithDetail{Transactions: txs, Pagination: info}, nil
+}
+
+// period reads the period of a summary request in the spender's own
+// calendar and home currency.
+func (h handler) period(c echo.Context, id string) (Period, spender.Preferences, *echo.HTTPError) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return Period{}, spender.Preferences{}, echo.NewHTTPError(http.StatusBadRequest, "invalid spender id")
+	}
+	prefs, err := h.storer.GetPreferences(c.Request().Context(), id)
+	if err != nil {
+		return Period{}, prefs, echo.NewHTTPError(http.StatusInternalServerError, "Please check server logs").SetInternal(err)
+	}
+	period, err := parsePeriod(c, prefs, time.Now())
+	if err != nil {
+		return Period{}, prefs, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	return period, prefs, nil
+}
+
 // =========================================================
-// GET /api/v1/spenders/{id}/transactions/summary
+// GET /api/v1/spenders/{id}/transactions/summary?period=month&at=2024-05-10
+// period is day, week, month or year as the spender's preferences define
+// them, at picks the period and defaults to today. No period is all time.
+// Either way the accounts in other currencies than the home one are left out.
 func (h handler) GetTransactionSummaryBySpenderIdHandler(c echo.Context) error {
 
 	logger := mlog.L(c)
@@ -166,20 +297,51 @@ func (h handler) GetTransactionSummaryBySpenderIdHandler(c echo.Context) error {
 
 	id := c.Param("id")
 
-	txSummary, err := h.storer.GetTransactionSummaryBySpenderId(ctx, id)
+	period, prefs, herr := h.period(c, id)
+	if herr != nil {
+		if herr.Internal != nil {
+			logger.Error("query error", zap.Error(herr.Internal))
+		}
+		return apierror.Write(c, herr)
+	}
+
+	txSummary, err := h.storer.GetTransactionSummaryBySpenderId(ctx, id, period)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, "Please check server logs")
+		return apierror.Internal(c)
 	}
+	if period.Kind != "" {
+		txSummary.Period = &period
+	}
+	txSummary.Currency = prefs.Currency
 
 	return c.JSON(http.StatusOK, txSummary)
 }
 
-func (p *Postgres) GetTransactionSummaryBySpenderId(ctx context.Context, id string) (TransactionSummary, error) {
+func (p *Postgres) GetTransactionSummaryBySpenderId(ctx context.Context, id string, period Period) (TransactionSummary, error) {
+	return p.summary(ctx, "spender_id", id, period)
+}
 
-	//Query
-	//SELECT * FROM transaction WHERE spender_id = id
-	rows, err := p.Db.QueryContext(ctx, `SELECT SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE 0 END) AS total_income, SUM(CASE WHEN transaction_type = 'expense' THEN amount ELSE 0 END) AS total_expenses, SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE -amount END) AS current_balance FROM transaction WHERE spender_id = $1`, id)
+// GetTransactionSummaryByWalletId is the combined summary of every spender
+// recording into a shared wallet, counting the accounts in currency only.
+func (p *Postgres) GetTransactionSummaryByWalletId(ctx context.Context, id string, currency string) (TransactionSummary, error) {
+	return p.summary(ctx, "wallet_id", id, allTime(currency))
+}
+
+func (p *Postgres) GetPreferences(ctx context.Context, id string) (spender.Preferences, error) {
+	spenderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return spender.Preferences{}, err
+	}
+	return spender.LoadPreferences(ctx, p.Db, spenderID)
+}
+
+// summary totals the transactions whose scope column (spender_id or
+// wallet_id) equals id.
+func (p *Postgres) summary(ctx context.Context, scope string, id string, period Period) (TransactionSummary, error) {
+
+	cond, args := period.clause("", []any{id})
+	rows, err := p.Db.QueryContext(ctx, fmt.Sprintf(summaryStmt, scope, cond), args...)
 	if err != nil {
 		return TransactionSummary{}, err
 	}
@@ -201,3 +363,61 @@ func (p *Postgres) GetTransactionSummaryBySpenderId(ctx context.Context, id stri
 		CurrentBalance: totalIncome - totalExpenses,
 	}, nil
 }
+
+// =========================================================
+// GET /api/v1/spenders/{id}/transactions/summary/categories?period=week
+// Takes the same period as the summary.
+func (h handler) GetCategorySummaryBySpenderIdHandler(c echo.Context) error {
+
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+
+	period, _, herr := h.period(c, id)
+	if herr != nil {
+		if herr.Internal != nil {
+			logger.Error("query error", zap.Error(herr.Internal))
+		}
+		return apierror.Write(c, herr)
+	}
+
+	categories, err := h.storer.GetCategorySummaryBySpenderId(ctx, id, period)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, categories)
+}
+
+// GetCategorySummaryBySpenderId totals amounts per category. A transaction
+// with split lines is counted under each line's category instead of its own.
+func (p *Postgres) GetCategorySummaryBySpenderId(ctx context.Context, id string, period Period) ([]CategorySummary, error) {
+	return p.categorySummary(ctx, "spender_id", id, period)
+}
+
+func (p *Postgres) GetCategorySummaryByWalletId(ctx context.Context, id string, currency string) ([]CategorySummary, error) {
+	return p.categorySummary(ctx, "wallet_id", id, allTime(currency))
+}
+
+func (p *Postgres) categorySummary(ctx context.Context, scope string, id string, period Period) ([]CategorySummary, error) {
+
+	cond, args := period.clause("t.", []any{id})
+	rows, err := p.Db.QueryContext(ctx, fmt.Sprintf(categorySummaryStmt, scope, cond), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []CategorySummary{}
+	for rows.Next() {
+		var cs CategorySummary
+		if err := rows.Scan(&cs.Category, &cs.TransactionType, &cs.Total, &cs.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, cs)
+	}
+
+	return categories, rows.Err()
+}
@@ -2,13 +2,17 @@ package transaction
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,7 +62,8 @@ func TestGetTransactionDetailBySpenderId(t *testing.T) {
 			"summary": {
 				"total_income": 2000,
 				"total_expenses": 1000,
-				"current_balance": 1000
+				"current_balance": 1000,
+				"currency": "THB"
 			},
 			"pagination": {
 				"current_page": 1,
@@ -92,7 +97,7 @@ func TestGetTransactionDetailBySpenderId(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.JSONEq(t, `"Please check your page number"`, rec.Body.String())
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "Please check your page number"}`, rec.Body.String())
 	})
 
 	t.Run("get transaction detail by spender id and invalid limit", func(t *testing.T) {
@@ -117,7 +122,7 @@ func TestGetTransactionDetailBySpenderId(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.JSONEq(t, `"Please check your page limit"`, rec.Body.String())
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "Please check your page limit"}`, rec.Body.String())
 	})
 }
 
@@ -190,28 +195,54 @@ func TestGetTransactionSummaryBySpenderId(t *testing.T) {
 		assert.JSONEq(t, `{
 			"total_income": 2000,
 			"total_expenses": 1000,
-			"current_balance": 1000
+			"current_balance": 1000,
+			"currency": "THB"
 		}`, rec.Body.String())
 
 	})
 }
 
 type StubTxDetailStorer struct {
-	txDetail  TransactionWithDetail
-	txSummary TransactionSummary
+	txDetail   TransactionWithDetail
+	txSummary  TransactionSummary
+	categories []CategorySummary
 }
 
-func (s StubTxDetailStorer) GetTransactionDetailBySpenderId(ctx context.Context, id string, offset int, limit int) (TransactionWithDetail, error) {
+func (s StubTxDetailStorer) GetTransactionDetailBySpenderId(ctx context.Context, id string, filter Filter, offset int, limit int, sort Sort) (TransactionWithDetail, error) {
 	return s.txDetail, nil
 }
 
-func (s StubTxDetailStorer) GetTransactionSummaryBySpenderId(ctx context.Context, id string) (TransactionSummary, error) {
+func (s StubTxDetailStorer) GetTransactionPageBySpenderId(ctx context.Context, id string, filter Filter, page Page) (TransactionWithDetail, error) {
+	return s.txDetail, nil
+}
+
+func (s StubTxDetailStorer) GetTransactionSummaryBySpenderId(ctx context.Context, id string, period Period) (TransactionSummary, error) {
 	return s.txSummary, nil
 }
 
+func (s StubTxDetailStorer) GetCategorySummaryBySpenderId(ctx context.Context, id string, period Period) ([]CategorySummary, error) {
+	return s.categories, nil
+}
+
+func (s StubTxDetailStorer) GetPreferences(ctx context.Context, id string) (spender.Preferences, error) {
+	return spender.DefaultPreferences(1), nil
+}
+
 //=================================================================================================
 // SQL Mock
 
+// expectDefaultPreferences expects the lookup of a spender who never saved
+// preferences, their home currency is THB.
+func expectDefaultPreferences(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`).WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"timezone", "currency", "locale", "week_start", "month_start_day"}))
+}
+
+// homeCurrency is the summary condition of all time, after the scope id.
+func homeCurrency(alias string) string {
+	return fmt.Sprintf(" AND (%[1]saccount_id IS NULL OR %[1]saccount_id IN (SELECT id FROM account WHERE currency = $2))", alias)
+}
+
 func TestGetTransactionDetailBySpenderIdWithSQLMock(t *testing.T) {
 	t.Run("get transaction detail by spender id", func(t *testing.T) {
 		//create a new echo instance
@@ -228,17 +259,23 @@ func TestGetTransactionDetailBySpenderIdWithSQLMock(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url"}).
-			AddRow("1", "2024-04-30T09:00:00.000Z", 1000, "Food", "expense", 1, "Lunch", "https://example.com/image1.jpg").
-			AddRow("2", "2024-04-29T19:00:00.000Z", 2000, "Transport", "income", 1, "Salary", "https://example.com/image2.jpg")
-		mock.ExpectQuery(`SELECT id,date,amount,category, transaction_type,spender_id, note, image_url FROM transaction WHERE spender_id = $1 OFFSET $2 LIMIT $3`).WithArgs("1", 0, 10).WillReturnRows(rows)
+		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}).
+			AddRow("1", "2024-04-30T09:00:00.000Z", 1000, "Food", "expense", 1, "Lunch", "https://example.com/image1.jpg", "2024-04-30T09:05:00Z").
+			AddRow("2", "2024-04-29T19:00:00.000Z", 2000, "Transport", "income", 1, "Salary", "https://example.com/image2.jpg", "2024-04-29T19:05:00Z")
+		mock.ExpectQuery(`SELECT id, date, amount, category, transaction_type, spender_id, note, image_url, created_at FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL ORDER BY date DESC, id DESC OFFSET $2 LIMIT $3`).WithArgs("1", 0, 10).WillReturnRows(rows)
+
+		splitRows := sqlmock.NewRows([]string{"id", "transaction_id", "category", "amount", "note"}).
+			AddRow(1, "1", "Food", 600, "Rice").
+			AddRow(2, "1", "Drink", 400, "")
+		mock.ExpectQuery(splitsByTxIDs).WithArgs(sqlmock.AnyArg()).WillReturnRows(splitRows)
 
 		rowCount := sqlmock.NewRows([]string{"count"}).AddRow(2)
-		mock.ExpectQuery(`SELECT COUNT(*) FROM transaction WHERE spender_id = $1`).WithArgs("1").WillReturnRows(rowCount)
+		mock.ExpectQuery(`SELECT COUNT(*) FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL`).WithArgs("1").WillReturnRows(rowCount)
 
+		expectDefaultPreferences(mock)
 		rowsSummary := sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).
 			AddRow(2000, 1000, 1000)
-		mock.ExpectQuery(`SELECT SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE 0 END) AS total_income, SUM(CASE WHEN transaction_type = 'expense' THEN amount ELSE 0 END) AS total_expenses, SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE -amount END) AS current_balance FROM transaction WHERE spender_id = $1`).WithArgs("1").WillReturnRows(rowsSummary)
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", homeCurrency(""))).WithArgs("1", "THB").WillReturnRows(rowsSummary)
 
 		h := New(config.FeatureFlag{}, &Postgres{Db: db})
 		err := h.GetTransactionDetailBySpenderIdHandler(c)
@@ -256,7 +293,12 @@ func TestGetTransactionDetailBySpenderIdWithSQLMock(t *testing.T) {
 					"transaction_type": "expense",
 					"spender_id": 1,
 					"note": "Lunch",
-					"image_url": "https://example.com/image1.jpg"
+					"image_url": "https://example.com/image1.jpg",
+					"created_at": "2024-04-30T09:05:00Z",
+					"splits": [
+						{"id": 1, "category": "Food", "amount": 600, "note": "Rice"},
+						{"id": 2, "category": "Drink", "amount": 400, "note": ""}
+					]
 				},
 				{
 					"id": "2",
@@ -266,13 +308,15 @@ func TestGetTransactionDetailBySpenderIdWithSQLMock(t *testing.T) {
 					"transaction_type": "income",
 					"spender_id": 1,
 					"note": "Salary",
-					"image_url": "https://example.com/image2.jpg"
+					"image_url": "https://example.com/image2.jpg",
+					"created_at": "2024-04-29T19:05:00Z"
 				}
 			],
 			"summary": {
 				"total_income": 2000,
 				"total_expenses": 1000,
-				"current_balance": 1000
+				"current_balance": 1000,
+				"currency": "THB"
 			},
 			"pagination": {
 				"current_page": 1,
@@ -282,6 +326,154 @@ func TestGetTransactionDetailBySpenderIdWithSQLMock(t *testing.T) {
 		}`, tmp)
 	})
 
+	t.Run("only list the transactions with all of the tags", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions?category=Food&tags=trip-japan,reimbursable&tags_match=all", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		where := `spender_id = $1 AND deleted_at IS NULL AND category = $2 AND id IN (SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id AND g.spender_id = "transaction".spender_id WHERE g.name = ANY($3::text[]) GROUP BY tt.transaction_id HAVING COUNT(DISTINCT g.name) = cardinality($3::text[]))`
+		tags := pq.Array([]string{"trip-japan", "reimbursable"})
+		mock.ExpectQuery(fmt.Sprintf(listStmt, where, "date DESC, id DESC", 4, 5)).WithArgs("1", "Food", tags, 0, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}).
+				AddRow("1", "2024-04-30T09:00:00Z", 1000, "Food", "expense", 1, "Ramen", "", "2024-04-30T09:05:00Z"))
+		mock.ExpectQuery(splitsByTxIDs).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "category", "amount", "note"}))
+		mock.ExpectQuery(fmt.Sprintf(countStmt, where)).WithArgs("1", "Food", tags).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		expectDefaultPreferences(mock)
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", homeCurrency(""))).
+			WithArgs("1", "THB").WillReturnRows(sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).AddRow(0, 1000, -1000))
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetTransactionDetailBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [
+				{"id": "1", "date": "2024-04-30T09:00:00Z", "amount": 1000, "category": "Food", "transaction_type": "expense", "spender_id": 1, "note": "Ramen", "image_url": "", "created_at": "2024-04-30T09:05:00Z"}
+			],
+			"summary": {"total_income": 0, "total_expenses": 1000, "current_balance": -1000, "currency": "THB"},
+			"pagination": {"current_page": 1, "total_pages": 1, "per_page": 10}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject an unknown tags_match", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions?tags=food&tags_match=some", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		h := New(config.FeatureFlag{}, MockStubData())
+		err := h.GetTransactionDetailBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "tags_match must be any or all"}`, rec.Body.String())
+	})
+
+	t.Run("fail when the count fails", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, date, amount, category, transaction_type, spender_id, note, image_url, created_at FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL ORDER BY date DESC, id DESC OFFSET $2 LIMIT $3`).
+			WithArgs("1", 0, 10).WillReturnRows(sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}))
+		mock.ExpectQuery(fmt.Sprintf(countStmt, "spender_id = $1 AND deleted_at IS NULL")).WithArgs("1").WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetTransactionDetailBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetTransactionPageBySpenderIdWithSQLMock(t *testing.T) {
+	t.Run("get the page after the cursor with the total", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		cursor := Cursor{Sort: "-date", Values: []string{"2024-04-30T09:00:00Z"}, ID: "3"}.encode()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions?limit=1&include_total=true&cursor="+cursor, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}).
+			AddRow("2", "2024-04-29T19:00:00Z", 2000, "Transport", "income", 1, "Salary", "", "2024-04-29T19:00:00Z").
+			AddRow("1", "2024-04-29T08:00:00Z", 1000, "Food", "expense", 1, "Lunch", "", "2024-04-29T08:00:00Z")
+		mock.ExpectQuery(`SELECT id, date, amount, category, transaction_type, spender_id, note, image_url, created_at FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL AND (date, id) < ($2, $3) ORDER BY date DESC, id DESC LIMIT 2`).
+			WithArgs("1", "2024-04-30T09:00:00Z", "3").WillReturnRows(rows)
+		mock.ExpectQuery(splitsByTxIDs).WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "category", "amount", "note"}))
+		mock.ExpectQuery(fmt.Sprintf(countStmt, "spender_id = $1 AND deleted_at IS NULL")).WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		expectDefaultPreferences(mock)
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", homeCurrency(""))).
+			WithArgs("1", "THB").WillReturnRows(sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).AddRow(2000, 1000, 1000))
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetTransactionDetailBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [
+				{"id": "2", "date": "2024-04-29T19:00:00Z", "amount": 2000, "category": "Transport", "transaction_type": "income", "spender_id": 1, "note": "Salary", "image_url": "", "created_at": "2024-04-29T19:00:00Z"}
+			],
+			"summary": {"total_income": 2000, "total_expenses": 1000, "current_balance": 1000, "currency": "THB"},
+			"pagination": {
+				"current_page": 0,
+				"total_pages": 3,
+				"per_page": 1,
+				"next_cursor": "`+Cursor{Sort: "-date", Values: []string{"2024-04-29T19:00:00Z"}, ID: "2"}.encode()+`",
+				"prev_cursor": "`+Cursor{Sort: "-date", Values: []string{"2024-04-29T19:00:00Z"}, ID: "2", Before: true}.encode()+`",
+				"total_count": 3
+			}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject a tampered cursor", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions?cursor=abc", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		h := New(config.FeatureFlag{}, MockStubData())
+		err := h.GetTransactionDetailBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "invalid cursor"}`, rec.Body.String())
+	})
 }
 
 func TestGetTransactionSummaryBySpenderIdWithSQLMock(t *testing.T) {
@@ -300,9 +492,10 @@ func TestGetTransactionSummaryBySpenderIdWithSQLMock(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
+		expectDefaultPreferences(mock)
 		rows := sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).
 			AddRow(2000, 1000, 1000)
-		mock.ExpectQuery(`SELECT SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE 0 END) AS total_income, SUM(CASE WHEN transaction_type = 'expense' THEN amount ELSE 0 END) AS total_expenses, SUM(CASE WHEN transaction_type = 'income' THEN amount ELSE -amount END) AS current_balance FROM transaction WHERE spender_id = $1`).WithArgs("1").WillReturnRows(rows)
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", homeCurrency(""))).WithArgs("1", "THB").WillReturnRows(rows)
 
 		h := New(config.FeatureFlag{}, &Postgres{Db: db})
 		err := h.GetTransactionSummaryBySpenderIdHandler(c)
@@ -312,7 +505,115 @@ func TestGetTransactionSummaryBySpenderIdWithSQLMock(t *testing.T) {
 		assert.JSONEq(t, `{
 			"total_income": 2000,
 			"total_expenses": 1000,
-			"current_balance": 1000
+			"current_balance": 1000,
+			"currency": "THB"
 		}`, rec.Body.String())
 	})
 }
+
+func TestGetTransactionSummaryForPeriodWithSQLMock(t *testing.T) {
+	t.Run("get transaction summary of the spender's month", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions/summary?period=month&at=2024-05-10", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		bangkok, _ := time.LoadLocation("Asia/Bangkok")
+		mock.ExpectQuery(`SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"timezone", "currency", "locale", "week_start", "month_start_day"}).AddRow("Asia/Bangkok", "THB", "th-TH", "monday", 25))
+		rows := sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).
+			AddRow(30000, 1000, 29000)
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", " AND date >= $2 AND date < $3 AND (account_id IS NULL OR account_id IN (SELECT id FROM account WHERE currency = $4))")).
+			WithArgs("1", time.Date(2024, 4, 25, 0, 0, 0, 0, bangkok), time.Date(2024, 5, 25, 0, 0, 0, 0, bangkok), "THB").WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetTransactionSummaryBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"total_income": 30000,
+			"total_expenses": 1000,
+			"current_balance": 29000,
+			"period": {"kind": "month", "from": "2024-04-25", "to": "2024-05-24", "timezone": "Asia/Bangkok"},
+			"currency": "THB"
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetTransactionSummaryForEmptyPeriodWithSQLMock(t *testing.T) {
+	t.Run("a period without transactions sums to zero", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions/summary?period=day&at=2024-05-10", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		bangkok, _ := time.LoadLocation("Asia/Bangkok")
+		mock.ExpectQuery(`SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"timezone", "currency", "locale", "week_start", "month_start_day"}).AddRow("Asia/Bangkok", "THB", "th-TH", "monday", 1))
+		mock.ExpectQuery(fmt.Sprintf(summaryStmt, "spender_id", " AND date >= $2 AND date < $3 AND (account_id IS NULL OR account_id IN (SELECT id FROM account WHERE currency = $4))")).
+			WithArgs("1", time.Date(2024, 5, 10, 0, 0, 0, 0, bangkok), time.Date(2024, 5, 11, 0, 0, 0, 0, bangkok), "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"total_income", "total_expenses", "current_balance"}).AddRow(0, 0, 0))
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetTransactionSummaryBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"total_income": 0,
+			"total_expenses": 0,
+			"current_balance": 0,
+			"period": {"kind": "day", "from": "2024-05-10", "to": "2024-05-10", "timezone": "Asia/Bangkok"},
+			"currency": "THB"
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetCategorySummaryBySpenderIdWithSQLMock(t *testing.T) {
+	t.Run("get category summary counting split lines", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spenders/1/transactions/summary/categories", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"category", "transaction_type", "total", "count"}).
+			AddRow("Groceries", "expense", 800, 2).
+			AddRow("Household", "expense", 200, 1)
+		expectDefaultPreferences(mock)
+		mock.ExpectQuery(fmt.Sprintf(categorySummaryStmt, "spender_id", homeCurrency("t."))).WithArgs("1", "THB").WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, &Postgres{Db: db})
+		err := h.GetCategorySummaryBySpenderIdHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+			{"category": "Groceries", "transaction_type": "expense", "total": 800, "count": 2},
+			{"category": "Household", "transaction_type": "expense", "total": 200, "count": 1}
+		]`, rec.Body.String())
+	})
+}
@@ -5,10 +5,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,7 +33,9 @@ func TestCreateTransaction(t *testing.T) {
 		defer db.Close()
 
 		column := []string{"id"}
-		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 1000.0, "food", "expense", 1, "lunch", "http://image.com").WillReturnRows(sqlmock.NewRows(column).AddRow(1))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 1000.0, "food", "expense", 1, "lunch", "http://image.com", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows(column).AddRow(1))
+		mock.ExpectCommit()
 
 		h := NewHandler(config.FeatureFlag{}, db)
 
@@ -43,6 +48,96 @@ func TestCreateTransaction(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, rec.Code)
 		assert.JSONEq(t, `{"id": "1", "date": "2021-08-01", "amount": 1000, "category": "food", "transaction_type": "expense", "spender_id": 1, "note": "lunch", "image_url": "http://image.com"}`, rec.Body.String())
 	})
+
+	t.Run("fill the category and tags from a merchant rule", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 45, "category": "uncategorized", "transaction_type": "expense", "spender_id": 1, "merchant": "7-Eleven #0123"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, spender_id, match, pattern, category, tags, priority, created_at FROM merchant_rule WHERE spender_id = $1 ORDER BY priority DESC, id`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "spender_id", "match", "pattern", "category", "tags", "priority", "created_at"}).
+				AddRow(2, 1, "contains", "7-ELEVEN", "food", "{convenience}", 0, time.Now()))
+		mock.ExpectQuery(`SELECT id, name FROM merchant WHERE name = $1 UNION ALL SELECT m.id, m.name FROM merchant_alias a JOIN merchant m ON m.id = a.merchant_id WHERE a.alias = $1 LIMIT 1`).WithArgs("7-ELEVEN").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "7-ELEVEN"))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 45.0, "food", "expense", 1, "", "", nil, nil, nil, "7-ELEVEN", int64(5)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO tag (spender_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (spender_id, name) DO NOTHING;`).WithArgs(int64(1), pq.Array([]string{"convenience"})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO transaction_tag (transaction_id, tag_id) SELECT $1, id FROM tag WHERE spender_id = $2 AND name = ANY($3::text[]) ON CONFLICT DO NOTHING;`).WithArgs(int64(1), int64(1), pq.Array([]string{"convenience"})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": "1", "date": "2021-08-01", "amount": 45, "category": "food", "transaction_type": "expense", "spender_id": 1, "note": "", "image_url": "", "merchant": "7-ELEVEN"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("add a new merchant in the insert", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 45, "category": "food", "transaction_type": "expense", "spender_id": 1, "merchant": "Café Amazon"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, spender_id, match, pattern, category, tags, priority, created_at FROM merchant_rule WHERE spender_id = $1 ORDER BY priority DESC, id`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "spender_id", "match", "pattern", "category", "tags", "priority", "created_at"}))
+		mock.ExpectQuery(`SELECT id, name FROM merchant WHERE name = $1 UNION ALL SELECT m.id, m.name FROM merchant_alias a JOIN merchant m ON m.id = a.merchant_id WHERE a.alias = $1 LIMIT 1`).WithArgs("CAFÉ AMAZON").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(`INSERT INTO merchant (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`).WithArgs("CAFÉ AMAZON").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 45.0, "food", "expense", 1, "", "", nil, nil, nil, "CAFÉ AMAZON", int64(6)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a rejected body adds no merchant", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 45, "category": "food", "transaction_type": "expense", "spender_id": 1, "merchant": "Café Amazon", "splits": [{"category": "food", "amount": 10}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, spender_id, match, pattern, category, tags, priority, created_at FROM merchant_rule WHERE spender_id = $1 ORDER BY priority DESC, id`).WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "spender_id", "match", "pattern", "category", "tags", "priority", "created_at"}))
+		mock.ExpectQuery(`SELECT id, name FROM merchant WHERE name = $1 UNION ALL SELECT m.id, m.name FROM merchant_alias a JOIN merchant m ON m.id = a.merchant_id WHERE a.alias = $1 LIMIT 1`).WithArgs("CAFÉ AMAZON").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestUpdateTransaction(t *testing.T) {
@@ -65,7 +160,12 @@ func TestUpdateTransaction(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectQuery(uStmt).WithArgs("2021-08-01", 555.0, "shopping", "expense", 1, "lunch", "http://image.com", id)
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(snapshotStmt).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(uStmt).WithArgs("2021-08-01", 555.0, "shopping", "expense", 1, "lunch", "http://image.com", nil, nil, nil, "", nil, id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(dSplitStmt).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(dShareStmt).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
 
 		h := NewHandler(config.FeatureFlag{}, db)
 		err = h.Update(c)
@@ -78,3 +178,260 @@ func TestUpdateTransaction(t *testing.T) {
 		assert.JSONEq(t, `{"id": "1", "date": "2021-08-01", "amount": 555, "category": "shopping", "transaction_type": "expense", "spender_id": 1, "note": "lunch", "image_url": "http://image.com"}`, rec.Body.String())
 	})
 }
+
+func TestCreateTransactionWithSplits(t *testing.T) {
+	t.Run("create transaction with split lines", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 1000, "category": "shopping", "transaction_type": "expense", "spender_id": 1, "note": "big c", "image_url": "",
+			"splits": [{"category": "groceries", "amount": 800, "note": "food"}, {"category": "household", "amount": 200, "note": "soap"}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 1000.0, "shopping", "expense", 1, "big c", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(cSplitStmt).WithArgs("1", "groceries", 800.0, "food").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectQuery(cSplitStmt).WithArgs("1", "household", 200.0, "soap").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": "1", "date": "2021-08-01", "amount": 1000, "category": "shopping", "transaction_type": "expense", "spender_id": 1, "note": "big c", "image_url": "",
+			"splits": [{"id": 10, "category": "groceries", "amount": 800, "note": "food"}, {"id": 11, "category": "household", "amount": 200, "note": "soap"}]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject splits that do not add up", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 1000, "category": "shopping", "transaction_type": "expense", "spender_id": 1,
+			"splits": [{"category": "groceries", "amount": 800.10}, {"category": "household", "amount": 200}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := NewHandler(config.FeatureFlag{}, nil)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "sum of splits must equal the transaction amount",
+			"errors": [{"field": "splits", "message": "sum of splits must equal the transaction amount"}]}`, rec.Body.String())
+	})
+}
+
+func TestCreateSharedTransaction(t *testing.T) {
+	t.Run("share the bill between spenders", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1,
+			"sharing": {"type": "equal", "participants": [{"spender_id": 1}, {"spender_id": 2}]}}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 100.0, "food", "expense", 1, "", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(participantsStmt).WithArgs(pq.Array([]int{1, 2})).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectExec(cShareStmt).WithArgs("1", 1, 50.0).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(cShareStmt).WithArgs("1", 2, 50.0).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a participant that is not a spender is rejected", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1,
+			"sharing": {"type": "equal", "participants": [{"spender_id": 1}, {"spender_id": 99}]}}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 100.0, "food", "expense", 1, "", "", nil, nil, nil, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(participantsStmt).WithArgs(pq.Array([]int{1, 99})).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectRollback()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "participant spender 99 not found"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateTransactionInWallet(t *testing.T) {
+	t.Run("viewer cannot record into a wallet", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 2, "wallet_id": 3}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(walletWriterStmt).WithArgs(3, 2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateTransfer(t *testing.T) {
+	t.Run("move money between two accounts of the spender", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 500, "category": "saving", "transaction_type": "transfer", "spender_id": 1, "account_id": 1, "to_account_id": 2}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(accountOwnerStmt).WithArgs(pq.Array([]int{1, 2}), 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectQuery(cStmt).WithArgs("2021-08-01", 500.0, "saving", "transfer", 1, "", "", nil, 1, 2, "", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": "7", "date": "2021-08-01", "amount": 500, "category": "saving", "transaction_type": "transfer", "spender_id": 1, "note": "", "image_url": "", "account_id": 1, "to_account_id": 2}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject a transfer to the same account", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 500, "category": "saving", "transaction_type": "transfer", "spender_id": 1, "account_id": 1, "to_account_id": 1}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("reject an account of another spender", func(t *testing.T) {
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"date": "2021-08-01", "amount": 500, "category": "saving", "transaction_type": "transfer", "spender_id": 1, "account_id": 1, "to_account_id": 9}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(accountOwnerStmt).WithArgs(pq.Array([]int{1, 9}), 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteTransaction(t *testing.T) {
+	t.Run("delete leaves a tombstone", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("transaction already deleted", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(dStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err := h.Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -1,12 +1,19 @@
 package transaction
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/tag"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -16,8 +23,14 @@ type handlerTransaction struct {
 }
 
 const (
-	cStmt = `INSERT INTO transaction (date, amount, category, transaction_type, spender_id, note, image_url) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
-	uStmt = `UPDATE transaction SET date = $1, amount = $2, category = $3, transaction_type = $4, spender_id = $5, note = $6, image_url = $7 WHERE id = $8;`
+	cStmt = `INSERT INTO transaction (date, amount, category, transaction_type, spender_id, note, image_url, wallet_id, account_id, to_account_id, merchant, merchant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id;`
+	uStmt = `UPDATE transaction SET date = $1, amount = $2, category = $3, transaction_type = $4, spender_id = $5, note = $6, image_url = $7, wallet_id = $8, account_id = $9, to_account_id = $10, merchant = $11, merchant_id = $12 WHERE id = $13 AND deleted_at IS NULL;`
+
+	walletWriterStmt = `SELECT EXISTS (SELECT 1 FROM wallet_member WHERE wallet_id = $1 AND spender_id = $2 AND role IN ('owner', 'editor'))`
+	accountOwnerStmt = `SELECT COUNT(*) FROM account WHERE id = ANY($1::int[]) AND spender_id = $2`
+
+	// the row stays as a tombstone for the change feed
+	dStmt = `UPDATE transaction SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;`
 )
 
 func NewHandler(cfg config.FeatureFlag, db *sql.DB) *handlerTransaction {
@@ -32,27 +45,31 @@ func (h handlerTransaction) Create(c echo.Context) error {
 	err := c.Bind(&trBody)
 	if err != nil {
 		logger.Error("bad request body", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return apierror.Invalid(c, err)
+	}
+
+	shares, herr := h.check(ctx, &trBody)
+	if herr != nil {
+		if herr.Internal != nil {
+			logger.Error("query row error", zap.Error(herr.Internal))
+		}
+		return apierror.Write(c, herr)
 	}
 
 	var insertTransactionId string
 
-	err = h.db.QueryRowContext(ctx, cStmt, trBody.Date, trBody.Amount, trBody.Category, trBody.TransactionType, trBody.SpenderID, trBody.Note, trBody.ImageURL).Scan(&insertTransactionId)
+	insertTransactionId, err = h.createWithDetails(ctx, trBody, shares)
 
-	transaction := Transaction{
-		ID:              insertTransactionId,
-		Date:            trBody.Date,
-		Amount:          trBody.Amount,
-		Category:        trBody.Category,
-		TransactionType: trBody.TransactionType,
-		SpenderID:       trBody.SpenderID,
-		Note:            trBody.Note,
-		ImageURL:        trBody.ImageURL,
-	}
+	transaction := trBody.transaction(insertTransactionId)
+	transaction.Shares = shares
 
+	var missing missingParticipantError
+	if errors.As(err, &missing) {
+		return apierror.Respond(c, http.StatusBadRequest, missing.Error())
+	}
 	if err != nil {
 		logger.Error("query row error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apierror.Internal(c)
 	}
 
 	logger.Info("create successfully", zap.String("id", insertTransactionId))
@@ -67,29 +84,227 @@ func (h handlerTransaction) Update(c echo.Context) error {
 	err := c.Bind(&trBody)
 	if err != nil {
 		logger.Error("bad request body", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return apierror.Invalid(c, err)
+	}
+
+	shares, herr := h.check(ctx, &trBody)
+	if herr != nil {
+		if herr.Internal != nil {
+			logger.Error("query row error", zap.Error(herr.Internal))
+		}
+		return apierror.Write(c, herr)
 	}
 
 	id := c.Param("id")
-	// var updatedTransaction Transaction
-	_ = h.db.QueryRowContext(ctx, uStmt, trBody.Date, trBody.Amount, trBody.Category, trBody.TransactionType, trBody.SpenderID, trBody.Note, trBody.ImageURL, id).Scan()
+	found, err := h.update(ctx, id, trBody, shares)
+	var missing missingParticipantError
+	if errors.As(err, &missing) {
+		return apierror.Respond(c, http.StatusBadRequest, missing.Error())
+	}
+	if err != nil {
+		logger.Error("update error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "transaction not found")
+	}
+
+	transaction := trBody.transaction(id)
+	transaction.Shares = shares
+
+	logger.Info("update successfully", zap.String("id", id))
+	return c.JSON(http.StatusOK, transaction)
+}
 
-	transaction := Transaction{
-		ID:              id,
-		Date:            trBody.Date,
-		Amount:          trBody.Amount,
-		Category:        trBody.Category,
-		TransactionType: trBody.TransactionType,
-		SpenderID:       trBody.SpenderID,
-		Note:            trBody.Note,
-		ImageURL:        trBody.ImageURL,
+// DELETE /api/v1/transactions/:id
+func (h handlerTransaction) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	found, err := h.remove(ctx, id)
+	if err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "transaction not found")
 	}
 
+	logger.Info("delete successfully", zap.String("id", id))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// remove soft deletes the transaction, it reports false when the id does
+// not exist.
+func (h handlerTransaction) remove(ctx context.Context, id string) (bool, error) {
+	tx, err := audit.BeginTx(ctx, h.db)
 	if err != nil {
-		logger.Error("query row error", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return false, err
 	}
+	defer tx.Rollback()
 
-	logger.Info("create successfully", zap.String("id", id))
-	return c.JSON(http.StatusOK, transaction)
+	res, err := tx.ExecContext(ctx, dStmt, id)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// createWithDetails inserts the transaction with its split lines and bill
+// shares in one DB transaction.
+func (h handlerTransaction) createWithDetails(ctx context.Context, trBody TransactionReqBody, shares []Share) (string, error) {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	id, err := insert(ctx, tx, trBody, shares)
+	if err != nil {
+		return "", err
+	}
+	return id, tx.Commit()
+}
+
+// insert records the transaction with its split lines, bill shares and
+// the tags from its merchant rules inside tx.
+func insert(ctx context.Context, tx *sql.Tx, trBody TransactionReqBody, shares []Share) (string, error) {
+	if err := trBody.ensureMerchant(ctx, tx); err != nil {
+		return "", err
+	}
+	var id string
+	if err := tx.QueryRowContext(ctx, cStmt, trBody.args()...).Scan(&id); err != nil {
+		return "", err
+	}
+	if err := insertSplits(ctx, tx, id, trBody.Splits); err != nil {
+		return "", err
+	}
+	if err := insertShares(ctx, tx, id, shares); err != nil {
+		return "", err
+	}
+	if err := attachRuleTags(ctx, tx, id, trBody); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func attachRuleTags(ctx context.Context, tx *sql.Tx, id string, trBody TransactionReqBody) error {
+	if len(trBody.ruleTags) == 0 {
+		return nil
+	}
+	txID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	return tag.Attach(ctx, tx, txID, int64(trBody.SpenderID), trBody.ruleTags)
+}
+
+// update replaces the transaction with its split lines and bill shares, PUT
+// without them removes any existing ones. The version replaced is kept for
+// a revert. It reports false when the id does not exist.
+func (h handlerTransaction) update(ctx context.Context, id string, trBody TransactionReqBody, shares []Share) (bool, error) {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, snapshotStmt, id)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := trBody.ensureMerchant(ctx, tx); err != nil {
+		return false, err
+	}
+	res, err = tx.ExecContext(ctx, uStmt, append(trBody.args(), id)...)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, dSplitStmt, id); err != nil {
+		return false, err
+	}
+	if err := insertSplits(ctx, tx, id, trBody.Splits); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, dShareStmt, id); err != nil {
+		return false, err
+	}
+	if err := insertShares(ctx, tx, id, shares); err != nil {
+		return false, err
+	}
+	if err := attachRuleTags(ctx, tx, id, trBody); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// check runs the merchant rules on the body, then verifies it. It only
+// reads, a new merchant is added by the write.
+func (h handlerTransaction) check(ctx context.Context, trBody *TransactionReqBody) ([]Share, *echo.HTTPError) {
+	if err := trBody.categorize(ctx, h.db); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Please check server logs").SetInternal(err)
+	}
+	return h.verify(ctx, *trBody)
+}
+
+// verify validates the body and the spender's rights on the wallet and the
+// accounts it references, then resolves how the bill is shared.
+func (h handlerTransaction) verify(ctx context.Context, trBody TransactionReqBody) ([]Share, *echo.HTTPError) {
+	shares, err := trBody.validate()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if ok, err := h.canWriteWallet(ctx, trBody); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Please check server logs").SetInternal(err)
+	} else if !ok {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "spender is not allowed to record into this wallet")
+	}
+
+	if ok, err := h.ownsAccounts(ctx, trBody); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Please check server logs").SetInternal(err)
+	} else if !ok {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "account does not belong to the spender")
+	}
+	return shares, nil
+}
+
+// canWriteWallet reports whether the spender may record into the wallet of
+// the transaction, only owners and editors can. No wallet is always allowed.
+func (h handlerTransaction) canWriteWallet(ctx context.Context, trBody TransactionReqBody) (bool, error) {
+	if trBody.WalletID == nil {
+		return true, nil
+	}
+
+	var ok bool
+	err := h.db.QueryRowContext(ctx, walletWriterStmt, *trBody.WalletID, trBody.SpenderID).Scan(&ok)
+	return ok, err
+}
+
+// ownsAccounts reports whether the accounts the transaction moves money
+// through belong to its spender.
+func (h handlerTransaction) ownsAccounts(ctx context.Context, trBody TransactionReqBody) (bool, error) {
+	var ids []int
+	for _, id := range []*int{trBody.AccountID, trBody.ToAccountID} {
+		if id != nil {
+			ids = append(ids, *id)
+		}
+	}
+	if len(ids) == 0 {
+		return true, nil
+	}
+
+	var n int
+	err := h.db.QueryRowContext(ctx, accountOwnerStmt, pq.Array(ids), trBody.SpenderID).Scan(&n)
+	return n == len(ids), err
 }
@@ -25,9 +25,9 @@ func TestGetTransactionsHandler(t *testing.T) {
 	defer db.Close()
 
 	// Define expectations for SQL mock
-	rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url"}).
-		AddRow(1, time.Now(), 100.0, "Food", "expense", 1, "Dinner out", "http://example.com/receipt.jpg").
-		AddRow(2, time.Now(), 200.0, "Salary", "income", 1, "Monthly salary", "http://example.com/salary.jpg")
+	rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}).
+		AddRow(1, time.Now(), 100.0, "Food", "expense", 1, "Dinner out", "http://example.com/receipt.jpg", time.Now()).
+		AddRow(2, time.Now(), 200.0, "Salary", "income", 1, "Monthly salary", "http://example.com/salary.jpg", time.Now())
 
 	mock.ExpectQuery("^SELECT (.+) FROM \"transaction\" WHERE").WillReturnRows(rows)
 	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM \"transaction\" WHERE").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
@@ -52,3 +52,57 @@ func TestGetTransactionsHandler(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestGetTransactionsHandlerInvalidSort(t *testing.T) {
+	e := echo.New()
+
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/?sort=note", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := GetTransactionsHandler(db)(c)
+
+	var httpErr *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+	}
+}
+
+func TestGetTransactionsHandlerKeyset(t *testing.T) {
+	e := echo.New()
+
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "transaction_type", "spender_id", "note", "image_url", "created_at"}).
+		AddRow(3, "2024-04-30T09:00:00Z", 100.0, "Food", "expense", 1, "Dinner out", "", "2024-04-30T09:00:00Z").
+		AddRow(2, "2024-04-29T09:00:00Z", 100.0, "Food", "expense", 1, "Lunch", "", "2024-04-29T09:00:00Z")
+	mock.ExpectQuery(`SELECT id, date, amount, category, transaction_type, spender_id, note, image_url, created_at FROM "transaction" WHERE deleted_at IS NULL AND category = $1 ORDER BY amount DESC, date ASC, id ASC LIMIT 2`).
+		WithArgs("Food").WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/?cursor=&limit=1&category=Food&sort=-amount,date", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := GetTransactionsHandler(db)
+
+	if assert.NoError(t, h(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [
+				{"id": "3", "date": "2024-04-30T09:00:00Z", "amount": 100, "category": "Food", "transaction_type": "expense", "spender_id": 1, "note": "Dinner out", "image_url": "", "created_at": "2024-04-30T09:00:00Z"}
+			],
+			"summary": {"total_income": 0, "total_expenses": 100, "current_balance": -100},
+			"pagination": {
+				"current_page": 0,
+				"total_pages": 0,
+				"per_page": 1,
+				"next_cursor": "`+Cursor{Sort: "-amount,date", Values: []string{"100", "2024-04-30T09:00:00Z"}, ID: "3"}.encode()+`"
+			}
+		}`, rec.Body.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
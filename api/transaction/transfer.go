@@ -0,0 +1,30 @@
+package transaction
+
+import "errors"
+
+// Transfer moves money between two accounts of the same spender, it is
+// neither income nor expense so summaries leave it out.
+const Transfer = "transfer"
+
+func validateTransfer(b TransactionReqBody) error {
+	if b.TransactionType != Transfer {
+		if b.ToAccountID != nil {
+			return errors.New("to_account_id is only allowed for transfers")
+		}
+		return nil
+	}
+
+	if b.AccountID == nil || b.ToAccountID == nil {
+		return errors.New("transfer needs both account_id and to_account_id")
+	}
+	if *b.AccountID == *b.ToAccountID {
+		return errors.New("transfer needs two different accounts")
+	}
+	if b.Amount <= 0 {
+		return errors.New("transfer amount must be greater than zero")
+	}
+	if len(b.Splits) > 0 || b.Sharing != nil {
+		return errors.New("transfer cannot have splits or sharing")
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// snapshotStmt keeps the transaction as it is before an edit, it locks
+	// the row so the version saved is the one replaced
+	snapshotStmt = `INSERT INTO transaction_version (transaction_id, version, data)
+		SELECT t.id, t.version, jsonb_build_object(
+			'date', t.date, 'amount', t.amount, 'category', t.category, 'transaction_type', t.transaction_type,
+			'spender_id', t.spender_id, 'note', COALESCE(t.note, ''), 'image_url', COALESCE(t.image_url, ''),
+			'wallet_id', t.wallet_id, 'account_id', t.account_id, 'to_account_id', t.to_account_id,
+			'merchant', t.merchant, 'merchant_id', t.merchant_id,
+			'splits', COALESCE((SELECT jsonb_agg(jsonb_build_object('category', s.category, 'amount', s.amount, 'note', COALESCE(s.note, '')) ORDER BY s.id) FROM transaction_split s WHERE s.transaction_id = t.id), '[]'::jsonb),
+			'shares', COALESCE((SELECT jsonb_agg(jsonb_build_object('spender_id', h.spender_id, 'amount', h.amount) ORDER BY h.spender_id) FROM transaction_share h WHERE h.transaction_id = t.id), '[]'::jsonb))
+		FROM "transaction" t WHERE t.id = $1 AND t.deleted_at IS NULL FOR UPDATE OF t;`
+	versionsStmt   = `SELECT version, data, created_at FROM transaction_version WHERE transaction_id = $1 ORDER BY version`
+	versionStmt    = `SELECT data FROM transaction_version WHERE transaction_id = $1 AND version = $2`
+	curVersionStmt = `SELECT version FROM transaction WHERE id = $1 AND deleted_at IS NULL`
+	merchantStmt   = `SELECT EXISTS (SELECT 1 FROM merchant WHERE id = $1 FOR SHARE)`
+)
+
+// snapshot is a stored version, the body it was written with, the
+// merchant it was linked to and the shares it resolved to.
+type snapshot struct {
+	TransactionReqBody
+	MerchantID *int64  `json:"merchant_id"`
+	Shares     []Share `json:"shares"`
+}
+
+// body asks for the snapshot again as it was, its merchant kept and its
+// shares as the exact amounts they came to.
+func (s snapshot) body() TransactionReqBody {
+	b := s.TransactionReqBody
+	b.merchantID, b.restored = s.MerchantID, true
+	if len(s.Shares) > 0 {
+		b.Sharing = &ShareRequest{Type: ShareExact}
+		for _, sh := range s.Shares {
+			b.Sharing.Participants = append(b.Sharing.Participants, Participant{SpenderID: sh.SpenderID, Amount: sh.Amount})
+		}
+	}
+	return b
+}
+
+func (s snapshot) transaction(id string) Transaction {
+	t := s.TransactionReqBody.transaction(id)
+	t.Shares = s.Shares
+	return t
+}
+
+// Version is the transaction as it was until an edit replaced it at
+// ReplacedAt.
+type Version struct {
+	Version     int         `json:"version"`
+	Transaction Transaction `json:"transaction"`
+	ReplacedAt  time.Time   `json:"replaced_at"`
+}
+
+type Versions struct {
+	Current  int       `json:"current"`
+	Versions []Version `json:"versions"`
+}
+
+// GET /api/v1/transactions/:id/versions
+// The versions replaced by edits, oldest first. Writes other than an edit,
+// like a bulk recategorize, move the version on without keeping the one
+// before, their numbers are missing from the list.
+func (h handlerTransaction) Versions(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid transaction id")
+	}
+
+	var vs Versions
+	err := h.db.QueryRowContext(ctx, curVersionStmt, id).Scan(&vs.Current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "transaction not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	vs.Versions, err = h.versions(ctx, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	return c.JSON(http.StatusOK, vs)
+}
+
+func (h handlerTransaction) versions(ctx context.Context, id string) ([]Version, error) {
+	rows, err := h.db.QueryContext(ctx, versionsStmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []Version{}
+	for rows.Next() {
+		var (
+			v    Version
+			data []byte
+			s    snapshot
+		)
+		if err := rows.Scan(&v.Version, &data, &v.ReplacedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		v.Transaction = s.transaction(id)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// keepMerchant leaves the merchant of a reverted version as it was, only
+// unlinked when the merchant has since been deleted or merged into another,
+// as the delete does to the transactions still linked to it.
+func (b *TransactionReqBody) keepMerchant(ctx context.Context, tx *sql.Tx) error {
+	if b.merchantID == nil {
+		return nil
+	}
+	var ok bool
+	if err := tx.QueryRowContext(ctx, merchantStmt, *b.merchantID).Scan(&ok); err != nil {
+		return err
+	}
+	if !ok {
+		b.merchantID = nil
+	}
+	return nil
+}
+
+// POST /api/v1/transactions/:id/revert?version=2
+// Restores a previous version as a new edit, the version it replaces is
+// kept like any other so the revert can itself be reverted. The version is
+// restored as it was, the merchant rules don't run again, but checked like
+// the body of an edit, a wallet the spender can no longer write to or an
+// account since deleted refuse the revert.
+func (h handlerTransaction) Revert(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid transaction id")
+	}
+	version, err := strconv.Atoi(c.QueryParam("version"))
+	if err != nil || version < 1 {
+		return apierror.Respond(c, http.StatusBadRequest, "version must be a positive number")
+	}
+
+	var data []byte
+	err = h.db.QueryRowContext(ctx, versionStmt, id, version).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "version not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		logger.Error("decode version error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	body := s.body()
+	shares, herr := h.verify(ctx, body)
+	if herr != nil {
+		if herr.Internal != nil {
+			logger.Error("query row error", zap.Error(herr.Internal))
+		}
+		return apierror.Write(c, herr)
+	}
+
+	found, err := h.update(ctx, id, body, shares)
+	var missing missingParticipantError
+	if errors.As(err, &missing) {
+		return apierror.Respond(c, http.StatusBadRequest, missing.Error())
+	}
+	if err != nil {
+		logger.Error("revert error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "transaction not found")
+	}
+
+	transaction := body.transaction(id)
+	transaction.Shares = shares
+
+	logger.Info("revert successfully", zap.String("id", id), zap.Int("version", version))
+	return c.JSON(http.StatusOK, transaction)
+}
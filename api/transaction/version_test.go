@@ -0,0 +1,224 @@
+package transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+const versionData = `{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1, "note": "lunch", "image_url": "", "wallet_id": null, "account_id": null, "to_account_id": null, "merchant": "7-ELEVEN", "merchant_id": 9,
+	"splits": [{"category": "food", "amount": 60, "note": ""}, {"category": "drink", "amount": 40, "note": "coffee"}],
+	"shares": [{"spender_id": 1, "amount": 50}, {"spender_id": 2, "amount": 50}]}`
+
+func TestVersions(t *testing.T) {
+	t.Run("list the versions replaced by edits", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		replaced := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(curVersionStmt).WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+		mock.ExpectQuery(versionsStmt).WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"version", "data", "created_at"}).
+			AddRow(1, []byte(versionData), replaced))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Versions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"current": 3, "versions": [{"version": 1, "replaced_at": "2024-05-01T10:00:00Z", "transaction": {
+			"id": "1", "date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1, "note": "lunch", "image_url": "", "merchant": "7-ELEVEN",
+			"splits": [{"category": "food", "amount": 60, "note": ""}, {"category": "drink", "amount": 40, "note": "coffee"}],
+			"shares": [{"spender_id": 1, "amount": 50}, {"spender_id": 2, "amount": 50}]}}]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(curVersionStmt).WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Versions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRevert(t *testing.T) {
+	revert := func(version string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/?version="+version, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	t.Run("restore a previous version", func(t *testing.T) {
+		c, rec := revert("1")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(versionStmt).WithArgs("1", 1).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte(versionData)))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(snapshotStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(merchantStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(uStmt).WithArgs("2021-08-01", 100.0, "food", "expense", 1, "lunch", "", nil, nil, nil, "7-ELEVEN", int64(9), "1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(dSplitStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(cSplitStmt).WithArgs("1", "food", 60.0, "").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectQuery(cSplitStmt).WithArgs("1", "drink", 40.0, "coffee").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+		mock.ExpectExec(dShareStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(participantsStmt).WithArgs(pq.Array([]int{1, 2})).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectExec(cShareStmt).WithArgs("1", 1, 50.0).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(cShareStmt).WithArgs("1", 2, 50.0).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": "1", "date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1, "note": "lunch", "image_url": "", "merchant": "7-ELEVEN",
+			"splits": [{"id": 11, "category": "food", "amount": 60, "note": ""}, {"id": 12, "category": "drink", "amount": 40, "note": "coffee"}],
+			"shares": [{"spender_id": 1, "amount": 50}, {"spender_id": 2, "amount": 50}]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a merchant deleted since is unlinked, not made again", func(t *testing.T) {
+		c, rec := revert("2")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		data := `{"date": "2021-08-01", "amount": 100, "category": "snacks", "transaction_type": "expense", "spender_id": 1, "merchant": "7-ELEVEN", "merchant_id": 9, "splits": [], "shares": []}`
+		mock.ExpectQuery(versionStmt).WithArgs("1", 2).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte(data)))
+		audittest.ExpectBegin(mock, audittest.Anonymous)
+		mock.ExpectExec(snapshotStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(merchantStmt).WithArgs(int64(9)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(uStmt).WithArgs("2021-08-01", 100.0, "snacks", "expense", 1, "", "", nil, nil, nil, "7-ELEVEN", nil, "1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(dSplitStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(dShareStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuse a version in a wallet the spender can no longer write to", func(t *testing.T) {
+		c, rec := revert("2")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		data := `{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1, "wallet_id": 4, "splits": [], "shares": []}`
+		mock.ExpectQuery(versionStmt).WithArgs("1", 2).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte(data)))
+		mock.ExpectQuery(walletWriterStmt).WithArgs(4, 1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuse a version whose account was deleted", func(t *testing.T) {
+		c, rec := revert("2")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		data := `{"date": "2021-08-01", "amount": 100, "category": "food", "transaction_type": "expense", "spender_id": 1, "account_id": 7, "splits": [], "shares": []}`
+		mock.ExpectQuery(versionStmt).WithArgs("1", 2).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte(data)))
+		mock.ExpectQuery(accountOwnerStmt).WithArgs(pq.Array([]int{7}), 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		c, rec := revert("7")
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("error creating mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(versionStmt).WithArgs("1", 7).WillReturnRows(sqlmock.NewRows([]string{"data"}))
+
+		h := NewHandler(config.FeatureFlag{}, db)
+		err = h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		c, rec := revert("zero")
+
+		h := NewHandler(config.FeatureFlag{}, nil)
+		err := h.Revert(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,446 @@
+package wallet
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// SummaryStorer computes wallet totals with the same logic as the spender
+// summaries, see transaction.Postgres.
+type SummaryStorer interface {
+	GetTransactionSummaryByWalletId(ctx context.Context, id string, currency string) (transaction.TransactionSummary, error)
+	GetCategorySummaryByWalletId(ctx context.Context, id string, currency string) ([]transaction.CategorySummary, error)
+}
+
+type handler struct {
+	flag    config.FeatureFlag
+	db      *sql.DB
+	summary SummaryStorer
+}
+
+func New(cfg config.FeatureFlag, db *sql.DB, summary SummaryStorer) *handler {
+	return &handler{cfg, db, summary}
+}
+
+const (
+	cStmt       = `INSERT INTO wallet (name) VALUES ($1) RETURNING id, created_at;`
+	cMemberStmt = `INSERT INTO wallet_member (wallet_id, spender_id, role) VALUES ($1, $2, $3);`
+	uMemberStmt = `UPDATE wallet_member SET role = $1 WHERE wallet_id = $2 AND spender_id = $3;`
+	dMemberStmt = `DELETE FROM wallet_member WHERE wallet_id = $1 AND spender_id = $2;`
+
+	roleStmt = `SELECT role FROM wallet_member WHERE wallet_id = $1 AND spender_id = $2`
+	// locks the acting member and the owners so concurrent role changes and
+	// removals go one at a time, in spender order so they can't deadlock
+	lockStmt    = `SELECT spender_id, role FROM wallet_member WHERE wallet_id = $1 AND (role = 'owner' OR spender_id = $2) ORDER BY spender_id FOR UPDATE`
+	getStmt     = `SELECT id, name, created_at FROM wallet WHERE id = $1`
+	membersStmt = `SELECT m.spender_id, s.name, s.email, m.role FROM wallet_member m JOIN spender s ON s.id = m.spender_id WHERE m.wallet_id = $1 ORDER BY m.spender_id`
+	bySpender   = `SELECT w.id, w.name, w.created_at, m.role FROM wallet w JOIN wallet_member m ON m.wallet_id = w.id WHERE m.spender_id = $1 ORDER BY w.id`
+)
+
+// role returns the role of the spender in the wallet, empty when the
+// spender is not a member.
+func (h handler) role(ctx context.Context, walletID, spenderID int64) (string, error) {
+	var role string
+	err := h.db.QueryRowContext(ctx, roleStmt, walletID, spenderID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+type access struct {
+	walletID int64
+	actor    int64
+	role     string
+}
+
+// authorize resolves the acting spender and the wallet id and checks the
+// actor's role with allowed. Non members get 404 so wallet ids can't be probed.
+func (h handler) authorize(c echo.Context, allowed func(string) bool) (access, *echo.HTTPError) {
+	actor, err := actorID(c)
+	if err != nil {
+		return access{}, echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	walletID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return access{}, echo.NewHTTPError(http.StatusBadRequest, "invalid wallet id")
+	}
+
+	role, err := h.role(c.Request().Context(), walletID, actor)
+	if err != nil {
+		mlog.L(c).Error("query row error", zap.Error(err))
+		return access{}, echo.NewHTTPError(http.StatusInternalServerError, "Please check server logs")
+	}
+	if role == "" {
+		return access{}, echo.NewHTTPError(http.StatusNotFound, "wallet not found")
+	}
+	if !allowed(role) {
+		return access{}, echo.NewHTTPError(http.StatusForbidden, "your wallet role does not allow this action")
+	}
+	return access{walletID, actor, role}, nil
+}
+
+// POST /api/v1/wallets
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	actor, err := actorID(c)
+	if err != nil {
+		return apierror.Respond(c, http.StatusUnauthorized, err.Error())
+	}
+
+	var w Wallet
+	if err := c.Bind(&w); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if w.Name == "" {
+		return apierror.Respond(c, http.StatusBadRequest, "name is required")
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, cStmt, w.Name).Scan(&w.ID, &w.CreatedAt); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if _, err := tx.ExecContext(ctx, cMemberStmt, w.ID, actor, RoleOwner); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	w.Role = RoleOwner
+	w.Members = []Member{{SpenderID: actor, Role: RoleOwner}}
+
+	logger.Info("create successfully", zap.Int64("id", w.ID))
+	return c.JSON(http.StatusCreated, w)
+}
+
+// GET /api/v1/wallets/:id
+func (h handler) GetByID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canView)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+	id := a.walletID
+
+	var w Wallet
+	if err := h.db.QueryRowContext(ctx, getStmt, id).Scan(&w.ID, &w.Name, &w.CreatedAt); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	w.Role = a.role
+
+	rows, err := h.db.QueryContext(ctx, membersStmt, id)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.SpenderID, &m.Name, &m.Email, &m.Role); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		w.Members = append(w.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, w)
+}
+
+// GET /api/v1/spenders/:id/wallets
+// Only the spender lists their own wallets, others get 404.
+func (h handler) GetBySpenderID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	actor, err := actorID(c)
+	if err != nil {
+		return apierror.Respond(c, http.StatusUnauthorized, err.Error())
+	}
+
+	spenderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	if spenderID != actor {
+		return apierror.Respond(c, http.StatusNotFound, "spender not found")
+	}
+
+	rows, err := h.db.QueryContext(ctx, bySpender, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer rows.Close()
+
+	wallets := []Wallet{}
+	for rows.Next() {
+		var w Wallet
+		if err := rows.Scan(&w.ID, &w.Name, &w.CreatedAt, &w.Role); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return apierror.Internal(c)
+		}
+		wallets = append(wallets, w)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("rows error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, wallets)
+}
+
+// PUT /api/v1/wallets/:id/members/:spender_id
+func (h handler) UpdateMember(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canManage)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+	id := a.walletID
+
+	memberID, err := strconv.ParseInt(c.Param("spender_id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+
+	var m Member
+	if err := c.Bind(&m); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if !validRole(m.Role) {
+		return apierror.Respond(c, http.StatusBadRequest, "role must be one of owner, editor or viewer")
+	}
+	m.SpenderID = memberID
+
+	found, err := h.changeMember(ctx, a, canManage, memberID, m.Role != RoleOwner, uMemberStmt, m.Role, id, memberID)
+	if herr := changeError(err); herr != nil {
+		return apierror.Write(c, herr)
+	}
+	if errors.Is(err, errLastOwner) {
+		return apierror.Respond(c, http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		logger.Error("update member error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "member not found")
+	}
+
+	logger.Info("update member successfully", zap.Int64("wallet_id", id), zap.Int64("spender_id", memberID))
+	return c.JSON(http.StatusOK, m)
+}
+
+// DELETE /api/v1/wallets/:id/members/:spender_id
+// Owners remove anyone, other members can only leave by removing themselves.
+func (h handler) RemoveMember(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canView)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+	id := a.walletID
+
+	memberID, err := strconv.ParseInt(c.Param("spender_id"), 10, 64)
+	if err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid spender id")
+	}
+	allowed := canManage
+	if memberID == a.actor {
+		allowed = canView
+	}
+	if !allowed(a.role) {
+		return apierror.Respond(c, http.StatusForbidden, "your wallet role does not allow this action")
+	}
+
+	found, err := h.changeMember(ctx, a, allowed, memberID, true, dMemberStmt, id, memberID)
+	if herr := changeError(err); herr != nil {
+		return apierror.Write(c, herr)
+	}
+	if errors.Is(err, errLastOwner) {
+		return apierror.Respond(c, http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		logger.Error("remove member error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !found {
+		return apierror.Respond(c, http.StatusNotFound, "member not found")
+	}
+
+	logger.Info("remove member successfully", zap.Int64("wallet_id", id), zap.Int64("spender_id", memberID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+var (
+	errLastOwner = errors.New("a wallet must keep at least one owner")
+	errNotMember = errors.New("not a member")
+	errRole      = errors.New("role not allowed")
+)
+
+// changeError is the response to the actor having left the wallet or lost
+// the role since authorize, nil for other errors.
+func changeError(err error) *echo.HTTPError {
+	switch {
+	case errors.Is(err, errNotMember):
+		return echo.NewHTTPError(http.StatusNotFound, "wallet not found")
+	case errors.Is(err, errRole):
+		return echo.NewHTTPError(http.StatusForbidden, "your wallet role does not allow this action")
+	}
+	return nil
+}
+
+// changeMember runs stmt on the member, demotes tells whether it makes them
+// stop being an owner. The actor and the owners are locked, the actor's
+// role checked again with allowed, so a role changed since authorize
+// counts, and two demotions can't both leave the wallet without an owner.
+// It reports false when the member does not exist.
+func (h handler) changeMember(ctx context.Context, a access, allowed func(string) bool, memberID int64, demotes bool, stmt string, args ...any) (bool, error) {
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, lockStmt, a.walletID, a.actor)
+	if err != nil {
+		return false, err
+	}
+	var (
+		owners []int64
+		role   string
+	)
+	for rows.Next() {
+		var (
+			id int64
+			r  string
+		)
+		if err := rows.Scan(&id, &r); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if id == a.actor {
+			role = r
+		}
+		if r == RoleOwner {
+			owners = append(owners, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if role == "" {
+		return false, errNotMember
+	}
+	if !allowed(role) {
+		return false, errRole
+	}
+	if demotes && slices.Contains(owners, memberID) && len(owners) == 1 {
+		return false, errLastOwner
+	}
+
+	res, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GET /api/v1/wallets/:id/summary
+// Totals are in the home currency of the member asking, the accounts in
+// other currencies are left out.
+func (h handler) GetSummary(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canView)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+
+	prefs, err := spender.LoadPreferences(ctx, h.db, a.actor)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	summary, err := h.summary.GetTransactionSummaryByWalletId(ctx, strconv.FormatInt(a.walletID, 10), prefs.Currency)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	summary.Currency = prefs.Currency
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// GET /api/v1/wallets/:id/summary/categories
+// In the same currency as the summary.
+func (h handler) GetCategorySummary(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canView)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+
+	prefs, err := spender.LoadPreferences(ctx, h.db, a.actor)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	categories, err := h.summary.GetCategorySummaryByWalletId(ctx, strconv.FormatInt(a.walletID, 10), prefs.Currency)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	return c.JSON(http.StatusOK, categories)
+}
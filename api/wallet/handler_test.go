@@ -0,0 +1,339 @@
+package wallet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var lockColumns = []string{"spender_id", "role"}
+
+// asSpender signs req in as the spender id, as audit.Middleware does for
+// requests from the gateway.
+func asSpender(req *http.Request, id string) *http.Request {
+	return req.WithContext(audit.WithActor(req.Context(), signedIn(id), ""))
+}
+
+func signedIn(id string) audit.Actor {
+	return audit.Actor{Type: audit.Spender, ID: id}
+}
+
+// stubSummary only has totals in currency.
+type stubSummary struct {
+	summary  transaction.TransactionSummary
+	currency string
+}
+
+func (s stubSummary) GetTransactionSummaryByWalletId(ctx context.Context, id string, currency string) (transaction.TransactionSummary, error) {
+	if currency != s.currency {
+		return transaction.TransactionSummary{}, nil
+	}
+	return s.summary, nil
+}
+
+func (s stubSummary) GetCategorySummaryByWalletId(ctx context.Context, id string, currency string) ([]transaction.CategorySummary, error) {
+	return nil, nil
+}
+
+func TestCreateWallet(t *testing.T) {
+	t.Run("create wallet and make the creator its owner", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Family"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		audittest.ExpectBegin(mock, signedIn("1"))
+		mock.ExpectQuery(cStmt).WithArgs("Family").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, created))
+		mock.ExpectExec(cMemberStmt).WithArgs(int64(3), int64(1), RoleOwner).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 3, "name": "Family", "created_at": "2024-05-01T00:00:00Z", "role": "owner", "members": [{"spender_id": 1, "role": "owner"}]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("create wallet requires the acting spender", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Family"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil, stubSummary{})
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("a spender header the gateway didn't vouch for is not enough", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Family"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Spender-ID", "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil, stubSummary{})
+		err := audit.Middleware("gateway-secret")(h.Create)(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("an API key is not a spender", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "Family"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = req.WithContext(audit.WithActor(req.Context(), audit.Actor{Type: audit.APIKey, ID: "1"}, ""))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil, stubSummary{})
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestWalletSummary(t *testing.T) {
+	t.Run("members see the combined summary in their home currency", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = asSpender(req, "2")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleViewer))
+		mock.ExpectQuery(`SELECT timezone, currency, locale, week_start, month_start_day FROM spender_preference WHERE spender_id = $1`).WithArgs(int64(2)).
+			WillReturnRows(sqlmock.NewRows([]string{"timezone", "currency", "locale", "week_start", "month_start_day"}).AddRow("Asia/Tokyo", "JPY", "ja-JP", "sunday", 1))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{transaction.TransactionSummary{TotalIncome: 5000, TotalExpenses: 1200, CurrentBalance: 3800}, "JPY"})
+		err := h.GetSummary(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_income": 5000, "total_expenses": 1200, "current_balance": 3800, "currency": "JPY"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("non members get not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = asSpender(req, "9")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(9)).WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.GetSummary(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestGetBySpenderID(t *testing.T) {
+	t.Run("a spender lists their own wallets", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := asSpender(httptest.NewRequest(http.MethodGet, "/", nil), "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		at := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(bySpender).WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "role"}).AddRow(3, "Home", at, RoleOwner))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id": 3, "name": "Home", "created_at": "2024-06-01T00:00:00Z", "role": "owner"}]`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("another spender's wallets are not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := asSpender(httptest.NewRequest(http.MethodGet, "/", nil), "2")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.GetBySpenderID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateMember(t *testing.T) {
+	t.Run("viewer cannot change roles", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "editor"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "2")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "spender_id")
+		c.SetParamValues("3", "2")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleViewer))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.UpdateMember(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("last owner cannot be demoted", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "viewer"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "spender_id")
+		c.SetParamValues("3", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleOwner))
+		audittest.ExpectBegin(mock, signedIn("1"))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(1, RoleOwner))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.UpdateMember(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("an owner demoted meanwhile cannot change roles", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "owner"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "spender_id")
+		c.SetParamValues("3", "4")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleOwner))
+		audittest.ExpectBegin(mock, signedIn("1"))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(1, RoleViewer).AddRow(2, RoleOwner))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.UpdateMember(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRemoveMember(t *testing.T) {
+	t.Run("an owner leaves while another one stays", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "spender_id")
+		c.SetParamValues("3", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleOwner))
+		audittest.ExpectBegin(mock, signedIn("1"))
+		mock.ExpectQuery(lockStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows(lockColumns).AddRow(1, RoleOwner).AddRow(2, RoleOwner))
+		mock.ExpectExec(dMemberStmt).WithArgs(int64(3), int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.RemoveMember(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
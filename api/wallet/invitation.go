@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/apierror"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	invitationTTL = 7 * 24 * time.Hour
+
+	cInviteStmt  = `INSERT INTO wallet_invitation (token, wallet_id, email, role, invited_by, expires_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	getInvite    = `SELECT wallet_id, email, role, expires_at, accepted_at FROM wallet_invitation WHERE token = $1 FOR UPDATE`
+	spenderEmail = `SELECT email FROM spender WHERE id = $1 AND deleted_at IS NULL`
+	// an owner accepting an invitation to their own wallet keeps the owner role
+	upsertMember = `INSERT INTO wallet_member (wallet_id, spender_id, role) VALUES ($1, $2, $3) ON CONFLICT (wallet_id, spender_id) DO UPDATE SET role = EXCLUDED.role WHERE wallet_member.role <> 'owner';`
+	acceptInvite = `UPDATE wallet_invitation SET accepted_at = $1 WHERE token = $2;`
+)
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// POST /api/v1/wallets/:id/invitations
+// The token is meant to be delivered by email, the invitee accepts it with
+// their own spender id.
+func (h handler) Invite(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	a, herr := h.authorize(c, canManage)
+	if herr != nil {
+		return apierror.Write(c, herr)
+	}
+
+	var inv Invitation
+	if err := c.Bind(&inv); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return apierror.Invalid(c, err)
+	}
+	if _, err := mail.ParseAddress(inv.Email); err != nil {
+		return apierror.Respond(c, http.StatusBadRequest, "invalid email")
+	}
+	if inv.Role != RoleEditor && inv.Role != RoleViewer {
+		return apierror.Respond(c, http.StatusBadRequest, "role must be editor or viewer")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		logger.Error("token error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	inv.Token = token
+	inv.WalletID = a.walletID
+	inv.InvitedBy = a.actor
+	inv.ExpiresAt = time.Now().Add(invitationTTL).UTC()
+
+	if _, err := h.db.ExecContext(ctx, cInviteStmt, inv.Token, inv.WalletID, inv.Email, inv.Role, inv.InvitedBy, inv.ExpiresAt); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("invitation created", zap.Int64("wallet_id", inv.WalletID))
+	return c.JSON(http.StatusCreated, inv)
+}
+
+// POST /api/v1/wallets/invitations/:token/accept
+func (h handler) Accept(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	actor, err := actorID(c)
+	if err != nil {
+		return apierror.Respond(c, http.StatusUnauthorized, err.Error())
+	}
+
+	tx, err := audit.BeginTx(ctx, h.db)
+	if err != nil {
+		logger.Error("begin error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	defer tx.Rollback()
+
+	var inv Invitation
+	var acceptedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, getInvite, c.Param("token")).Scan(&inv.WalletID, &inv.Email, &inv.Role, &inv.ExpiresAt, &acceptedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.Respond(c, http.StatusNotFound, "invitation not found")
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if acceptedAt.Valid {
+		return apierror.Respond(c, http.StatusConflict, "invitation already accepted")
+	}
+	now := time.Now()
+	if now.After(inv.ExpiresAt) {
+		return apierror.Respond(c, http.StatusGone, "invitation expired")
+	}
+
+	var email string
+	if err := tx.QueryRowContext(ctx, spenderEmail, actor).Scan(&email); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("query row error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if !strings.EqualFold(strings.TrimSpace(email), strings.TrimSpace(inv.Email)) {
+		return apierror.Respond(c, http.StatusForbidden, "invitation was sent to another email")
+	}
+
+	if _, err := tx.ExecContext(ctx, upsertMember, inv.WalletID, actor, inv.Role); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if _, err := tx.ExecContext(ctx, acceptInvite, now, c.Param("token")); err != nil {
+		logger.Error("exec error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return apierror.Internal(c)
+	}
+
+	logger.Info("invitation accepted", zap.Int64("wallet_id", inv.WalletID), zap.Int64("spender_id", actor))
+	return c.JSON(http.StatusOK, Member{SpenderID: actor, Email: email, Role: inv.Role})
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit/audittest"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvite(t *testing.T) {
+	t.Run("owner invites by email", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "mom@jot.ok", "role": "editor"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleOwner))
+		mock.ExpectExec(cInviteStmt).WithArgs(sqlmock.AnyArg(), int64(3), "mom@jot.ok", RoleEditor, int64(1), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.Invite(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"token":"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject owner role in invitations", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "mom@jot.ok", "role": "owner"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = asSpender(req, "1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(roleStmt).WithArgs(int64(3), int64(1)).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleOwner))
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.Invite(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAccept(t *testing.T) {
+	t.Run("invitee joins the wallet", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = asSpender(req, "2")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("token")
+		c.SetParamValues("abc")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, signedIn("2"))
+		mock.ExpectQuery(getInvite).WithArgs("abc").WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "email", "role", "expires_at", "accepted_at"}).
+			AddRow(3, "Mom@Jot.ok", RoleEditor, time.Now().Add(time.Hour), nil))
+		mock.ExpectQuery(spenderEmail).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("mom@jot.ok"))
+		mock.ExpectExec(upsertMember).WithArgs(int64(3), int64(2), RoleEditor).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(acceptInvite).WithArgs(sqlmock.AnyArg(), "abc").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.Accept(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"spender_id": 2, "email": "mom@jot.ok", "role": "editor"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invitation for another email is refused", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = asSpender(req, "4")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("token")
+		c.SetParamValues("abc")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		audittest.ExpectBegin(mock, signedIn("4"))
+		mock.ExpectQuery(getInvite).WithArgs("abc").WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "email", "role", "expires_at", "accepted_at"}).
+			AddRow(3, "mom@jot.ok", RoleEditor, time.Now().Add(time.Hour), nil))
+		mock.ExpectQuery(spenderEmail).WithArgs(int64(4)).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("kid@jot.ok"))
+		mock.ExpectRollback()
+
+		h := New(config.FeatureFlag{}, db, stubSummary{})
+		err := h.Accept(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
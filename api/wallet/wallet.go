@@ -0,0 +1,71 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Wallet is shared by several spenders who record expenses into it and see
+// a combined summary.
+type Wallet struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role,omitempty"`
+	Members   []Member  `json:"members,omitempty"`
+}
+
+type Member struct {
+	SpenderID int64  `json:"spender_id"`
+	Name      string `json:"name,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Role      string `json:"role"`
+}
+
+type Invitation struct {
+	Token     string    `json:"token"`
+	WalletID  int64     `json:"wallet_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy int64     `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var errNoActor = errors.New("only a signed in spender can act on wallets")
+
+// actorID is the spender the gateway signed in for the request, see
+// audit.Middleware. Admins, API keys and anonymous callers have none.
+func actorID(c echo.Context) (int64, error) {
+	a := audit.ActorOf(c.Request().Context())
+	if a.Type != audit.Spender {
+		return 0, errNoActor
+	}
+	id, err := strconv.ParseInt(a.ID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errNoActor
+	}
+	return id, nil
+}
+
+func validRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// canView is true for every member, an empty role means not a member.
+func canView(role string) bool {
+	return validRole(role)
+}
+
+func canManage(role string) bool {
+	return role == RoleOwner
+}
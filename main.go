@@ -10,6 +10,8 @@ import (
 
 	"github.com/KKGo-Software-engineering/workshop-summer/api"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/recurring"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/suggest"
 	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/gommon/log"
 	_ "github.com/lib/pq"
@@ -47,6 +49,11 @@ func main() {
 	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	// The scheduler stops with the interrupt signal, replicas coordinate through an advisory lock.
+	go recurring.NewScheduler(db, logger, time.Minute).Run(sig)
+	// Category models catch up with new and edited transactions.
+	go suggest.NewTrainer(db, logger, time.Minute).Run(sig)
+
 	<-sig.Done()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
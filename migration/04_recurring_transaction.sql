@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "recurring_transaction" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL,
+  amount DECIMAL(10,2) DEFAULT 0,
+  category VARCHAR(50) DEFAULT '',
+  transaction_type VARCHAR(20) DEFAULT '',
+  note VARCHAR(255) DEFAULT '',
+  frequency VARCHAR(10) NOT NULL,
+  interval_count INT NOT NULL DEFAULT 1,
+  day_of_month INT NOT NULL DEFAULT 0,
+  start_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  end_date TIMESTAMP WITH TIME ZONE,
+  count INT NOT NULL DEFAULT 0,
+  occurrences INT NOT NULL DEFAULT 0,
+  next_run TIMESTAMP WITH TIME ZONE,
+  active BOOLEAN NOT NULL DEFAULT TRUE
+);
+CREATE INDEX IF NOT EXISTS recurring_transaction_next_run_idx ON "recurring_transaction" (next_run) WHERE active;
+
+ALTER TABLE "transaction" ADD COLUMN recurring_id INT REFERENCES "recurring_transaction" (id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_recurring_occurrence_idx ON "transaction" (recurring_id, date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_recurring_occurrence_idx;
+ALTER TABLE "transaction" DROP COLUMN recurring_id;
+DROP TABLE IF EXISTS "recurring_transaction";
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "transaction_split" (
+  id SERIAL PRIMARY KEY,
+  transaction_id INT NOT NULL REFERENCES "transaction" (id) ON DELETE CASCADE,
+  category VARCHAR(50) NOT NULL,
+  amount DECIMAL(10,2) NOT NULL,
+  note VARCHAR(255) DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS transaction_split_transaction_id_idx ON "transaction_split" (transaction_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "transaction_split";
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "wallet" (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS "wallet_member" (
+  wallet_id INT NOT NULL REFERENCES "wallet" (id) ON DELETE CASCADE,
+  spender_id INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+  role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+  PRIMARY KEY (wallet_id, spender_id)
+);
+CREATE INDEX IF NOT EXISTS wallet_member_spender_id_idx ON "wallet_member" (spender_id);
+
+CREATE TABLE IF NOT EXISTS "wallet_invitation" (
+  token VARCHAR(64) PRIMARY KEY,
+  wallet_id INT NOT NULL REFERENCES "wallet" (id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  role VARCHAR(10) NOT NULL CHECK (role IN ('editor', 'viewer')),
+  invited_by INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  accepted_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE "transaction" ADD COLUMN wallet_id INT REFERENCES "wallet" (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS transaction_wallet_id_idx ON "transaction" (wallet_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP COLUMN wallet_id;
+DROP TABLE IF EXISTS "wallet_invitation";
+DROP TABLE IF EXISTS "wallet_member";
+DROP TABLE IF EXISTS "wallet";
+-- +goose StatementEnd
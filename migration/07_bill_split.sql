@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "transaction_share" (
+  transaction_id INT NOT NULL REFERENCES "transaction" (id) ON DELETE CASCADE,
+  spender_id INT NOT NULL REFERENCES "spender" (id),
+  amount DECIMAL(10,2) NOT NULL,
+  PRIMARY KEY (transaction_id, spender_id)
+);
+CREATE INDEX IF NOT EXISTS transaction_share_spender_id_idx ON "transaction_share" (spender_id);
+
+CREATE TABLE IF NOT EXISTS "settlement" (
+  id SERIAL PRIMARY KEY,
+  from_spender_id INT NOT NULL REFERENCES "spender" (id),
+  to_spender_id INT NOT NULL REFERENCES "spender" (id),
+  amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+  note VARCHAR(255) DEFAULT '',
+  date TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  CHECK (from_spender_id <> to_spender_id)
+);
+
+-- Every share of somebody else's payment is a debt to the payer, a
+-- settlement from A to B is booked as B owing A so it cancels out.
+CREATE OR REPLACE VIEW "debt_ledger" AS
+  SELECT s.spender_id AS debtor_id, t.spender_id AS creditor_id, s.amount, t.id AS transaction_id, NULL::INT AS settlement_id, t.date
+  FROM "transaction_share" s JOIN "transaction" t ON t.id = s.transaction_id
+  WHERE s.spender_id <> t.spender_id
+  UNION ALL
+  SELECT to_spender_id, from_spender_id, amount, NULL, id, date
+  FROM "settlement";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS "debt_ledger";
+DROP TABLE IF EXISTS "settlement";
+DROP TABLE IF EXISTS "transaction_share";
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "account" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  type VARCHAR(20) NOT NULL CHECK (type IN ('cash', 'bank', 'credit_card')),
+  currency CHAR(3) NOT NULL DEFAULT 'THB',
+  opening_balance DECIMAL(12,2) NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS account_spender_id_idx ON "account" (spender_id);
+
+ALTER TABLE "transaction" ADD COLUMN account_id INT REFERENCES "account" (id) ON DELETE SET NULL;
+ALTER TABLE "transaction" ADD COLUMN to_account_id INT REFERENCES "account" (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS transaction_account_id_idx ON "transaction" (account_id);
+CREATE INDEX IF NOT EXISTS transaction_to_account_id_idx ON "transaction" (to_account_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP COLUMN to_account_id;
+ALTER TABLE "transaction" DROP COLUMN account_id;
+DROP TABLE IF EXISTS "account";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "transaction" ADD COLUMN external_id VARCHAR(255);
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_spender_external_id_key UNIQUE (spender_id, external_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP CONSTRAINT transaction_spender_external_id_key;
+ALTER TABLE "transaction" DROP COLUMN external_id;
+-- +goose StatementEnd
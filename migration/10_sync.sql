@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE IF NOT EXISTS "sync_seq";
+
+ALTER TABLE "transaction" ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+ALTER TABLE "transaction" ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "transaction" ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE "transaction" ADD COLUMN sync_seq BIGINT NOT NULL DEFAULT nextval('sync_seq');
+CREATE INDEX IF NOT EXISTS transaction_spender_sync_seq_idx ON "transaction" (spender_id, sync_seq);
+
+ALTER TABLE "spender" ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+ALTER TABLE "spender" ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "spender" ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE "spender" ADD COLUMN sync_seq BIGINT NOT NULL DEFAULT nextval('sync_seq');
+
+-- Every write moves the row to the end of the change feed, whichever
+-- code path made it.
+CREATE OR REPLACE FUNCTION touch_sync() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := now();
+  NEW.version := OLD.version + 1;
+  NEW.sync_seq := nextval('sync_seq');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transaction_touch_sync BEFORE UPDATE ON "transaction" FOR EACH ROW EXECUTE FUNCTION touch_sync();
+CREATE TRIGGER spender_touch_sync BEFORE UPDATE ON "spender" FOR EACH ROW EXECUTE FUNCTION touch_sync();
+
+-- Deleted transactions are tombstones now, they no longer create debts.
+CREATE OR REPLACE VIEW "debt_ledger" AS
+  SELECT s.spender_id AS debtor_id, t.spender_id AS creditor_id, s.amount, t.id AS transaction_id, NULL::INT AS settlement_id, t.date
+  FROM "transaction_share" s JOIN "transaction" t ON t.id = s.transaction_id
+  WHERE s.spender_id <> t.spender_id AND t.deleted_at IS NULL
+  UNION ALL
+  SELECT to_spender_id, from_spender_id, amount, NULL, id, date
+  FROM "settlement";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE VIEW "debt_ledger" AS
+  SELECT s.spender_id AS debtor_id, t.spender_id AS creditor_id, s.amount, t.id AS transaction_id, NULL::INT AS settlement_id, t.date
+  FROM "transaction_share" s JOIN "transaction" t ON t.id = s.transaction_id
+  WHERE s.spender_id <> t.spender_id
+  UNION ALL
+  SELECT to_spender_id, from_spender_id, amount, NULL, id, date
+  FROM "settlement";
+
+DROP TRIGGER IF EXISTS spender_touch_sync ON "spender";
+DROP TRIGGER IF EXISTS transaction_touch_sync ON "transaction";
+DROP FUNCTION IF EXISTS touch_sync();
+
+ALTER TABLE "spender" DROP COLUMN sync_seq;
+ALTER TABLE "spender" DROP COLUMN version;
+ALTER TABLE "spender" DROP COLUMN deleted_at;
+ALTER TABLE "spender" DROP COLUMN updated_at;
+
+DROP INDEX IF EXISTS transaction_spender_sync_seq_idx;
+ALTER TABLE "transaction" DROP COLUMN sync_seq;
+ALTER TABLE "transaction" DROP COLUMN version;
+ALTER TABLE "transaction" DROP COLUMN deleted_at;
+ALTER TABLE "transaction" DROP COLUMN updated_at;
+
+DROP SEQUENCE IF EXISTS "sync_seq";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS transaction_spender_date_id_idx ON "transaction" (spender_id, date DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS transaction_date_id_idx ON "transaction" (date DESC, id DESC) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_date_id_idx;
+DROP INDEX IF EXISTS transaction_spender_date_id_idx;
+-- +goose StatementEnd
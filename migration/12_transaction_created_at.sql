@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "transaction" ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS transaction_spender_created_at_id_idx ON "transaction" (spender_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_spender_created_at_id_idx;
+ALTER TABLE "transaction" DROP COLUMN created_at;
+-- +goose StatementEnd
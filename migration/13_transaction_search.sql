@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE "transaction" ADD COLUMN merchant VARCHAR(255) NOT NULL DEFAULT '';
+
+-- 'simple' keeps words as written so Thai and English index the same way,
+-- merchant names rank above categories and notes.
+ALTER TABLE "transaction" ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', coalesce(merchant, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(category, '')), 'B') ||
+  setweight(to_tsvector('simple', coalesce(note, '')), 'C')
+) STORED;
+
+-- Thai is written without spaces between words, trigrams find a word
+-- inside a run of text where the tsvector only has the whole run.
+ALTER TABLE "transaction" ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+  lower(coalesce(merchant, '') || ' ' || coalesce(category, '') || ' ' || coalesce(note, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS transaction_search_vector_idx ON "transaction" USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS transaction_search_text_idx ON "transaction" USING GIN (search_text gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_search_text_idx;
+DROP INDEX IF EXISTS transaction_search_vector_idx;
+ALTER TABLE "transaction" DROP COLUMN search_text;
+ALTER TABLE "transaction" DROP COLUMN search_vector;
+ALTER TABLE "transaction" DROP COLUMN merchant;
+-- +goose StatementEnd
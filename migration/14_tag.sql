@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "tag" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+  name VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  UNIQUE (spender_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS "transaction_tag" (
+  transaction_id INT NOT NULL REFERENCES "transaction" (id) ON DELETE CASCADE,
+  tag_id INT NOT NULL REFERENCES "tag" (id) ON DELETE CASCADE,
+  PRIMARY KEY (transaction_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS transaction_tag_tag_id_idx ON "transaction_tag" (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "transaction_tag";
+DROP TABLE IF EXISTS "tag";
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "merchant" (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL UNIQUE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS "merchant_alias" (
+  alias VARCHAR(255) PRIMARY KEY,
+  merchant_id INT NOT NULL REFERENCES "merchant" (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS merchant_alias_merchant_id_idx ON "merchant_alias" (merchant_id);
+
+CREATE TABLE IF NOT EXISTS "merchant_rule" (
+  id SERIAL PRIMARY KEY,
+  spender_id INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+  match VARCHAR(10) NOT NULL DEFAULT 'contains' CHECK (match IN ('contains', 'equals', 'prefix')),
+  pattern VARCHAR(255) NOT NULL,
+  category VARCHAR(50) NOT NULL DEFAULT '',
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  priority INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS merchant_rule_spender_id_idx ON "merchant_rule" (spender_id, priority DESC, id);
+
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS merchant_id INT REFERENCES "merchant" (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS transaction_merchant_id_idx ON "transaction" (merchant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS merchant_id;
+DROP TABLE IF EXISTS "merchant_rule";
+DROP TABLE IF EXISTS "merchant_alias";
+DROP TABLE IF EXISTS "merchant";
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "category_model" (
+  spender_id INT PRIMARY KEY REFERENCES "spender" (id) ON DELETE CASCADE,
+  trained_seq BIGINT NOT NULL DEFAULT 0,
+  model JSONB NOT NULL,
+  trained_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "category_model";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Spenders sharing an email have to be merged or soft-deleted first,
+-- deleted ones free their email for a new spender.
+CREATE UNIQUE INDEX IF NOT EXISTS spender_email_key ON "spender" (lower(email)) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS spender_email_key;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS spender_name_trgm_idx ON "spender" USING GIN (name gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS spender_email_trgm_idx ON "spender" USING GIN (email gin_trgm_ops) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS spender_email_trgm_idx;
+DROP INDEX IF EXISTS spender_name_trgm_idx;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "spender_preference" (
+  spender_id INT PRIMARY KEY REFERENCES "spender" (id) ON DELETE CASCADE,
+  timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  currency CHAR(3) NOT NULL DEFAULT 'THB',
+  locale VARCHAR(35) NOT NULL DEFAULT 'th-TH',
+  week_start VARCHAR(9) NOT NULL DEFAULT 'monday',
+  month_start_day SMALLINT NOT NULL DEFAULT 1 CHECK (month_start_day BETWEEN 1 AND 28)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "spender_preference";
+-- +goose StatementEnd
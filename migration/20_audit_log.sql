@@ -0,0 +1,119 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "audit_log" (
+  id BIGSERIAL PRIMARY KEY,
+  actor_type VARCHAR(20) NOT NULL,
+  actor_id VARCHAR(64) NOT NULL DEFAULT '',
+  action VARCHAR(20) NOT NULL,
+  entity_type VARCHAR(64) NOT NULL,
+  entity_id VARCHAR(64) NOT NULL,
+  before JSONB,
+  after JSONB,
+  request_id VARCHAR(64) NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON "audit_log" (entity_type, entity_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON "audit_log" (actor_type, actor_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_request_idx ON "audit_log" (request_id);
+
+-- The log is append-only, whoever the database user is.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON "audit_log" FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- Every write to an audited table is logged in the same DB transaction,
+-- whichever code path made it. The application says who made it with
+-- set_config('audit.actor_type', ...) local to its transaction, writes it
+-- didn't attribute are logged as unknown.
+--
+-- The first trigger argument is the column identifying the entity, child
+-- rows like splits name their transaction so they show in its history.
+-- Updates keep only the fields that changed, bookkeeping columns left out.
+CREATE OR REPLACE FUNCTION audit_row() RETURNS trigger AS $$
+DECLARE
+  old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+  new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+  noise TEXT[] := ARRAY['updated_at', 'version', 'sync_seq', 'search_vector', 'search_text'];
+  act TEXT := lower(TG_OP);
+  k TEXT;
+BEGIN
+  old_row := old_row - noise;
+  new_row := new_row - noise;
+
+  IF TG_OP = 'UPDATE' THEN
+    FOR k IN SELECT jsonb_object_keys(new_row) LOOP
+      IF old_row -> k = new_row -> k THEN
+        old_row := old_row - k;
+        new_row := new_row - k;
+      END IF;
+    END LOOP;
+    IF new_row = '{}'::jsonb THEN
+      RETURN NULL;
+    END IF;
+    IF new_row ? 'deleted_at' THEN
+      act := CASE WHEN new_row -> 'deleted_at' = 'null'::jsonb THEN 'restore' ELSE 'delete' END;
+    END IF;
+  ELSIF TG_OP = 'INSERT' THEN
+    act := 'create';
+  END IF;
+
+  INSERT INTO audit_log (actor_type, actor_id, action, entity_type, entity_id, before, after, request_id)
+  VALUES (
+    COALESCE(NULLIF(current_setting('audit.actor_type', true), ''), 'unknown'),
+    COALESCE(current_setting('audit.actor_id', true), ''),
+    act,
+    TG_TABLE_NAME,
+    COALESCE(new_row, old_row) ->> TG_ARGV[0],
+    old_row,
+    new_row,
+    COALESCE(current_setting('audit.request_id', true), '')
+  );
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transaction_audit AFTER INSERT OR UPDATE OR DELETE ON "transaction" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER transaction_split_audit AFTER INSERT OR UPDATE OR DELETE ON "transaction_split" FOR EACH ROW EXECUTE FUNCTION audit_row('transaction_id');
+CREATE TRIGGER transaction_share_audit AFTER INSERT OR UPDATE OR DELETE ON "transaction_share" FOR EACH ROW EXECUTE FUNCTION audit_row('transaction_id');
+CREATE TRIGGER transaction_tag_audit AFTER INSERT OR UPDATE OR DELETE ON "transaction_tag" FOR EACH ROW EXECUTE FUNCTION audit_row('transaction_id');
+CREATE TRIGGER spender_audit AFTER INSERT OR UPDATE OR DELETE ON "spender" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER spender_preference_audit AFTER INSERT OR UPDATE OR DELETE ON "spender_preference" FOR EACH ROW EXECUTE FUNCTION audit_row('spender_id');
+CREATE TRIGGER recurring_transaction_audit AFTER INSERT OR UPDATE OR DELETE ON "recurring_transaction" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER wallet_audit AFTER INSERT OR UPDATE OR DELETE ON "wallet" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER wallet_member_audit AFTER INSERT OR UPDATE OR DELETE ON "wallet_member" FOR EACH ROW EXECUTE FUNCTION audit_row('wallet_id');
+CREATE TRIGGER settlement_audit AFTER INSERT OR UPDATE OR DELETE ON "settlement" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER account_audit AFTER INSERT OR UPDATE OR DELETE ON "account" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER tag_audit AFTER INSERT OR UPDATE OR DELETE ON "tag" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER merchant_audit AFTER INSERT OR UPDATE OR DELETE ON "merchant" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+CREATE TRIGGER merchant_alias_audit AFTER INSERT OR UPDATE OR DELETE ON "merchant_alias" FOR EACH ROW EXECUTE FUNCTION audit_row('merchant_id');
+CREATE TRIGGER merchant_rule_audit AFTER INSERT OR UPDATE OR DELETE ON "merchant_rule" FOR EACH ROW EXECUTE FUNCTION audit_row('id');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS merchant_rule_audit ON "merchant_rule";
+DROP TRIGGER IF EXISTS merchant_alias_audit ON "merchant_alias";
+DROP TRIGGER IF EXISTS merchant_audit ON "merchant";
+DROP TRIGGER IF EXISTS tag_audit ON "tag";
+DROP TRIGGER IF EXISTS account_audit ON "account";
+DROP TRIGGER IF EXISTS settlement_audit ON "settlement";
+DROP TRIGGER IF EXISTS wallet_member_audit ON "wallet_member";
+DROP TRIGGER IF EXISTS wallet_audit ON "wallet";
+DROP TRIGGER IF EXISTS recurring_transaction_audit ON "recurring_transaction";
+DROP TRIGGER IF EXISTS spender_preference_audit ON "spender_preference";
+DROP TRIGGER IF EXISTS spender_audit ON "spender";
+DROP TRIGGER IF EXISTS transaction_tag_audit ON "transaction_tag";
+DROP TRIGGER IF EXISTS transaction_share_audit ON "transaction_share";
+DROP TRIGGER IF EXISTS transaction_split_audit ON "transaction_split";
+DROP TRIGGER IF EXISTS transaction_audit ON "transaction";
+DROP FUNCTION IF EXISTS audit_row();
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON "audit_log";
+DROP FUNCTION IF EXISTS audit_log_append_only();
+DROP TABLE IF EXISTS "audit_log";
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The transaction as it was before an edit replaced it, version is the
+-- transaction's version column at the time. Splits and shares are kept in
+-- data with the row so a revert restores all of it.
+CREATE TABLE IF NOT EXISTS "transaction_version" (
+  transaction_id INT NOT NULL REFERENCES "transaction" (id) ON DELETE CASCADE,
+  version INT NOT NULL,
+  data JSONB NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  PRIMARY KEY (transaction_id, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "transaction_version";
+-- +goose StatementEnd
@@ -0,0 +1,75 @@
+-- +goose Up
+-- +goose StatementBegin
+-- sync_seq is drawn when a row is written, not when its DB transaction
+-- commits, so a row can become visible after rows with a greater seq. The
+-- feed orders by the id of the writing transaction too and only returns
+-- rows written before the oldest transaction still running, those can't
+-- be joined by late commits.
+ALTER TABLE "transaction" ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE "spender" ADD COLUMN sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+DROP INDEX IF EXISTS transaction_spender_sync_seq_idx;
+CREATE INDEX IF NOT EXISTS transaction_spender_sync_idx ON "transaction" (spender_id, sync_xid, sync_seq);
+
+CREATE OR REPLACE FUNCTION touch_sync() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := now();
+  NEW.version := OLD.version + 1;
+  NEW.sync_xid := pg_current_xact_id();
+  NEW.sync_seq := nextval('sync_seq');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A transaction moved to another spender leaves the previous one's feed,
+-- the tombstone tells their devices to drop it.
+CREATE TABLE IF NOT EXISTS "sync_tombstone" (
+  spender_id INT NOT NULL,
+  entity VARCHAR(20) NOT NULL,
+  entity_id INT NOT NULL,
+  version INT NOT NULL,
+  deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+  sync_seq BIGINT NOT NULL DEFAULT nextval('sync_seq')
+);
+CREATE INDEX IF NOT EXISTS sync_tombstone_spender_sync_idx ON "sync_tombstone" (spender_id, sync_xid, sync_seq);
+
+CREATE OR REPLACE FUNCTION tombstone_moved() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO sync_tombstone (spender_id, entity, entity_id, version) VALUES (OLD.spender_id, TG_TABLE_NAME, OLD.id, NEW.version);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transaction_tombstone_moved AFTER UPDATE OF spender_id ON "transaction" FOR EACH ROW
+  WHEN (OLD.spender_id IS DISTINCT FROM NEW.spender_id AND OLD.spender_id IS NOT NULL AND OLD.deleted_at IS NULL)
+  EXECUTE FUNCTION tombstone_moved();
+
+-- Creates pushed by offline clients carry an id the client made up, a
+-- retried push finds the row it created the first time.
+ALTER TABLE "transaction" ADD COLUMN client_id UUID;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_spender_client_id_key ON "transaction" (spender_id, client_id) WHERE client_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_spender_client_id_key;
+ALTER TABLE "transaction" DROP COLUMN client_id;
+
+DROP TRIGGER IF EXISTS transaction_tombstone_moved ON "transaction";
+DROP FUNCTION IF EXISTS tombstone_moved();
+DROP TABLE IF EXISTS "sync_tombstone";
+
+CREATE OR REPLACE FUNCTION touch_sync() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := now();
+  NEW.version := OLD.version + 1;
+  NEW.sync_seq := nextval('sync_seq');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS transaction_spender_sync_idx;
+CREATE INDEX IF NOT EXISTS transaction_spender_sync_seq_idx ON "transaction" (spender_id, sync_seq);
+ALTER TABLE "spender" DROP COLUMN sync_xid;
+ALTER TABLE "transaction" DROP COLUMN sync_xid;
+-- +goose StatementEnd
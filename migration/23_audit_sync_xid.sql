@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+-- sync_xid changes with every write like sync_seq, it is left out of the
+-- audit log too.
+CREATE OR REPLACE FUNCTION audit_row() RETURNS trigger AS $$
+DECLARE
+  old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+  new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+  noise TEXT[] := ARRAY['updated_at', 'version', 'sync_seq', 'sync_xid', 'search_vector', 'search_text'];
+  act TEXT := lower(TG_OP);
+  k TEXT;
+BEGIN
+  old_row := old_row - noise;
+  new_row := new_row - noise;
+
+  IF TG_OP = 'UPDATE' THEN
+    FOR k IN SELECT jsonb_object_keys(new_row) LOOP
+      IF old_row -> k = new_row -> k THEN
+        old_row := old_row - k;
+        new_row := new_row - k;
+      END IF;
+    END LOOP;
+    IF new_row = '{}'::jsonb THEN
+      RETURN NULL;
+    END IF;
+    IF new_row ? 'deleted_at' THEN
+      act := CASE WHEN new_row -> 'deleted_at' = 'null'::jsonb THEN 'restore' ELSE 'delete' END;
+    END IF;
+  ELSIF TG_OP = 'INSERT' THEN
+    act := 'create';
+  END IF;
+
+  INSERT INTO audit_log (actor_type, actor_id, action, entity_type, entity_id, before, after, request_id)
+  VALUES (
+    COALESCE(NULLIF(current_setting('audit.actor_type', true), ''), 'unknown'),
+    COALESCE(current_setting('audit.actor_id', true), ''),
+    act,
+    TG_TABLE_NAME,
+    COALESCE(new_row, old_row) ->> TG_ARGV[0],
+    old_row,
+    new_row,
+    COALESCE(current_setting('audit.request_id', true), '')
+  );
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_row() RETURNS trigger AS $$
+DECLARE
+  old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+  new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+  noise TEXT[] := ARRAY['updated_at', 'version', 'sync_seq', 'search_vector', 'search_text'];
+  act TEXT := lower(TG_OP);
+  k TEXT;
+BEGIN
+  old_row := old_row - noise;
+  new_row := new_row - noise;
+
+  IF TG_OP = 'UPDATE' THEN
+    FOR k IN SELECT jsonb_object_keys(new_row) LOOP
+      IF old_row -> k = new_row -> k THEN
+        old_row := old_row - k;
+        new_row := new_row - k;
+      END IF;
+    END LOOP;
+    IF new_row = '{}'::jsonb THEN
+      RETURN NULL;
+    END IF;
+    IF new_row ? 'deleted_at' THEN
+      act := CASE WHEN new_row -> 'deleted_at' = 'null'::jsonb THEN 'restore' ELSE 'delete' END;
+    END IF;
+  ELSIF TG_OP = 'INSERT' THEN
+    act := 'create';
+  END IF;
+
+  INSERT INTO audit_log (actor_type, actor_id, action, entity_type, entity_id, before, after, request_id)
+  VALUES (
+    COALESCE(NULLIF(current_setting('audit.actor_type', true), ''), 'unknown'),
+    COALESCE(current_setting('audit.actor_id', true), ''),
+    act,
+    TG_TABLE_NAME,
+    COALESCE(new_row, old_row) ->> TG_ARGV[0],
+    old_row,
+    new_row,
+    COALESCE(current_setting('audit.request_id', true), '')
+  );
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd